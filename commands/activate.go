@@ -3,117 +3,208 @@ package commands
 import (
 	"cosm/types"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
 
+// environmentConfig holds the resolved build list and generated environment of a project
+type environmentConfig struct {
+	project       *types.Project
+	cosmDir       string
+	buildListFile string
+	generated     bool // the build list was regenerated
+	buildList     types.BuildList
+	env           map[string]string
+}
+
 // Activate computes the build list for the current project under development
 func Activate(cmd *cobra.Command, args []string) error {
-	project, projectStat, err := validateActivate(args)
+	if len(args) != 0 {
+		return newCodedError(ErrCodeInvalidArgs, "cosm activate takes no arguments; run in package root with Project.json")
+	}
+	if os.Getenv("COSM_PROMPT") != "" {
+		return fmt.Errorf("a cosm environment is already active in this shell; exit it before activating again")
+	}
+	shellFlag, _ := cmd.Flags().GetString("shell")
+	shell, err := detectShell(shellFlag)
 	if err != nil {
 		return err
 	}
+	if shell == shellJSON {
+		return newCodedError(ErrCodeInvalidArgs, "cannot activate a json shell (use bash, zsh or fish)")
+	}
+
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	jobs, err := parseJobs(cmd)
+	if err != nil {
+		return err
+	}
+	config, err := prepareEnvironment(refresh, jobs)
+	if err != nil {
+		return err
+	}
+
+	// Write environment variables and the startup file of the interactive shell
+	if err := writeEnvironmentFiles(config.env); err != nil {
+		return fmt.Errorf("failed to generate environment variables: %v", err)
+	}
+	if err := createShellFiles(shell); err != nil {
+		return err
+	}
+
+	// Machine-readable output reports the build list instead of starting a shell
+	if JSONOutput() {
+		return printJSONResult(activateResult{
+			Project:       projectResult{Name: config.project.Name, UUID: config.project.UUID, Version: config.project.Version},
+			BuildListFile: config.buildListFile,
+			Generated:     config.generated,
+			BuildList:     config.buildList,
+		})
+	}
+
+	// Start a new interactive shell
+	if err := startInteractiveShell(shell); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// prepareEnvironment generates or verifies the build list of the project in the current directory,
+// makes all packages available using up to jobs concurrent jobs and generates the environment
+// variables of the project. With refresh set, the build list is regenerated even if it is up-to-date.
+func prepareEnvironment(refresh bool, jobs int) (*environmentConfig, error) {
+	project, err := loadActivationProject()
+	if err != nil {
+		return nil, err
+	}
 
 	cosmDir, err := getCosmDir()
 	if err != nil {
-		return fmt.Errorf("failed to get cosm directory: %v", err)
+		return nil, fmt.Errorf("failed to get cosm directory: %v", err)
 	}
 	registriesDir := setupRegistriesDir(cosmDir)
-	buildListFile := ".cosm/buildlist.json"
+	config := &environmentConfig{project: project, cosmDir: cosmDir, buildListFile: ".cosm/buildlist.json"}
 
-	if err := generateOrVerifyBuildList(project, projectStat, registriesDir, buildListFile); err != nil {
-		return err
+	config.generated, err = generateOrVerifyBuildList(project, cosmDir, registriesDir, config.buildListFile, refresh)
+	if err != nil {
+		return nil, err
 	}
 
 	// Load build list
-	buildList, err := loadBuildListFile(buildListFile)
+	config.buildList, err = loadBuildListFile(config.buildListFile)
 	if err != nil {
-		return fmt.Errorf("failed to load buildlist.json: %v", err)
+		return nil, fmt.Errorf("failed to load buildlist.json: %v", err)
 	}
 
-	// Generate environment variables
-	if err := generateEnvironmentVariables(cosmDir, &buildList); err != nil {
-		return fmt.Errorf("failed to generate environment variables: %v", err)
+	// Record the project in the depot so that cosm gc keeps its packages
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project directory: %v", err)
+	}
+	if err := registerProject(cosmDir, projectDir); err != nil {
+		return nil, fmt.Errorf("failed to register project: %v", err)
 	}
 
 	// Make all packages available
-	if err := makePackagesAvailable(&buildList, cosmDir); err != nil {
-		return fmt.Errorf("failed to make packages available: %v", err)
+	if err := makePackagesAvailable(&config.buildList, cosmDir, jobs); err != nil {
+		return nil, fmt.Errorf("failed to make packages available: %w", err)
 	}
 
-	// Start a new interactive shell
-	if err := startInteractiveShell(); err != nil {
-		return err
+	// Generate environment variables; language plugins read the Project.json of available packages
+	config.env, err = collectEnvironment(cosmDir, project, &config.buildList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate environment variables: %v", err)
 	}
+	return config, nil
+}
 
-	return nil
+// activateResult is the JSON document printed by cosm activate --output json
+type activateResult struct {
+	Project       projectResult   `json:"project"`
+	BuildListFile string          `json:"buildlist_file"`
+	Generated     bool            `json:"generated"`
+	BuildList     types.BuildList `json:"buildlist"`
 }
 
-// validateActivate checks if the command is run in a valid package root with no arguments
-func validateActivate(args []string) (*types.Project, os.FileInfo, error) {
-	if len(args) != 0 {
-		return nil, nil, fmt.Errorf("cosm activate takes no arguments; run in package root with Project.json")
-	}
+// loadActivationProject checks that the command is run in a valid package root and loads the project
+func loadActivationProject() (*types.Project, error) {
 	projectFile := "Project.json"
-	projectStat, err := os.Stat(projectFile)
-	if err != nil {
+	if _, err := os.Stat(projectFile); err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil, fmt.Errorf("Project.json not found in current directory")
+			return nil, newCodedError(ErrCodeNotFound, "Project.json not found in current directory")
 		}
-		return nil, nil, fmt.Errorf("failed to stat Project.json: %v", err)
+		return nil, fmt.Errorf("failed to stat Project.json: %v", err)
 	}
 	project, err := loadProject(projectFile)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse Project.json: %v", err)
+		return nil, fmt.Errorf("failed to parse Project.json: %v", err)
 	}
-	return project, projectStat, nil
+	return project, nil
 }
 
-// generateOrVerifyBuildList generates the build list if needed or verifies it’s up-to-date
-func generateOrVerifyBuildList(project *types.Project, projectStat os.FileInfo, registriesDir, buildListFile string) error {
-	needsBuildList, err := needsBuildListGeneration(projectStat)
+// generateOrVerifyBuildList generates the build list if needed or forced by refresh, or verifies
+// it’s up-to-date, reporting whether it was generated
+func generateOrVerifyBuildList(project *types.Project, cosmDir, registriesDir, buildListFile string, refresh bool) (bool, error) {
+	digest, err := computeBuildListDigest(project, cosmDir, registriesDir)
 	if err != nil {
-		return err
+		return false, err
+	}
+	needsBuildList := refresh
+	if !needsBuildList {
+		if needsBuildList, err = needsBuildListGeneration(digest); err != nil {
+			return false, err
+		}
 	}
 
 	if needsBuildList {
-		if err := createEnvironmentFiles(); err != nil {
-			return err
+		if err := os.MkdirAll(".cosm", 0755); err != nil {
+			return false, fmt.Errorf("failed to create .cosm directory: %v", err)
 		}
-		if err := generateLocalBuildList(project, registriesDir); err != nil {
-			return err
+		if err := generateLocalBuildList(project, registriesDir, digest); err != nil {
+			return false, err
 		}
-		fmt.Printf("Generated build list for %s in %s\n", project.Name, buildListFile)
+		fmt.Fprintf(messages, "Generated build list for %s in %s\n", project.Name, buildListFile)
 	} else {
-		fmt.Printf("Build list up-to-date in %s\n", buildListFile)
+		fmt.Fprintf(messages, "Build list up-to-date in %s\n", buildListFile)
 	}
-	return nil
+	return needsBuildList, nil
 }
 
-// needsBuildListGeneration checks if buildlist.json needs regeneration based on mod times
-func needsBuildListGeneration(projectStat os.FileInfo) (bool, error) {
+// needsBuildListGeneration checks if buildlist.json is missing or was generated from other inputs
+// than those with the given digest
+func needsBuildListGeneration(digest string) (bool, error) {
 	buildListFile := ".cosm/buildlist.json"
-	buildListStat, err := os.Stat(buildListFile)
-	if err == nil {
-		return !buildListStat.ModTime().After(projectStat.ModTime()), nil
+	if _, err := os.Stat(buildListFile); err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to stat %s: %v", buildListFile, err)
 	}
-	if os.IsNotExist(err) {
-		return true, nil
+	buildList, err := loadBuildListFile(buildListFile)
+	if err != nil {
+		return false, err
 	}
-	return false, fmt.Errorf("failed to stat %s: %v", buildListFile, err)
+	return buildList.Digest != digest, nil
 }
 
-// generateLocalBuildList computes and writes the build list to .cosm/buildlist.json
-func generateLocalBuildList(project *types.Project, registriesDir string) error {
+// generateLocalBuildList computes and writes the build list to .cosm/buildlist.json, recording
+// the digest of its inputs
+func generateLocalBuildList(project *types.Project, registriesDir, digest string) error {
 	buildList, err := generateBuildList(project, registriesDir)
 	if err != nil {
 		return fmt.Errorf("failed to generate build list for %s: %v", project.Name, err)
 	}
+	buildList.Digest = digest
 	data, err := json.MarshalIndent(buildList, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal buildlist.json: %v", err)
@@ -125,12 +216,16 @@ func generateLocalBuildList(project *types.Project, registriesDir string) error
 	return nil
 }
 
-// createEnvironmentFiles creates .cosm directory, .env, and .bashrc
-func createEnvironmentFiles() error {
-	if err := os.MkdirAll(".cosm", 0755); err != nil {
-		return fmt.Errorf("failed to create .cosm directory: %v", err)
-	}
-	const bashrcContent = `# signal that cosm prompt is active
+// Startup files of the interactive shells, relative to the project root
+var shellRCFiles = map[string]string{
+	shellBash: filepath.Join(".cosm", ".bashrc"),
+	shellZsh:  filepath.Join(".cosm", "zsh", ".zshrc"),
+	shellFish: filepath.Join(".cosm", "config.fish"),
+}
+
+// shellRCContents define the cosm prompt and reload the environment before every command
+var shellRCContents = map[string]string{
+	shellBash: `# signal that cosm prompt is active
 		export COSM_PROMPT=1
 
 		# supress depracation warning
@@ -160,62 +255,188 @@ func createEnvironmentFiles() error {
 		esac
 		}
 		trap before_command DEBUG
-		`
-	if err := os.WriteFile(".cosm/.bashrc", []byte(bashrcContent), 0644); err != nil {
-		return fmt.Errorf("failed to write .cosm/.bashrc: %v", err)
+		`,
+	shellZsh: `# signal that cosm prompt is active
+export COSM_PROMPT=1
+
+# define cosm prompt
+PROMPT='%B%F{green}cosm>%f%b '
+
+# reload environment variables in every command
+function cosm_reload_env() {
+	if [ -f .cosm/.env ]; then
+		source .cosm/.env
+	fi
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec cosm_reload_env
+cosm_reload_env
+`,
+	shellFish: `# signal that cosm prompt is active
+set -gx COSM_PROMPT 1
+
+# define cosm prompt
+function fish_prompt
+	set_color --bold green
+	echo -n 'cosm> '
+	set_color normal
+end
+
+# reload environment variables in every command
+function cosm_reload_env --on-event fish_preexec
+	if test -f .cosm/.env.fish
+		source .cosm/.env.fish
+	end
+end
+cosm_reload_env
+`,
+}
+
+// createShellFiles writes the startup file of the interactive shell
+func createShellFiles(shell string) error {
+	rcFile := shellRCFiles[shell]
+	if err := os.MkdirAll(filepath.Dir(rcFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %v", rcFile, err)
+	}
+	if err := os.WriteFile(rcFile, []byte(shellRCContents[shell]), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", rcFile, err)
 	}
 	return nil
 }
 
-// generateEnvironmentVariables creates the .cosm/.env file with environment variables
-func generateEnvironmentVariables(cosmDir string, buildList *types.BuildList) error {
-	// Construct TERRA_PATH
-	var terraPaths []string
-	terraPaths = append(terraPaths, "src/?.t")
+// writeEnvironmentFiles creates .cosm/.env for bash and zsh and .cosm/.env.fish for fish with
+// the environment variables of the project
+func writeEnvironmentFiles(env map[string]string) error {
+	envFiles := map[string]string{
+		filepath.Join(".cosm", ".env"):      formatEnvironment(env, shellBash),
+		filepath.Join(".cosm", ".env.fish"): formatEnvironment(env, shellFish),
+	}
+	for envFile, content := range envFiles {
+		if err := os.WriteFile(envFile, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", envFile, err)
+		}
+	}
+	return nil
+}
+
+// makePackagesAvailable ensures all packages in the build list are available, cloning and
+// extracting up to jobs packages concurrently. Progress is reported as packages complete, and
+// the errors of all failed packages are reported together.
+func makePackagesAvailable(buildList *types.BuildList, cosmDir string, jobs int) error {
+	var pending []types.BuildListDependency
 	for _, dep := range buildList.Dependencies {
-		if dep.Path != "" {
-			pathVar := filepath.Join(cosmDir, dep.Path, "src", "?.t")
-			terraPaths = append(terraPaths, pathVar)
+		if !dep.Develop {
+			pending = append(pending, dep) // Development checkouts are used in place
 		}
 	}
-	terraPathValue := strings.Join(terraPaths, ";") + ";;"
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Name != pending[j].Name {
+			return pending[i].Name < pending[j].Name
+		}
+		return pending[i].Version < pending[j].Version
+	})
+	if jobs > len(pending) {
+		jobs = len(pending)
+	}
+	fmt.Fprintf(messages, "Making %d packages available (jobs: %d)\n", len(pending), jobs)
 
-	// Write to .cosm/.env
-	envContent := fmt.Sprintf("export TERRA_PATH=%q\n", terraPathValue)
-	envFile := filepath.Join(".", ".cosm", ".env")
-	if err := os.WriteFile(envFile, []byte(envContent), 0644); err != nil {
-		return fmt.Errorf("failed to write .cosm/.env: %v", err)
+	var (
+		mu       sync.Mutex
+		done     int
+		failures []string
+		offline  bool // a failure was caused by offline mode
+		wg       sync.WaitGroup
+	)
+	queue := make(chan types.BuildListDependency)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dep := range queue {
+				err := makeDependencyAvailable(cosmDir, dep)
+				mu.Lock()
+				done++
+				if err != nil {
+					var cmdErr *CommandError
+					offline = offline || (errors.As(err, &cmdErr) && cmdErr.Code == ErrCodeOffline)
+					failures = append(failures, fmt.Sprintf("%s@%s: %v", dep.Name, dep.Version, err))
+					fmt.Fprintf(messages, "[%d/%d] Failed %s@%s\n", done, len(pending), dep.Name, dep.Version)
+				} else {
+					fmt.Fprintf(messages, "[%d/%d] Available %s@%s\n", done, len(pending), dep.Name, dep.Version)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, dep := range pending {
+		queue <- dep
 	}
+	close(queue)
+	wg.Wait()
 
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		err := fmt.Errorf("%d of %d packages could not be made available:\n  %s", len(failures), len(pending), strings.Join(failures, "\n  "))
+		if offline {
+			return withCode(ErrCodeOffline, err)
+		}
+		return err
+	}
 	return nil
 }
 
-// makePackagesAvailable ensures all packages in the build list are available
-func makePackagesAvailable(buildList *types.BuildList, cosmDir string) error {
-	registriesDir := setupRegistriesDir(cosmDir)
-	// Process all dependencies
-	for _, dep := range buildList.Dependencies {
-		specs, _, err := findDependency(dep.Name, dep.Version, dep.UUID, registriesDir)
+// makeDependencyAvailable makes a single build list entry available. The entry carries the
+// Git URL and SHA1 of the package, so the registries are only consulted for older build lists.
+func makeDependencyAvailable(cosmDir string, dep types.BuildListDependency) error {
+	specs := types.Specs{Name: dep.Name, UUID: dep.UUID, Version: dep.Version, GitURL: dep.GitURL, SHA1: dep.SHA1, TreeHash: dep.TreeHash}
+	if specs.GitURL == "" || specs.SHA1 == "" {
+		var err error
+		specs, _, err = findDependency(dep.Name, dep.Version, dep.UUID, setupRegistriesDir(cosmDir))
 		if err != nil {
 			return err
 		}
-		if err := MakePackageAvailable(cosmDir, &specs); err != nil {
-			return fmt.Errorf("failed to make package '%s@%s' available: %v", dep.Name, dep.Version, err)
-		}
 	}
-	return nil
+	return MakePackageAvailable(cosmDir, &specs)
 }
 
-// startBashShell starts a new bash shell sourcing .cosm/.bashrc
-func startInteractiveShell() error {
-	bashrcFile := filepath.Join(".cosm", ".bashrc")
-	cmdShell := exec.Command("bash", "--rcfile", bashrcFile)
+// parseJobs returns the number of concurrent jobs given by --jobs, defaulting to the number of CPUs
+func parseJobs(cmd *cobra.Command) (int, error) {
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	if jobs < 0 {
+		return 0, newCodedError(ErrCodeInvalidArgs, "--jobs must be a positive number, got %d", jobs)
+	}
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	return jobs, nil
+}
+
+// startInteractiveShell starts a new interactive shell with the cosm startup file
+func startInteractiveShell(shell string) error {
+	rcFile := shellRCFiles[shell]
+	var cmdShell *exec.Cmd
+	switch shell {
+	case shellZsh:
+		zdotdir, err := filepath.Abs(filepath.Dir(rcFile))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", rcFile, err)
+		}
+		cmdShell = exec.Command("zsh", "-i")
+		cmdShell.Env = append(os.Environ(), "ZDOTDIR="+zdotdir)
+	case shellFish:
+		cmdShell = exec.Command("fish", "--init-command", "source "+rcFile)
+	default:
+		cmdShell = exec.Command("bash", "--rcfile", rcFile)
+	}
 	cmdShell.Stdin = os.Stdin
 	cmdShell.Stdout = os.Stdout
 	cmdShell.Stderr = os.Stderr
-	fmt.Printf("Starting interactive shell. Press ctrl-d or type 'exit' to quit.\n")
+	fmt.Fprintf(messages, "Starting interactive shell. Press ctrl-d or type 'exit' to quit.\n")
 	if err := cmdShell.Run(); err != nil {
-		return fmt.Errorf("failed to start bash shell with .cosm/.bashrc: %v", err)
+		return fmt.Errorf("failed to start %s shell with %s: %v", shell, rcFile, err)
 	}
 	return nil
 }
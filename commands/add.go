@@ -12,7 +12,7 @@ import (
 func Add(cmd *cobra.Command, args []string) error {
 	packageName, versionTag, err := parseAddArgs(args)
 	if err != nil {
-		return err
+		return withCode(ErrCodeInvalidArgs, err)
 	}
 	project, err := loadProject("Project.json")
 	if err != nil {
@@ -26,14 +26,30 @@ func Add(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	selectedPackage, err := findPackageInRegistries(packageName, versionTag, registriesDir, registryNames)
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	selectedPackage, err := findPackageInRegistries(packageName, versionTag, registriesDir, registryNames, refresh)
 	if err != nil {
 		return err
 	}
 	if err := updateProjectWithDependency(project, packageName, selectedPackage.Specs.Version, selectedPackage.RegistryName, selectedPackage.Specs.UUID); err != nil {
 		return err
 	}
-	return nil
+	return printJSONResult(dependencyResult{
+		Action:   "added",
+		Name:     packageName,
+		UUID:     selectedPackage.Specs.UUID,
+		Version:  selectedPackage.Specs.Version,
+		Registry: selectedPackage.RegistryName,
+	})
+}
+
+// dependencyResult is the JSON document printed when a command changes a dependency of the project
+type dependencyResult struct {
+	Action   string `json:"action"`
+	Name     string `json:"name"`
+	UUID     string `json:"uuid,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Registry string `json:"registry,omitempty"`
 }
 
 // parseAddArgs validates and parses the package name and optional version
@@ -73,7 +89,7 @@ func updateDependency(project *types.Project, packageName, versionTag, depUUID s
 
 	// Check if dependency already exists
 	if _, exists := project.Deps[depKey]; exists {
-		return fmt.Errorf("dependency '%s' with major version %s already exists in project", packageName, majorVersion)
+		return newCodedError(ErrCodeAlreadyExists, "dependency '%s' with major version %s already exists in project", packageName, majorVersion)
 	}
 
 	// Add the dependency
@@ -93,6 +109,6 @@ func updateProjectWithDependency(project *types.Project, packageName, versionTag
 	if err := saveProject(project, "Project.json"); err != nil {
 		return err
 	}
-	fmt.Printf("Added dependency '%s' %s from registry '%s' to project\n", packageName, versionTag, registryName)
+	fmt.Fprintf(messages, "Added dependency '%s' %s from registry '%s' to project\n", packageName, versionTag, registryName)
 	return nil
 }
@@ -1,7 +1,109 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
 
-func Develop(cmd *cobra.Command, args []string) {
+	"github.com/spf13/cobra"
+)
 
+// developResult is the JSON document printed by cosm develop and free --output json
+type developResult struct {
+	Action  string `json:"action"` // "developed" or "freed"
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	Version string `json:"version"`
+	Path    string `json:"path"` // development checkout
+}
+
+// Develop switches an existing dependency to development mode, using a git clone
+// of the dependency in $COSM_DEPOT_PATH/dev/<name>@v<major> instead of a registered release
+func Develop(cmd *cobra.Command, args []string) error {
+	packageName, err := parseDevelopArgs(args, "develop")
+	if err != nil {
+		return err
+	}
+	project, err := loadProject("Project.json")
+	if err != nil {
+		return err
+	}
+	depKey, err := selectDependencyKey(project, packageName, "develop")
+	if err != nil {
+		return err
+	}
+	dep := project.Deps[depKey]
+	depUUID, err := extractUUIDFromKey(depKey)
+	if err != nil {
+		return err
+	}
+
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return err
+	}
+	majorVersion, err := GetMajorVersion(dep.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get major version for '%s@%s': %v", packageName, dep.Version, err)
+	}
+	devDir := filepath.Join(cosmDir, developPath(packageName, majorVersion))
+	result := developResult{Action: "developed", Name: packageName, UUID: depUUID, Version: dep.Version, Path: devDir}
+	if dep.Develop {
+		fmt.Fprintf(messages, "Dependency '%s' is already in development mode at %s\n", packageName, devDir)
+		return printJSONResult(result)
+	}
+
+	if err := ensureDevelopCheckout(cosmDir, devDir, packageName, dep.Version, depUUID); err != nil {
+		return err
+	}
+
+	dep.Develop = true
+	project.Deps[depKey] = dep
+	if err := saveProject(project, "Project.json"); err != nil {
+		return err
+	}
+	fmt.Fprintf(messages, "Dependency '%s' is now in development mode at %s\n", packageName, devDir)
+	return printJSONResult(result)
+}
+
+// parseDevelopArgs validates the package name argument for develop and free
+func parseDevelopArgs(args []string, command string) (string, error) {
+	if len(args) != 1 {
+		return "", newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm %s <package_name>)", command)
+	}
+	packageName := args[0]
+	if packageName == "" {
+		return "", newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
+	}
+	return packageName, nil
+}
+
+// ensureDevelopCheckout clones the dependency into devDir unless a checkout of the same package already exists
+func ensureDevelopCheckout(cosmDir, devDir, packageName, version, depUUID string) error {
+	if _, err := os.Stat(devDir); os.IsNotExist(err) {
+		registriesDir := setupRegistriesDir(cosmDir)
+		specs, _, err := findDependency(packageName, version, depUUID, registriesDir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(devDir), 0755); err != nil {
+			return fmt.Errorf("failed to create dev directory %s: %v", filepath.Dir(devDir), err)
+		}
+		if _, err := clone(specs.GitURL, filepath.Dir(devDir), filepath.Base(devDir)); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to check development checkout at %s: %v", devDir, err)
+	} else {
+		fmt.Fprintf(messages, "Using existing development checkout at %s\n", devDir)
+	}
+
+	devProject, err := loadProjectFromDir(devDir)
+	if err != nil {
+		return err
+	}
+	if devProject.UUID != depUUID {
+		return fmt.Errorf("development checkout at %s has UUID '%s', expected '%s' for '%s'", devDir, devProject.UUID, depUUID, packageName)
+	}
+	return nil
 }
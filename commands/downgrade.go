@@ -1,7 +1,319 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"cosm/types"
+	"fmt"
+	"sort"
 
-func Downgrade(cmd *cobra.Command, args []string) {
+	"github.com/spf13/cobra"
+)
 
+// downgradeConfig holds configuration for downgrading a dependency
+type downgradeConfig struct {
+	packageName   string
+	version       string
+	refresh       bool
+	registriesDir string
+	project       *types.Project
+	projectFile   string
+}
+
+// requirementChange records a change to a direct requirement made by a downgrade
+type requirementChange struct {
+	key        string
+	name       string
+	oldVersion string // empty if the requirement was added
+	newVersion string // empty if the requirement was removed
+}
+
+// Downgrade lowers a direct or transitive dependency to an older version. Direct
+// requirements whose build lists need a newer version of the dependency are lowered
+// to their newest compatible version, following Minimal Version Selection. Development
+// checkouts that need a newer version are not changed and make the downgrade fail.
+func Downgrade(cmd *cobra.Command, args []string) error {
+	config, err := parseDowngradeArgs(args)
+	if err != nil {
+		return err
+	}
+	config.refresh, _ = cmd.Flags().GetBool("refresh")
+
+	// Make sure registries are up-to-date before resolving versions
+	if err := updateAllRegistries(config.registriesDir, config.refresh); err != nil {
+		return err
+	}
+
+	targetKey, targetUUID, currentVersion, err := findDowngradeTarget(config)
+	if err != nil {
+		return err
+	}
+	if err := validateDowngradeVersion(config, targetUUID, currentVersion); err != nil {
+		return err
+	}
+
+	changes, err := computeDowngradeChanges(config, targetKey, targetUUID)
+	if err != nil {
+		return err
+	}
+
+	printRequirementChanges(config, changes)
+	applyRequirementChanges(config.project, changes)
+	if err := saveProject(config.project, config.projectFile); err != nil {
+		return err
+	}
+	fmt.Fprintf(messages, "Downgraded dependency '%s' from %s to %s\n", config.packageName, currentVersion, config.version)
+	return printJSONResult(newDowngradeResult(config, changes))
+}
+
+// parseDowngradeArgs validates the package name and version and loads the project
+func parseDowngradeArgs(args []string) (*downgradeConfig, error) {
+	if len(args) != 2 {
+		return nil, newCodedError(ErrCodeInvalidArgs, "expected two arguments in the format <package_name> v<version> (e.g., cosm downgrade mypkg v1.2.3)")
+	}
+	config := &downgradeConfig{packageName: args[0], version: args[1], projectFile: "Project.json"}
+	if config.packageName == "" {
+		return nil, newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
+	}
+	if err := validateVersion(config.version); err != nil {
+		return nil, withCode(ErrCodeInvalidArgs, err)
+	}
+	if _, err := ParseSemVer(config.version); err != nil {
+		return nil, withCode(ErrCodeInvalidArgs, err)
+	}
+
+	project, err := loadProject(config.projectFile)
+	if err != nil {
+		return nil, err
+	}
+	config.project = project
+
+	config.registriesDir, err = getRegistriesDir()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// findDowngradeTarget locates the dependency to downgrade in the build list and returns
+// its key, UUID and currently selected version
+func findDowngradeTarget(config *downgradeConfig) (string, string, string, error) {
+	majorVersion, err := GetMajorVersion(config.version)
+	if err != nil {
+		return "", "", "", err
+	}
+	buildList, err := generateBuildList(config.project, config.registriesDir)
+	if err != nil {
+		return "", "", "", err
+	}
+	for key, dep := range buildList.Dependencies {
+		if dep.Name != config.packageName {
+			continue
+		}
+		depMajor, err := GetMajorVersion(dep.Version)
+		if err != nil {
+			return "", "", "", err
+		}
+		if depMajor == majorVersion {
+			return key, dep.UUID, dep.Version, nil
+		}
+	}
+	return "", "", "", newCodedError(ErrCodeNotFound, "dependency '%s' with major version %s not found in project or its build list", config.packageName, majorVersion)
+}
+
+// validateDowngradeVersion ensures the requested version is registered and older than the current one
+func validateDowngradeVersion(config *downgradeConfig, targetUUID, currentVersion string) error {
+	c, err := CompareSemVer(config.version, currentVersion)
+	if err != nil {
+		return err
+	}
+	if c == 0 {
+		return newCodedError(ErrCodeInvalidArgs, "dependency '%s' is already at %s", config.packageName, currentVersion)
+	}
+	if c > 0 {
+		return newCodedError(ErrCodeInvalidArgs, "version '%s' of '%s' is newer than the current version %s; use 'cosm upgrade' instead", config.version, config.packageName, currentVersion)
+	}
+
+	registered, err := findRegisteredVersions(config.packageName, targetUUID, config.registriesDir)
+	if err != nil {
+		return err
+	}
+	for _, rv := range registered {
+		if rv.Version == config.version && !rv.Yanked {
+			return nil
+		}
+	}
+	for _, rv := range registered {
+		if rv.Version == config.version {
+			return newCodedError(ErrCodeNotFound, "version '%s' of '%s' is yanked in registry '%s'", config.version, config.packageName, rv.RegistryName)
+		}
+	}
+	return newCodedError(ErrCodeNotFound, "version '%s' of '%s' not found in any registry", config.version, config.packageName)
+}
+
+// computeDowngradeChanges determines the direct requirement changes needed so that the
+// build list selects at most the requested version of the target
+func computeDowngradeChanges(config *downgradeConfig, targetKey, targetUUID string) ([]requirementChange, error) {
+	var changes []requirementChange
+
+	// Pin the target itself, as an explicit requirement if it was transitive
+	if dep, exists := config.project.Deps[targetKey]; exists {
+		changes = append(changes, requirementChange{key: targetKey, name: dep.Name, oldVersion: dep.Version, newVersion: config.version})
+	} else {
+		changes = append(changes, requirementChange{key: targetKey, name: config.packageName, newVersion: config.version})
+	}
+
+	keys := make([]string, 0, len(config.project.Deps))
+	for key := range config.project.Deps {
+		if key != targetKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return config.project.Deps[keys[i]].Name < config.project.Deps[keys[j]].Name
+	})
+
+	for _, key := range keys {
+		dep := config.project.Deps[key]
+		depUUID, err := extractUUIDFromKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if dep.Develop {
+			if err := checkDevelopRequirement(config, dep, depUUID, targetKey); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		compatible, err := requiresAtMost(dep.Name, dep.Version, depUUID, targetKey, config.version, config.registriesDir)
+		if err != nil {
+			return nil, err
+		}
+		if compatible {
+			continue
+		}
+		newVersion, err := findCompatibleVersion(dep, depUUID, targetKey, config.version, config.registriesDir)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, requirementChange{key: key, name: dep.Name, oldVersion: dep.Version, newVersion: newVersion})
+	}
+	return changes, nil
+}
+
+// checkDevelopRequirement fails if the development checkout of a direct dependency requires a
+// newer version of the target, since its requirements are only changed by its developer
+func checkDevelopRequirement(config *downgradeConfig, dep types.Dependency, depUUID, targetKey string) error {
+	buildList, err := resolveDevelopBuildList(dep.Name, dep.Version, depUUID, config.registriesDir)
+	if err != nil {
+		return err
+	}
+	compatible, err := selectsAtMost(buildList, targetKey, config.version)
+	if err != nil {
+		return err
+	}
+	if !compatible {
+		return newCodedError(ErrCodeInvalidArgs, "development checkout of '%s' requires '%s' %s, newer than %s; lower its requirement or run 'cosm free %s' first",
+			dep.Name, config.packageName, buildList.Dependencies[targetKey].Version, config.version, dep.Name)
+	}
+	return nil
+}
+
+// requiresAtMost reports whether the build list of a dependency version requires the
+// target key at a version no newer than maxVersion
+func requiresAtMost(depName, depVersion, depUUID, targetKey, maxVersion, registriesDir string) (bool, error) {
+	buildList, err := resolveDependencyBuildList(depName, depVersion, depUUID, registriesDir)
+	if err != nil {
+		return false, err
+	}
+	return selectsAtMost(buildList, targetKey, maxVersion)
+}
+
+// selectsAtMost reports whether a build list selects the target key at a version no newer
+// than maxVersion, or not at all
+func selectsAtMost(buildList types.BuildList, targetKey, maxVersion string) (bool, error) {
+	entry, exists := buildList.Dependencies[targetKey]
+	if !exists {
+		return true, nil
+	}
+	c, err := CompareSemVer(entry.Version, maxVersion)
+	if err != nil {
+		return false, err
+	}
+	return c <= 0, nil
+}
+
+// findCompatibleVersion finds the newest version of a direct dependency, older than its
+// current requirement and within the same major version, whose build list is compatible
+// with the downgraded target. An empty version means the requirement must be removed.
+func findCompatibleVersion(dep types.Dependency, depUUID, targetKey, maxVersion, registriesDir string) (string, error) {
+	current, err := ParseSemVer(dep.Version)
+	if err != nil {
+		return "", err
+	}
+	registered, err := findRegisteredVersions(dep.Name, depUUID, registriesDir)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, rv := range registered {
+		s, err := ParseSemVer(rv.Version)
+		if err != nil || s.Major != current.Major || s.IsPrerelease() || rv.Yanked {
+			continue
+		}
+		if compareSemVer(s, current) < 0 && !contains(candidates, rv.Version) {
+			candidates = append(candidates, rv.Version)
+		}
+	}
+	sortVersions(candidates)
+
+	// Walk candidates from newest to oldest
+	for i := len(candidates) - 1; i >= 0; i-- {
+		compatible, err := requiresAtMost(dep.Name, candidates[i], depUUID, targetKey, maxVersion, registriesDir)
+		if err != nil {
+			return "", err
+		}
+		if compatible {
+			return candidates[i], nil
+		}
+	}
+	return "", nil
+}
+
+// printRequirementChanges reports the direct requirement changes before they are written
+func printRequirementChanges(config *downgradeConfig, changes []requirementChange) {
+	fmt.Fprintf(messages, "Downgrading '%s' to %s changes the following direct requirements:\n", config.packageName, config.version)
+	for _, change := range changes {
+		switch {
+		case change.oldVersion == "":
+			fmt.Fprintf(messages, "  %s: added %s\n", change.name, change.newVersion)
+		case change.newVersion == "":
+			fmt.Fprintf(messages, "  %s: %s -> removed (no compatible version)\n", change.name, change.oldVersion)
+		default:
+			fmt.Fprintf(messages, "  %s: %s -> %s\n", change.name, change.oldVersion, change.newVersion)
+		}
+	}
+}
+
+// newDowngradeResult describes the direct requirement changes made by a downgrade
+func newDowngradeResult(config *downgradeConfig, changes []requirementChange) requirementChangesResult {
+	result := requirementChangesResult{Action: "downgraded", Package: config.packageName, Changes: []requirementChangeResult{}}
+	for _, change := range changes {
+		depUUID, _ := extractUUIDFromKey(change.key)
+		result.Changes = append(result.Changes, requirementChangeResult{Name: change.name, UUID: depUUID, From: change.oldVersion, To: change.newVersion})
+	}
+	return result
+}
+
+// applyRequirementChanges updates the project's direct requirements
+func applyRequirementChanges(project *types.Project, changes []requirementChange) {
+	for _, change := range changes {
+		if change.newVersion == "" {
+			delete(project.Deps, change.key)
+			continue
+		}
+		dep := project.Deps[change.key]
+		dep.Name = change.name
+		dep.Version = change.newVersion
+		project.Deps[change.key] = dep
+	}
 }
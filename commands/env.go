@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Env prints the environment of the project in the current directory for the selected shell,
+// e.g. for use with eval "$(cosm env)"
+func Env(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return newCodedError(ErrCodeInvalidArgs, "cosm env takes no arguments; run in package root with Project.json")
+	}
+	shellFlag, _ := cmd.Flags().GetString("shell")
+	shell, err := detectShell(shellFlag)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := parseJobs(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Progress messages go to stderr so that stdout can be evaluated by the shell
+	restore := redirectMessages(os.Stderr)
+	config, err := prepareEnvironment(false, jobs)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	if JSONOutput() {
+		return printJSONResult(config.env)
+	}
+	fmt.Fprint(messages, formatEnvironment(config.env, shell))
+	return nil
+}
@@ -1,7 +1,51 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"path/filepath"
 
-func Free(cmd *cobra.Command, args []string) {
+	"github.com/spf13/cobra"
+)
 
+// Free closes development mode for a dependency, returning to its registered release.
+// The development checkout is kept on disk.
+func Free(cmd *cobra.Command, args []string) error {
+	packageName, err := parseDevelopArgs(args, "free")
+	if err != nil {
+		return err
+	}
+	project, err := loadProject("Project.json")
+	if err != nil {
+		return err
+	}
+	depKey, err := selectDependencyKey(project, packageName, "free")
+	if err != nil {
+		return err
+	}
+	dep := project.Deps[depKey]
+	if !dep.Develop {
+		return newCodedError(ErrCodeInvalidArgs, "dependency '%s' is not in development mode", packageName)
+	}
+
+	dep.Develop = false
+	project.Deps[depKey] = dep
+	if err := saveProject(project, "Project.json"); err != nil {
+		return err
+	}
+
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return err
+	}
+	majorVersion, err := GetMajorVersion(dep.Version)
+	if err != nil {
+		return err
+	}
+	depUUID, err := extractUUIDFromKey(depKey)
+	if err != nil {
+		return err
+	}
+	devDir := filepath.Join(cosmDir, developPath(packageName, majorVersion))
+	fmt.Fprintf(messages, "Dependency '%s' is no longer in development mode and uses release %s (development checkout kept at %s)\n", packageName, dep.Version, devDir)
+	return printJSONResult(developResult{Action: "freed", Name: packageName, UUID: depUUID, Version: dep.Version, Path: devDir})
 }
@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// gcConfig holds configuration for garbage-collecting the depot
+type gcConfig struct {
+	cosmDir    string
+	dryRun     bool
+	keepLatest int
+}
+
+// gcResult is the JSON document printed by cosm gc --output json
+type gcResult struct {
+	DryRun   bool      `json:"dry_run"`
+	Projects []string  `json:"projects"`
+	Removed  []gcEntry `json:"removed"`
+	Freed    int64     `json:"freed_bytes"`
+}
+
+// gcEntry is a package or clone directory removed from the depot
+type gcEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size_bytes"`
+}
+
+// Gc removes package versions and clones that are not used by the build list of any project in the
+// project index of the depot. Development checkouts in dev/ are never removed.
+func Gc(cmd *cobra.Command, args []string) error {
+	config, err := parseGcArgs(cmd, args)
+	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+
+	unlock, err := lockProjectIndex(config.cosmDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	projectDirs, keepPackages, keepClones, err := collectProjectReferences(config.cosmDir)
+	if err != nil {
+		return err
+	}
+	if config.keepLatest > 0 {
+		if err := keepLatestVersions(config.cosmDir, config.keepLatest, keepPackages, keepClones); err != nil {
+			return err
+		}
+	}
+
+	packages, err := listPackageDirs(config.cosmDir)
+	if err != nil {
+		return err
+	}
+	clones, err := listDepotEntries(filepath.Join(config.cosmDir, "clones"))
+	if err != nil {
+		return err
+	}
+
+	result := gcResult{DryRun: config.dryRun, Projects: projectDirs, Removed: []gcEntry{}}
+	for _, pkg := range packages {
+		if keepPackages[pkg] {
+			continue
+		}
+		// Packages are extracted while their clone is locked; partial ones have no UUID to lock
+		packageUUID := ""
+		if project, err := loadProjectFromDir(filepath.Join(config.cosmDir, pkg)); err == nil {
+			packageUUID = project.UUID
+		}
+		entry, err := removeDepotEntry(config, pkg, packageUUID)
+		if err != nil {
+			return err
+		}
+		result.Removed = append(result.Removed, entry)
+		result.Freed += entry.Size
+	}
+	for _, uuid := range clones {
+		if keepClones[uuid] {
+			continue
+		}
+		entry, err := removeDepotEntry(config, filepath.Join("clones", uuid), uuid)
+		if err != nil {
+			return err
+		}
+		result.Removed = append(result.Removed, entry)
+		result.Freed += entry.Size
+	}
+
+	if config.dryRun {
+		fmt.Fprintf(messages, "Would free %s in %d directories (kept packages of %d projects)\n", formatSize(result.Freed), len(result.Removed), len(projectDirs))
+		return printJSONResult(result)
+	}
+	if err := removeEmptyPackageDirs(config.cosmDir); err != nil {
+		return err
+	}
+	if err := saveProjectIndex(config.cosmDir, projectDirs); err != nil {
+		return err
+	}
+	fmt.Fprintf(messages, "Freed %s in %d directories (kept packages of %d projects)\n", formatSize(result.Freed), len(result.Removed), len(projectDirs))
+	return printJSONResult(result)
+}
+
+// parseGcArgs validates the arguments and flags of cosm gc
+func parseGcArgs(cmd *cobra.Command, args []string) (*gcConfig, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("cosm gc takes no arguments")
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	keepLatest, _ := cmd.Flags().GetInt("keep-latest")
+	if keepLatest < 0 {
+		return nil, fmt.Errorf("--keep-latest must not be negative, got %d", keepLatest)
+	}
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return nil, err
+	}
+	return &gcConfig{cosmDir: cosmDir, dryRun: dryRun, keepLatest: keepLatest}, nil
+}
+
+// collectProjectReferences returns the projects of the index that still exist together with the
+// package paths and clone UUIDs used by their build lists. Projects that were never activated
+// have no build list and keep no packages.
+func collectProjectReferences(cosmDir string) ([]string, map[string]bool, map[string]bool, error) {
+	indexed, err := loadProjectIndex(cosmDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	projectDirs := []string{}
+	keepPackages := make(map[string]bool)
+	keepClones := make(map[string]bool)
+	for _, projectDir := range indexed {
+		if _, err := os.Stat(filepath.Join(projectDir, "Project.json")); os.IsNotExist(err) {
+			fmt.Fprintf(messages, "Forgetting project %s (no Project.json)\n", projectDir)
+			continue
+		}
+		projectDirs = append(projectDirs, projectDir)
+		buildListFile := filepath.Join(projectDir, ".cosm", "buildlist.json")
+		if _, err := os.Stat(buildListFile); os.IsNotExist(err) {
+			fmt.Fprintf(messages, "Warning: project %s has no build list; run 'cosm activate' in it to keep its packages\n", projectDir)
+			continue
+		}
+		buildList, err := loadBuildListFile(buildListFile)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load build list of project %s: %v", projectDir, err)
+		}
+		for _, dep := range buildList.Dependencies {
+			if dep.Develop {
+				continue // Development checkouts in dev/ are never collected
+			}
+			keepPackages[filepath.Clean(dep.Path)] = true
+			keepClones[dep.UUID] = true
+		}
+	}
+	return projectDirs, keepPackages, keepClones, nil
+}
+
+// keepLatestVersions additionally keeps the n latest materialized versions of every package, as
+// far as their versions are known from the registries. Versions with unreadable specs are unknown.
+func keepLatestVersions(cosmDir string, n int, keepPackages, keepClones map[string]bool) error {
+	index, _, err := indexRegisteredSpecs(setupRegistriesDir(cosmDir))
+	if err != nil {
+		return err
+	}
+	packages, err := listPackageDirs(cosmDir)
+	if err != nil {
+		return err
+	}
+	// Packages are identified by UUID, since different packages may share a name
+	versions := make(map[string][]string) // package UUID -> materialized versions
+	paths := make(map[string]string)      // uuid@version -> package path
+	for _, pkg := range packages {
+		name, sha1 := filepath.Base(filepath.Dir(pkg)), filepath.Base(pkg)
+		specs, ok := index[name+"@"+sha1]
+		if !ok {
+			continue
+		}
+		versions[specs.UUID] = append(versions[specs.UUID], specs.Version)
+		paths[specs.UUID+"@"+specs.Version] = pkg
+	}
+	for packageUUID, list := range versions {
+		sortVersions(list)
+		for i := len(list) - 1; i >= 0 && i >= len(list)-n; i-- {
+			keepPackages[paths[packageUUID+"@"+list[i]]] = true
+			keepClones[packageUUID] = true
+		}
+	}
+	return nil
+}
+
+// listPackageDirs returns the paths packages/<name>/<sha1> of all materialized packages,
+// relative to the depot
+func listPackageDirs(cosmDir string) ([]string, error) {
+	names, err := listDepotEntries(filepath.Join(cosmDir, "packages"))
+	if err != nil {
+		return nil, err
+	}
+	var packages []string
+	for _, name := range names {
+		shas, err := listDepotEntries(filepath.Join(cosmDir, "packages", name))
+		if err != nil {
+			return nil, err
+		}
+		for _, sha1 := range shas {
+			packages = append(packages, filepath.Join("packages", name, sha1))
+		}
+	}
+	return packages, nil
+}
+
+// listDepotEntries returns the sorted subdirectories of dir, skipping temporary directories of
+// operations in progress
+func listDepotEntries(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), "tmp-") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// removeDepotEntry removes a directory of the depot while holding the lock of the clone with the
+// given UUID, if any, or only reports its size in a dry run
+func removeDepotEntry(config *gcConfig, path, packageUUID string) (gcEntry, error) {
+	fullPath := filepath.Join(config.cosmDir, path)
+	size, err := dirSize(fullPath)
+	if err != nil {
+		return gcEntry{}, err
+	}
+	entry := gcEntry{Path: path, Size: size}
+	if config.dryRun {
+		fmt.Fprintf(messages, "Would remove %s (%s)\n", path, formatSize(size))
+		return entry, nil
+	}
+	if packageUUID != "" {
+		unlock, err := lockClone(config.cosmDir, packageUUID)
+		if err != nil {
+			return gcEntry{}, err
+		}
+		defer unlock()
+	}
+	if err := os.RemoveAll(fullPath); err != nil {
+		return gcEntry{}, fmt.Errorf("failed to remove %s: %v", fullPath, err)
+	}
+	fmt.Fprintf(messages, "Removed %s (%s)\n", path, formatSize(size))
+	return entry, nil
+}
+
+// removeEmptyPackageDirs removes packages/<name> directories without any remaining version
+func removeEmptyPackageDirs(cosmDir string) error {
+	names, err := listDepotEntries(filepath.Join(cosmDir, "packages"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		dir := filepath.Join(cosmDir, "packages", name)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", dir, err)
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(dir); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dirSize returns the total size of the regular files in dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute size of %s: %v", dir, err)
+	}
+	return size, nil
+}
+
+// formatSize formats a number of bytes with a binary unit
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"cosm/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// graphConfig holds configuration for exporting the dependency graph
+type graphConfig struct {
+	format string
+	all    bool
+}
+
+// dependencyGraph is the exported requirement graph with nodes keyed by <uuid>@<major version>
+type dependencyGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// graphNode is a package in the graph, labeled with its selected version
+type graphNode struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Develop bool   `json:"develop,omitempty"`
+	Root    bool   `json:"root,omitempty"`
+}
+
+// graphEdge is a requirement of a package version on a dependency version
+type graphEdge struct {
+	From        string `json:"from"`
+	FromVersion string `json:"fromversion"`
+	To          string `json:"to"`
+	Version     string `json:"version"`              // version required by the edge
+	Superseded  bool   `json:"superseded,omitempty"` // the requiring version is not in the build list
+}
+
+// Graph exports the dependency graph of the project in the current directory
+func Graph(cmd *cobra.Command, args []string) error {
+	config, err := parseGraphArgs(cmd, args)
+	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	project, err := loadProject("Project.json")
+	if err != nil {
+		return err
+	}
+	registriesDir, err := getRegistriesDir()
+	if err != nil {
+		return err
+	}
+
+	graph, err := buildDependencyGraph(project, registriesDir, config.all)
+	if err != nil {
+		return err
+	}
+
+	if JSONOutput() {
+		return printJSONResult(graph)
+	}
+	switch config.format {
+	case "dot":
+		fmt.Fprint(messages, formatGraphDOT(project.Name, graph))
+	case "mermaid":
+		fmt.Fprint(messages, formatGraphMermaid(graph))
+	case "json":
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal dependency graph: %v", err)
+		}
+		fmt.Fprintln(messages, string(data))
+	}
+	return nil
+}
+
+// parseGraphArgs validates arguments and flags for the graph command
+func parseGraphArgs(cmd *cobra.Command, args []string) (*graphConfig, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("cosm graph takes no arguments; run in package root with Project.json")
+	}
+	format, _ := cmd.Flags().GetString("format")
+	all, _ := cmd.Flags().GetBool("all")
+	switch format {
+	case "dot", "mermaid", "json":
+	default:
+		return nil, fmt.Errorf("unsupported graph format '%s' (use dot, mermaid or json)", format)
+	}
+	return &graphConfig{format: format, all: all}, nil
+}
+
+// buildDependencyGraph walks the requirement graph of a project. By default only the
+// requirements of the versions in the build list are included; with all set, requirements
+// of superseded versions are included as well.
+func buildDependencyGraph(project *types.Project, registriesDir string, all bool) (dependencyGraph, error) {
+	resolver, err := resolveProjectRequirements(project, registriesDir)
+	if err != nil {
+		return dependencyGraph{}, fmt.Errorf("failed to generate build list for %s: %v", project.Name, err)
+	}
+	buildList, err := resolver.buildList()
+	if err != nil {
+		return dependencyGraph{}, fmt.Errorf("failed to generate build list for %s: %v", project.Name, err)
+	}
+
+	rootID := project.UUID
+	if major, err := GetMajorVersion(project.Version); err == nil {
+		rootID = fmt.Sprintf("%s@%s", project.UUID, major)
+	}
+	graph := dependencyGraph{Nodes: []graphNode{{ID: rootID, Name: project.Name, Version: project.Version, Root: true}}}
+
+	// Map every node of the requirement graph to its key and version, and mark the selected ones
+	nodeKeys := map[string]requirement{rootNode: {key: rootID, name: project.Name, version: project.Version}}
+	for _, reqs := range resolver.requirements {
+		for _, req := range reqs {
+			nodeKeys[req.node] = req
+		}
+	}
+	selectedNodes := map[string]bool{rootNode: true}
+	for _, key := range sortedBuildListKeys(buildList) {
+		entry := buildList.Dependencies[key]
+		graph.Nodes = append(graph.Nodes, graphNode{ID: key, Name: entry.Name, Version: entry.Version, Develop: entry.Develop})
+		if entry.Develop {
+			selectedNodes[developNode(key)] = true
+		} else {
+			selectedNodes[fmt.Sprintf("%s@%s", entry.UUID, entry.Version)] = true
+		}
+	}
+
+	seen := make(map[graphEdge]bool)
+	for node, reqs := range resolver.requirements {
+		if !all && !selectedNodes[node] {
+			continue
+		}
+		from := nodeKeys[node]
+		for _, req := range reqs {
+			edge := graphEdge{From: from.key, FromVersion: from.version, To: req.key, Version: req.version, Superseded: !selectedNodes[node]}
+			if !seen[edge] {
+				seen[edge] = true
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.FromVersion != b.FromVersion {
+			return a.FromVersion < b.FromVersion
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Version < b.Version
+	})
+	return graph, nil
+}
+
+// formatGraphDOT formats the graph in Graphviz DOT format; superseded edges are dashed
+func formatGraphDOT(projectName string, graph dependencyGraph) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", projectName)
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&sb, "  %q [label=%q];\n", node.ID, graphNodeLabel(node, "\n"))
+	}
+	for _, edge := range graph.Edges {
+		if edge.Superseded {
+			fmt.Fprintf(&sb, "  %q -> %q [label=%q, style=dashed];\n", edge.From, edge.To, fmt.Sprintf("%s requires %s", edge.FromVersion, edge.Version))
+		} else {
+			fmt.Fprintf(&sb, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Version)
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// formatGraphMermaid formats the graph as a Mermaid flowchart; superseded edges are dotted
+func formatGraphMermaid(graph dependencyGraph) string {
+	ids := make(map[string]string, len(graph.Nodes))
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+	for i, node := range graph.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", ids[node.ID], graphNodeLabel(node, "<br/>"))
+	}
+	for _, edge := range graph.Edges {
+		if edge.Superseded {
+			fmt.Fprintf(&sb, "  %s -.->|\"%s requires %s\"| %s\n", ids[edge.From], edge.FromVersion, edge.Version, ids[edge.To])
+		} else {
+			fmt.Fprintf(&sb, "  %s -->|\"%s\"| %s\n", ids[edge.From], edge.Version, ids[edge.To])
+		}
+	}
+	return sb.String()
+}
+
+// graphNodeLabel returns the label of a node, its name and version separated by sep
+func graphNodeLabel(node graphNode, sep string) string {
+	label := node.Name + sep + node.Version
+	if node.Develop {
+		label += " (develop)"
+	}
+	return label
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"cosm/types"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// infoConfig holds configuration for showing the registry metadata of a package
+type infoConfig struct {
+	packageName   string
+	versionQuery  string
+	query         *versionQuery
+	registryNames []string
+	refresh       bool
+	registriesDir string
+}
+
+// infoResult is the JSON document printed by cosm info --output json
+type infoResult struct {
+	Packages []packageInfoResult `json:"packages"`
+}
+
+// packageInfoResult describes a package in one registry together with the selected version
+type packageInfoResult struct {
+	Name       string              `json:"name"`
+	UUID       string              `json:"uuid"`
+	GitURL     string              `json:"giturl"`
+	Registry   string              `json:"registry"`
+	Versions   []versionInfo       `json:"versions"`
+	Selected   string              `json:"selected"`
+	Deps       []dependencyInfo    `json:"deps"`
+	BuildList  []dependencyInfo    `json:"buildlist"`
+	Dependents []registryDependent `json:"dependents"`
+}
+
+// versionInfo is a registered version of a package and its commit
+type versionInfo struct {
+	Version string               `json:"version"`
+	SHA1    string               `json:"sha1"`
+	Yanked  *types.YankedVersion `json:"yanked,omitempty"`
+}
+
+// dependencyInfo is a requirement or build list entry of the selected version
+type dependencyInfo struct {
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	Version string `json:"version"`
+	SHA1    string `json:"sha1,omitempty"`
+}
+
+// Info prints the registered versions of a package, the requirements and build list of the latest
+// version or the latest version matching a query, and the registered versions requiring it
+func Info(cmd *cobra.Command, args []string) error {
+	config, err := parseInfoArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	result := infoResult{Packages: []packageInfoResult{}}
+	for _, registryName := range config.registryNames {
+		if _, err := refreshRegistry(config.registriesDir, registryName, config.refresh); err != nil {
+			return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", registryName, err))
+		}
+		registry, _, err := LoadRegistryMetadata(config.registriesDir, registryName)
+		if err != nil {
+			return err
+		}
+		pkgInfo, exists := registry.Packages[config.packageName]
+		if !exists {
+			continue
+		}
+		info, err := collectPackageInfo(config, registryName, pkgInfo)
+		if err != nil {
+			return err
+		}
+		result.Packages = append(result.Packages, info)
+	}
+	if len(result.Packages) == 0 {
+		return newCodedError(ErrCodeNotFound, "package '%s' not found in any registry", config.packageName)
+	}
+
+	for i, info := range result.Packages {
+		if i > 0 {
+			fmt.Fprintln(messages)
+		}
+		printPackageInfo(info)
+	}
+	return printJSONResult(result)
+}
+
+// parseInfoArgs parses <package>[@<version>] and selects the registries to inspect
+func parseInfoArgs(cmd *cobra.Command, args []string) (*infoConfig, error) {
+	if len(args) != 1 {
+		return nil, newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm info mypkg or cosm info mypkg@v1.2)")
+	}
+	config := &infoConfig{packageName: args[0]}
+	if idx := strings.Index(args[0], "@"); idx >= 0 {
+		config.packageName, config.versionQuery = args[0][:idx], args[0][idx+1:]
+		query, err := parseVersionQuery(config.versionQuery)
+		if err != nil {
+			return nil, withCode(ErrCodeInvalidArgs, err)
+		}
+		config.query = &query
+	}
+	if config.packageName == "" {
+		return nil, newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
+	}
+	config.refresh, _ = cmd.Flags().GetBool("refresh")
+	registryName, _ := cmd.Flags().GetString("registry")
+
+	var err error
+	config.registriesDir, err = getRegistriesDir()
+	if err != nil {
+		return nil, err
+	}
+	config.registryNames, err = loadRegistryNames(config.registriesDir)
+	if err != nil {
+		return nil, err
+	}
+	if registryName != "" {
+		if !contains(config.registryNames, registryName) {
+			return nil, newCodedError(ErrCodeNotFound, "registry '%s' not found in registries.json", registryName)
+		}
+		config.registryNames = []string{registryName}
+	}
+	return config, nil
+}
+
+// collectPackageInfo loads the versions of a package in a registry and the details of the
+// selected version
+func collectPackageInfo(config *infoConfig, registryName string, pkgInfo types.PackageInfo) (packageInfoResult, error) {
+	info := packageInfoResult{
+		Name:       config.packageName,
+		UUID:       pkgInfo.UUID,
+		GitURL:     pkgInfo.GitURL,
+		Registry:   registryName,
+		Versions:   []versionInfo{},
+		Deps:       []dependencyInfo{},
+		BuildList:  []dependencyInfo{},
+		Dependents: []registryDependent{},
+	}
+	versions, err := loadVersions(config.registriesDir, registryName, config.packageName)
+	if err != nil {
+		return info, err
+	}
+	yanked, err := loadYankedVersions(config.registriesDir, registryName, config.packageName)
+	if err != nil {
+		return info, err
+	}
+	sortVersions(versions)
+	var candidates, yankedCandidates []string
+	for _, version := range versions {
+		specs, err := loadSpecs(config.registriesDir, registryName, config.packageName, version)
+		if err != nil {
+			return info, fmt.Errorf("failed to load specs for '%s@%s' in registry '%s': %v", config.packageName, version, registryName, err)
+		}
+		v := versionInfo{Version: version, SHA1: specs.SHA1}
+		if yank, isYanked := yanked[version]; isYanked {
+			v.Yanked = &yank
+		}
+		info.Versions = append(info.Versions, v)
+		if config.query != nil && !config.query.matches(version) {
+			continue
+		}
+		if v.Yanked != nil {
+			yankedCandidates = append(yankedCandidates, version)
+		} else {
+			candidates = append(candidates, version)
+		}
+	}
+	// Yanked versions are only shown in detail if no other version matches
+	if len(candidates) == 0 {
+		candidates = yankedCandidates
+	}
+	info.Selected, err = determineLatestVersion(candidates)
+	if err != nil {
+		return info, err
+	}
+	if info.Selected == "" {
+		if config.query != nil {
+			return info, newCodedError(ErrCodeNotFound, "no version of package '%s' matching '%s' found in registry '%s'", config.packageName, config.versionQuery, registryName)
+		}
+		return info, nil // No version registered yet
+	}
+
+	specs, err := loadSpecs(config.registriesDir, registryName, config.packageName, info.Selected)
+	if err != nil {
+		return info, fmt.Errorf("failed to load specs for '%s@%s' in registry '%s': %v", config.packageName, info.Selected, registryName, err)
+	}
+	for _, key := range sortedDependencyKeys(specs.Deps) {
+		dep := specs.Deps[key]
+		depUUID, _ := extractUUIDFromKey(key)
+		info.Deps = append(info.Deps, dependencyInfo{Name: dep.Name, UUID: depUUID, Version: dep.Version})
+	}
+	buildList, err := loadBuildList(config.registriesDir, registryName, config.packageName, info.Selected)
+	if err != nil {
+		return info, err
+	}
+	for _, key := range sortedBuildListKeys(buildList) {
+		dep := buildList.Dependencies[key]
+		info.BuildList = append(info.BuildList, dependencyInfo{Name: dep.Name, UUID: dep.UUID, Version: dep.Version, SHA1: dep.SHA1})
+	}
+	index, err := loadDependentsIndex(config.registriesDir, registryName)
+	if err != nil {
+		return info, err
+	}
+	info.Dependents = index.dependentsOf(config.packageName, pkgInfo.UUID, info.Selected)
+	return info, nil
+}
+
+// printPackageInfo prints the registry metadata of a package
+func printPackageInfo(info packageInfoResult) {
+	fmt.Fprintf(messages, "Package '%s' in registry '%s'\n", info.Name, info.Registry)
+	fmt.Fprintf(messages, "  UUID:   %s\n", info.UUID)
+	fmt.Fprintf(messages, "  GitURL: %s\n", info.GitURL)
+	fmt.Fprintln(messages, "  Versions:")
+	if len(info.Versions) == 0 {
+		fmt.Fprintln(messages, "    (none)")
+		return
+	}
+	for _, v := range info.Versions {
+		marker := ""
+		if v.Yanked != nil && v.Yanked.Reason != "" {
+			marker = fmt.Sprintf(" (yanked: %s)", v.Yanked.Reason)
+		} else if v.Yanked != nil {
+			marker = " (yanked)"
+		}
+		if v.Version == info.Selected {
+			marker += " (selected)"
+		}
+		fmt.Fprintf(messages, "    %s %s%s\n", v.Version, v.SHA1, marker)
+	}
+	fmt.Fprintf(messages, "  Dependencies of %s:\n", info.Selected)
+	printDependencyInfos(info.Deps, false)
+	fmt.Fprintf(messages, "  Build list of %s:\n", info.Selected)
+	printDependencyInfos(info.BuildList, true)
+	fmt.Fprintf(messages, "  Dependents of %s in registry '%s':\n", info.Selected, info.Registry)
+	printRegistryDependents(info.Dependents, "    ")
+}
+
+// printDependencyInfos prints requirements or build list entries, optionally with their SHA1
+func printDependencyInfos(deps []dependencyInfo, withSHA1 bool) {
+	if len(deps) == 0 {
+		fmt.Fprintln(messages, "    (none)")
+	}
+	for _, dep := range deps {
+		if withSHA1 {
+			fmt.Fprintf(messages, "    %s %s %s\n", dep.Name, dep.Version, dep.SHA1)
+		} else {
+			fmt.Fprintf(messages, "    %s %s\n", dep.Name, dep.Version)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"cosm/types"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,7 +29,7 @@ func initWithoutTemplate(cmd *cobra.Command, args []string) error {
 	language := getInitLanguageFlag(cmd)
 	if version != "" {
 		if err := validateVersion(version); err != nil {
-			return err
+			return withCode(ErrCodeInvalidArgs, err)
 		}
 	}
 	projectUUID := uuid.New().String()
@@ -37,24 +38,40 @@ func initWithoutTemplate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	if err := ensureProjectFileDoesNotExist("Project.json"); err != nil {
-		return err
+		return withCode(ErrCodeAlreadyExists, err)
 	}
 	project := createProject(packageName, projectUUID, authors, language, version)
 	if err := saveProject(&project, "Project.json"); err != nil {
 		return err
 	}
-	fmt.Printf("Initialized project '%s' with version %s\n", packageName, version)
-	return nil
+	fmt.Fprintf(messages, "Initialized project '%s' with version %s\n", packageName, version)
+	return printJSONResult(newInitResult(project, "Project.json"))
+}
+
+// initResult is the JSON document printed by cosm init --output json
+type initResult struct {
+	Project     projectResult `json:"project"`
+	Language    string        `json:"language,omitempty"`
+	ProjectFile string        `json:"project_file"`
+}
+
+// newInitResult describes an initialized project
+func newInitResult(project types.Project, projectFile string) initResult {
+	return initResult{
+		Project:     projectResult{Name: project.Name, UUID: project.UUID, Version: project.Version},
+		Language:    project.Language,
+		ProjectFile: projectFile,
+	}
 }
 
 // validateInitArgs checks the command-line arguments for validity
 func validateInitArgsWithoutTemplate(args []string, cmd *cobra.Command) (string, string, error) {
 	if len(args) < 1 || len(args) > 2 {
-		return "", "", fmt.Errorf("one or two arguments required (e.g., cosm init <package-name> [version])")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "one or two arguments required (e.g., cosm init <package-name> [version])")
 	}
 	packageName := args[0]
 	if packageName == "" {
-		return "", "", fmt.Errorf("package name cannot be empty")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
 	}
 
 	// Check version from args or flag
@@ -64,7 +81,7 @@ func validateInitArgsWithoutTemplate(args []string, cmd *cobra.Command) (string,
 	}
 	flagVersion, _ := cmd.Flags().GetString("version")
 	if version != "" && flagVersion != "" {
-		return "", "", fmt.Errorf("cannot specify version both as an argument and a flag")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "cannot specify version both as an argument and a flag")
 	}
 	if version == "" {
 		version = flagVersion
@@ -91,7 +108,9 @@ func initWithTemplate(cmd *cobra.Command, args []string, templatePath string) er
 
 	// Create project directory
 	projectDir := packageName
-	if err := os.Mkdir(projectDir, 0755); err != nil {
+	if err := os.Mkdir(projectDir, 0755); os.IsExist(err) {
+		return newCodedError(ErrCodeAlreadyExists, "project directory %s already exists", projectDir)
+	} else if err != nil {
 		return fmt.Errorf("failed to create project directory %s: %v", projectDir, err)
 	}
 
@@ -109,7 +128,7 @@ func initWithTemplate(cmd *cobra.Command, args []string, templatePath string) er
 	}
 	projectFile := filepath.Join(projectDir, "Project.json")
 	if err := ensureProjectFileDoesNotExist(projectFile); err != nil {
-		return err
+		return withCode(ErrCodeAlreadyExists, err)
 	}
 	project := createProject(packageName, projectUUID, authors, language, version)
 	if err := saveProject(&project, projectFile); err != nil {
@@ -121,18 +140,18 @@ func initWithTemplate(cmd *cobra.Command, args []string, templatePath string) er
 		return fmt.Errorf("failed to initialize git repository: %v", err)
 	}
 
-	fmt.Printf("Initialized project '%s' with version %s in %s\n", packageName, version, projectDir)
-	return nil
+	fmt.Fprintf(messages, "Initialized project '%s' with version %s in %s\n", packageName, version, projectDir)
+	return printJSONResult(newInitResult(project, projectFile))
 }
 
 // validateInitArgsWithTemplate checks the command-line arguments and flags for template mode
 func validateInitArgsWithTemplate(args []string, cmd *cobra.Command) (string, string, error) {
 	if len(args) < 1 || len(args) > 2 {
-		return "", "", fmt.Errorf("one or two arguments required (e.g., cosm init <package-name> [version])")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "one or two arguments required (e.g., cosm init <package-name> [version])")
 	}
 	packageName := args[0]
 	if packageName == "" {
-		return "", "", fmt.Errorf("package name cannot be empty")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
 	}
 
 	// Check version from args or flag
@@ -142,7 +161,7 @@ func validateInitArgsWithTemplate(args []string, cmd *cobra.Command) (string, st
 	}
 	flagVersion, _ := cmd.Flags().GetString("version")
 	if version != "" && flagVersion != "" {
-		return "", "", fmt.Errorf("cannot specify version both as an argument and a flag")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "cannot specify version both as an argument and a flag")
 	}
 	if version == "" {
 		version = flagVersion
@@ -152,13 +171,13 @@ func validateInitArgsWithTemplate(args []string, cmd *cobra.Command) (string, st
 	}
 	if version != "" {
 		if err := validateVersion(version); err != nil {
-			return "", "", err
+			return "", "", withCode(ErrCodeInvalidArgs, err)
 		}
 	}
 
 	// Disallow --language with --template
 	if language, _ := cmd.Flags().GetString("language"); language != "" {
-		return "", "", fmt.Errorf("cannot specify --language when using --template")
+		return "", "", newCodedError(ErrCodeInvalidArgs, "cannot specify --language when using --template")
 	}
 
 	// Validate template path
@@ -169,12 +188,12 @@ func validateInitArgsWithTemplate(args []string, cmd *cobra.Command) (string, st
 	}
 	templateFullPath := filepath.Join(cosmDir, "templates", templatePath)
 	if _, err := os.Stat(templateFullPath); os.IsNotExist(err) {
-		return "", "", fmt.Errorf("template directory %s does not exist", templateFullPath)
+		return "", "", newCodedError(ErrCodeNotFound, "template directory %s does not exist", templateFullPath)
 	}
 	// Validate template path starts with <language>/
 	parts := strings.Split(templatePath, string(filepath.Separator))
 	if len(parts) < 2 {
-		return "", "", fmt.Errorf("template path %s must start with <language>/", templatePath)
+		return "", "", newCodedError(ErrCodeInvalidArgs, "template path %s must start with <language>/", templatePath)
 	}
 
 	return packageName, version, nil
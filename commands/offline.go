@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"os"
+	"strings"
+)
+
+// offlineMode is set by the global --offline flag or the COSM_OFFLINE environment variable. In
+// offline mode no Git remote is contacted: registries and clones are used as they are locally.
+var offlineMode = offlineFromEnv()
+
+// offlineFromEnv reports whether COSM_OFFLINE is set to a value other than 0, false or no
+func offlineFromEnv() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("COSM_OFFLINE"))) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// SetOffline enables offline mode; it cannot be disabled if COSM_OFFLINE is set
+func SetOffline(offline bool) {
+	if offline {
+		offlineMode = true
+	}
+}
+
+// Offline reports whether offline mode is enabled
+func Offline() bool {
+	return offlineMode
+}
+
+// requireOnline returns an error describing the action that is impossible in offline mode
+func requireOnline(action string) error {
+	if !offlineMode {
+		return nil
+	}
+	return newCodedError(ErrCodeOffline, "cannot %s in offline mode (--offline or COSM_OFFLINE is set)", action)
+}
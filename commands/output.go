@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Error codes reported with --output json, each with its own exit code
+const (
+	ErrCodeGeneral       = "error"
+	ErrCodeInvalidArgs   = "invalid_argument"
+	ErrCodeNotFound      = "not_found"
+	ErrCodeAlreadyExists = "already_exists"
+	ErrCodeGit           = "git_failed"
+	ErrCodeVerification  = "verification_failed"
+	ErrCodeOffline       = "offline" // a Git remote is needed while offline mode is enabled
+	ErrCodeHasDependents = "has_dependents"
+)
+
+// errorExitCodes maps error codes to process exit codes
+var errorExitCodes = map[string]int{
+	ErrCodeGeneral:       1,
+	ErrCodeInvalidArgs:   2,
+	ErrCodeNotFound:      3,
+	ErrCodeAlreadyExists: 4,
+	ErrCodeGit:           5,
+	ErrCodeVerification:  6,
+	ErrCodeOffline:       7,
+	ErrCodeHasDependents: 8,
+}
+
+// jsonOutput is set by the global --output json flag
+var jsonOutput bool
+
+// jsonWriter receives the JSON document of --output json
+var jsonWriter io.Writer = os.Stdout
+
+// messages receives human-readable output: stdout in text mode, stderr with JSON output or while
+// stdout is reserved for other programs. It is only changed through redirectMessages.
+var messages io.Writer = os.Stdout
+
+// CommandError is an error with a stable code for machine-readable output
+type CommandError struct {
+	Code    string
+	Err     error
+	Details interface{} // reported as "details" with --output json if set
+}
+
+// Error returns the message of the underlying error
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// ExitStatusError reports that the command started by cosm run failed; cosm exits with the same
+// status
+type ExitStatusError struct {
+	Command string
+	Status  int
+}
+
+// Error describes the exit status of the command
+func (e *ExitStatusError) Error() string {
+	return fmt.Sprintf("'%s' exited with status %d", e.Command, e.Status)
+}
+
+// withCode attaches an error code to err, keeping any code already attached
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return err
+	}
+	return &CommandError{Code: code, Err: err}
+}
+
+// newCodedError creates a formatted error with an error code
+func newCodedError(code, format string, args ...interface{}) error {
+	return &CommandError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// SetOutputFormat selects text or JSON output. With JSON output, human-readable messages are
+// written to stderr so that stdout carries a single JSON document. The returned function restores
+// text output.
+func SetOutputFormat(format string) (restore func(), err error) {
+	switch format {
+	case "text":
+		return func() {}, nil
+	case "json":
+		jsonOutput = true
+		restoreMessages := redirectMessages(os.Stderr)
+		return func() {
+			jsonOutput = false
+			restoreMessages()
+		}, nil
+	}
+	return nil, newCodedError(ErrCodeInvalidArgs, "unsupported output format '%s' (use text or json)", format)
+}
+
+// OutputFormatArg returns the value of the --output flag in the command line arguments. It is
+// read before Cobra parses the arguments so that usage errors are reported in the selected format.
+func OutputFormatArg(args []string) string {
+	format := "text"
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return format // The remaining arguments belong to the command of cosm run
+		case arg == "--output" && i+1 < len(args):
+			format = args[i+1]
+		case strings.HasPrefix(arg, "--output="):
+			format = strings.TrimPrefix(arg, "--output=")
+		}
+	}
+	return format
+}
+
+// InvalidArgs marks a usage error detected by Cobra, such as a wrong number of arguments or an
+// unknown flag, as an invalid argument
+func InvalidArgs(err error) error {
+	return withCode(ErrCodeInvalidArgs, err)
+}
+
+// redirectMessages sends human-readable messages to w until the returned function is called,
+// e.g. to stderr for commands whose stdout is consumed by other programs
+func redirectMessages(w io.Writer) (restore func()) {
+	previous := messages
+	messages = w
+	return func() { messages = previous }
+}
+
+// Messages returns the writer for human-readable output
+func Messages() io.Writer {
+	return messages
+}
+
+// JSONOutput reports whether JSON output was selected
+func JSONOutput() bool {
+	return jsonOutput
+}
+
+// printJSONResult writes the result of a command as a JSON document when JSON output is selected
+func printJSONResult(result interface{}) error {
+	if !jsonOutput {
+		return nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %v", err)
+	}
+	_, err = fmt.Fprintln(jsonWriter, string(data))
+	return err
+}
+
+// HandleError reports a command error and returns the exit code. With JSON output the error is
+// written as {"error": {"code": ..., "message": ...}} and the exit code depends on the code;
+// otherwise the error has already been printed and the exit code is 1. The exit status of the
+// command of cosm run is always propagated.
+func HandleError(err error) int {
+	if !jsonOutput {
+		var statusErr *ExitStatusError
+		if errors.As(err, &statusErr) {
+			return statusErr.Status
+		}
+		return 1
+	}
+	errDoc := map[string]interface{}{"code": ErrCodeGeneral, "message": err.Error()}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		errDoc["code"] = cmdErr.Code
+		if cmdErr.Details != nil {
+			errDoc["details"] = cmdErr.Details
+		}
+	}
+	doc := map[string]interface{}{"error": errDoc}
+	if printErr := printJSONResult(doc); printErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return ExitCode(err)
+}
+
+// ExitCode returns the exit code for the error code of err, the exit status of the command of
+// cosm run, or 1 for errors without a code
+func ExitCode(err error) int {
+	var statusErr *ExitStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		if exitCode, ok := errorExitCodes[cmdErr.Code]; ok {
+			return exitCode
+		}
+	}
+	return 1
+}
@@ -32,13 +32,21 @@ func RegistryAdd(cmd *cobra.Command, args []string) error {
 	// Parse arguments and setup
 	config, err := parseRegistryAddArgs(args)
 	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	if err := requireOnline(fmt.Sprintf("add packages to registry '%s'", config.registryName)); err != nil {
 		return err
 	}
 
-	// Update registry
-	if err := updateSingleRegistry(config.registriesDir, config.registryName); err != nil {
+	// Lock and update registry
+	unlock, err := lockRegistry(config.registriesDir, config.registryName)
+	if err != nil {
 		return err
 	}
+	defer unlock()
+	if err := syncRegistry(config.registriesDir, config.registryName); err != nil {
+		return withCode(ErrCodeGit, err)
+	}
 
 	// Load registry metadata
 	config.registry, config.registryFile, err = LoadRegistryMetadata(config.registriesDir, config.registryName)
@@ -149,7 +157,18 @@ func addPackageWithAllVersions(config *addPackageConfig) error {
 	if err := saveRegistryMetadata(config.registry, config.registryFile); err != nil {
 		return err
 	}
+	if err := writeSearchIndex(config.registriesDir, config.registryName); err != nil {
+		return err
+	}
+	if err := updateDependentsIndex(config.registriesDir, config.registryName, config.packageName, config.tags); err != nil {
+		return err
+	}
+	unlockClone, err := lockClone(config.cosmDir, config.packageUUID)
+	if err != nil {
+		return err
+	}
 	config.clonePath, err = moveCloneToPermanentDir(config.cosmDir, config.clonePath, config.packageUUID)
+	unlockClone()
 	if err != nil {
 		return err
 	}
@@ -158,10 +177,10 @@ func addPackageWithAllVersions(config *addPackageConfig) error {
 		commitMsg = fmt.Sprintf("Added package %s version %s", config.packageName, config.tags[0])
 	}
 	if err := commitAndPushRegistryChanges(config.registriesDir, config.registryName, commitMsg); err != nil {
-		return err
+		return withCode(ErrCodeGit, err)
 	}
-	fmt.Printf("Added package '%s' to registry '%s'\n", config.packageName, config.registryName)
-	return nil
+	fmt.Fprintf(messages, "Added package '%s' to registry '%s'\n", config.packageName, config.registryName)
+	return printJSONResult(newRegistryChangeResult(config, "added", config.tags))
 }
 
 // addSpecificPackageVersion adds a specific version of an existing package to the registry
@@ -169,7 +188,7 @@ func addSpecificPackageVersion(config *addPackageConfig) error {
 	// Check if package exists in registry
 	pkgInfo, exists := config.registry.Packages[config.packageName]
 	if !exists {
-		return fmt.Errorf("package '%s' not found in registry '%s'", config.packageName, config.registryName)
+		return newCodedError(ErrCodeNotFound, "package '%s' not found in registry '%s'", config.packageName, config.registryName)
 	}
 	config.packageUUID = pkgInfo.UUID
 	config.packageGitURL = pkgInfo.GitURL
@@ -177,19 +196,20 @@ func addSpecificPackageVersion(config *addPackageConfig) error {
 	// Check if version is already registered
 	config.packageDir = filepath.Join(config.registriesDir, config.registryName, strings.ToUpper(string(config.packageName[0])), config.packageName)
 	versionsFile := filepath.Join(config.packageDir, "versions.json")
-	var existingVersions []string
-	if data, err := os.ReadFile(versionsFile); err == nil {
-		if err := json.Unmarshal(data, &existingVersions); err != nil {
-			return fmt.Errorf("failed to parse versions.json for package '%s': %v", config.packageName, err)
-		}
+	if existingVersions, _, err := loadVersionsFile(versionsFile); err == nil {
 		if contains(existingVersions, config.versionTag) {
-			return fmt.Errorf("version '%s' of package '%s' is already registered in registry '%s'", config.versionTag, config.packageName, config.registryName)
+			return newCodedError(ErrCodeAlreadyExists, "version '%s' of package '%s' is already registered in registry '%s'", config.versionTag, config.packageName, config.registryName)
 		}
 	} else if !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read versions.json for package '%s': %v", config.packageName, err)
 	}
 
-	// Check if package is cloned
+	// Check if package is cloned; the clone is locked while its checkout changes
+	unlockClone, err := lockClone(config.cosmDir, config.packageUUID)
+	if err != nil {
+		return err
+	}
+	defer unlockClone()
 	config.clonePath = filepath.Join(config.cosmDir, "clones", config.packageUUID)
 	if _, err := os.Stat(config.clonePath); os.IsNotExist(err) {
 		tmpClonePath, err := clonePackageToTempDir(config.cosmDir, config.packageGitURL)
@@ -209,15 +229,47 @@ func addSpecificPackageVersion(config *addPackageConfig) error {
 	if err := updatePackageVersions(config.packageDir, config.packageName, config.packageUUID, config.packageGitURL, []string{config.versionTag}, config.registriesDir, config.clonePath); err != nil {
 		return err
 	}
+	if err := writeSearchIndex(config.registriesDir, config.registryName); err != nil {
+		return err
+	}
+	if err := updateDependentsIndex(config.registriesDir, config.registryName, config.packageName, []string{config.versionTag}); err != nil {
+		return err
+	}
 
 	// Commit and push registry changes
 	commitMsg := fmt.Sprintf("Added version %s of package %s", config.versionTag, config.packageName)
 	if err := commitAndPushRegistryChanges(config.registriesDir, config.registryName, commitMsg); err != nil {
-		return err
+		return withCode(ErrCodeGit, err)
 	}
 
-	fmt.Printf("Added version '%s' of package '%s' to registry '%s'\n", config.versionTag, config.packageName, config.registryName)
-	return nil
+	fmt.Fprintf(messages, "Added version '%s' of package '%s' to registry '%s'\n", config.versionTag, config.packageName, config.registryName)
+	return printJSONResult(newRegistryChangeResult(config, "added", []string{config.versionTag}))
+}
+
+// registryChangeResult is the JSON document printed when a package or its versions are added to or
+// removed from a registry
+type registryChangeResult struct {
+	Action   string   `json:"action"`
+	Registry string   `json:"registry"`
+	Package  string   `json:"package"`
+	UUID     string   `json:"uuid,omitempty"`
+	GitURL   string   `json:"giturl,omitempty"`
+	Versions []string `json:"versions"`
+}
+
+// newRegistryChangeResult builds the JSON document for a package added to a registry
+func newRegistryChangeResult(config *addPackageConfig, action string, versions []string) registryChangeResult {
+	if versions == nil {
+		versions = []string{}
+	}
+	return registryChangeResult{
+		Action:   action,
+		Registry: config.registryName,
+		Package:  config.packageName,
+		UUID:     config.packageUUID,
+		GitURL:   config.packageGitURL,
+		Versions: versions,
+	}
 }
 
 // ensurePackageNotRegistered checks if the package is already in the registry
@@ -225,9 +277,9 @@ func ensurePackageNotRegistered(registry types.Registry, packageName, registryNa
 	if _, exists := registry.Packages[packageName]; exists {
 		cleanupErr := cleanupTempClone(tmpClonePath)
 		if cleanupErr != nil {
-			return fmt.Errorf("package '%s' is already registered in registry '%s'; cleanup failed: %v", packageName, registryName, cleanupErr)
+			return newCodedError(ErrCodeAlreadyExists, "package '%s' is already registered in registry '%s'; cleanup failed: %v", packageName, registryName, cleanupErr)
 		}
-		return fmt.Errorf("package '%s' is already registered in registry '%s'", packageName, registryName)
+		return newCodedError(ErrCodeAlreadyExists, "package '%s' is already registered in registry '%s'", packageName, registryName)
 	}
 	return nil
 }
@@ -252,7 +304,8 @@ func moveCloneToPermanentDir(cosmDir, tmpClonePath, packageUUID string) (string,
 	return packageClonePath, nil
 }
 
-// validateAndCollectVersionTags fetches Git tags, or returns empty slice if none exist
+// validateAndCollectVersionTags fetches Git tags that are valid semantic versions, sorted by
+// precedence, or returns empty slice if none exist
 func validateAndCollectVersionTags(clonePath string) ([]string, error) {
 	tagOutput, err := GitCommand(clonePath, "tag")
 	if err != nil || len(strings.TrimSpace(tagOutput)) == 0 {
@@ -262,10 +315,15 @@ func validateAndCollectVersionTags(clonePath string) ([]string, error) {
 	tags := strings.Split(strings.TrimSpace(tagOutput), "\n")
 	var validTags []string
 	for _, tag := range tags {
-		if strings.HasPrefix(tag, "v") && len(strings.Split(tag, ".")) >= 2 {
-			validTags = append(validTags, tag)
+		if !strings.HasPrefix(tag, "v") {
+			continue
 		}
+		if _, err := ParseSemVer(tag); err != nil {
+			continue
+		}
+		validTags = append(validTags, tag)
 	}
+	sortVersions(validTags)
 	return validTags, nil
 }
 
@@ -282,12 +340,10 @@ func setupPackageDir(registriesDir, registryName, packageName string) (string, e
 // updatePackageVersions updates versions.json with the specified tags
 func updatePackageVersions(packageDir, packageName, packageUUID, packageGitURL string, tags []string, registriesDir, clonePath string) error {
 	versionsFile := filepath.Join(packageDir, "versions.json")
-	var versions []string
-	if data, err := os.ReadFile(versionsFile); err == nil {
-		if err := json.Unmarshal(data, &versions); err != nil {
-			return fmt.Errorf("failed to parse versions.json for package '%s': %v", packageName, err)
-		}
-	} else if !os.IsNotExist(err) {
+	versions, yanked, err := loadVersionsFile(versionsFile)
+	if os.IsNotExist(err) {
+		versions, yanked = nil, nil
+	} else if err != nil {
 		return fmt.Errorf("failed to read versions.json for package '%s': %v", packageName, err)
 	}
 
@@ -299,13 +355,8 @@ func updatePackageVersions(packageDir, packageName, packageUUID, packageGitURL s
 				return fmt.Errorf("failed to fetch remote changes for package '%s': %v", packageName, err)
 			}
 
-			// Checkout the specific version tag
-			if err := checkoutVersion(clonePath, tag); err != nil {
-				return fmt.Errorf("failed to checkout tag '%s' for package '%s': %v", tag, packageName, err)
-			}
-
 			// Load Project.json for this tag
-			project, err := loadProjectFromDir(clonePath)
+			project, err := loadProjectAtRef(clonePath, tag)
 			if err != nil {
 				return fmt.Errorf("failed to load Project.json for tag '%s': %v", tag, err)
 			}
@@ -315,11 +366,6 @@ func updatePackageVersions(packageDir, packageName, packageUUID, packageGitURL s
 				return fmt.Errorf("invalid Project.json for tag '%s': %v", tag, err)
 			}
 
-			// Revert clone to previous state
-			if err := revertClone(clonePath); err != nil {
-				return fmt.Errorf("failed to revert clone for tag '%s': %v", tag, err)
-			}
-
 			// Get SHA1 for the tag
 			sha1Output, err := GitCommand(clonePath, "rev-list", "-n", "1", tag)
 			if err != nil {
@@ -327,8 +373,14 @@ func updatePackageVersions(packageDir, packageName, packageUUID, packageGitURL s
 			}
 			sha1 := strings.TrimSpace(sha1Output)
 
+			// Hash the files of the tag for integrity checks of materialized packages
+			treeHash, err := hashCommitTree(clonePath, sha1)
+			if err != nil {
+				return fmt.Errorf("failed to hash files of tag '%s': %v", tag, err)
+			}
+
 			// Add the version using the project data for this tag
-			if err := addPackageVersion(packageDir, packageName, packageUUID, packageGitURL, sha1, tag, project, registriesDir); err != nil {
+			if err := addPackageVersion(packageDir, packageName, packageUUID, packageGitURL, sha1, treeHash, tag, project, registriesDir); err != nil {
 				return err
 			}
 
@@ -337,31 +389,36 @@ func updatePackageVersions(packageDir, packageName, packageUUID, packageGitURL s
 	}
 
 	// Write updated versions.json
-	data, err := json.MarshalIndent(versions, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal versions.json for package '%s': %v", packageName, err)
-	}
-	if err := os.WriteFile(versionsFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write versions.json for package '%s': %v", packageName, err)
+	if err := savePackageVersions(versions, yanked, versionsFile); err != nil {
+		return fmt.Errorf("failed to save versions.json for package '%s': %v", packageName, err)
 	}
-
 	return nil
 }
 
 // addPackageVersion adds a single version to the registry package directory
-func addPackageVersion(packageDir, packageName, packageUUID, packageGitURL, sha1, versionTag string, project *types.Project, registriesDir string) error {
+func addPackageVersion(packageDir, packageName, packageUUID, packageGitURL, sha1, treeHash, versionTag string, project *types.Project, registriesDir string) error {
 	versionDir := filepath.Join(packageDir, versionTag)
 	if err := os.MkdirAll(versionDir, 0755); err != nil {
 		return fmt.Errorf("failed to create version directory %s: %v", versionDir, err)
 	}
 
+	// Development mode is local to a project and never part of a registered version
+	for key, dep := range project.Deps {
+		dep.Develop = false
+		project.Deps[key] = dep
+	}
+
 	specs := types.Specs{
-		Name:    packageName,
-		UUID:    packageUUID,
-		Version: versionTag,
-		GitURL:  packageGitURL,
-		SHA1:    sha1,
-		Deps:    project.Deps,
+		Name:        packageName,
+		UUID:        packageUUID,
+		Version:     versionTag,
+		GitURL:      packageGitURL,
+		SHA1:        sha1,
+		TreeHash:    treeHash,
+		Deps:        project.Deps,
+		Description: project.Description,
+		Keywords:    project.Keywords,
+		Language:    project.Language,
 	}
 	data, err := json.MarshalIndent(specs, "", "  ")
 	if err != nil {
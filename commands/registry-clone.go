@@ -16,11 +16,11 @@ import (
 func RegistryClone(cmd *cobra.Command, args []string) error {
 	// Validate and parse arguments
 	if len(args) != 1 {
-		return fmt.Errorf("exactly one argument required (e.g., cosm registry clone <giturl>)")
+		return newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm registry clone <giturl>)")
 	}
 	gitURL := args[0]
 	if gitURL == "" {
-		return fmt.Errorf("git URL cannot be empty")
+		return newCodedError(ErrCodeInvalidArgs, "git URL cannot be empty")
 	}
 
 	// Initialize paths
@@ -33,12 +33,15 @@ func RegistryClone(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create registries directory %s: %v", registriesDir, err)
 	}
 
-	// Step 1: Clone to temporary folder
-	tmpDir := filepath.Join(registriesDir, "tmp-registry-clone")
-	if err := cloneToTempRegistryDir(gitURL, registriesDir, tmpDir); err != nil {
-		return err
+	// Step 1: Clone to a uniquely named temporary folder
+	tmpDir, err := os.MkdirTemp(registriesDir, "tmp-registry-clone-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory in %s: %v", registriesDir, err)
 	}
 	defer os.RemoveAll(tmpDir) // Ensure cleanup
+	if err := cloneToTempRegistryDir(gitURL, registriesDir, tmpDir); err != nil {
+		return withCode(ErrCodeGit, err)
+	}
 
 	// Step 2: Extract registry name
 	registryName, err := extractRegistryName(tmpDir)
@@ -46,7 +49,12 @@ func RegistryClone(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Step 3: Check if registry name exists
+	// Step 3: Check if registry name exists, holding the lock on registries.json until it is updated
+	unlock, err := lockRegistriesList(cosmDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := checkRegistryNameDoesNotExist(registriesDir, registryName); err != nil {
 		return err
 	}
@@ -63,17 +71,14 @@ func RegistryClone(cmd *cobra.Command, args []string) error {
 	}
 
 	// Step 6: Cleanup handled by defer
-	fmt.Printf("Cloned registry '%s' from %s\n", registryName, gitURL)
-	return nil
+	fmt.Fprintf(messages, "Cloned registry '%s' from %s\n", registryName, gitURL)
+	return printJSONResult(registryResult{Action: "cloned", Registry: registryName, GitURL: gitURL})
 }
 
-// cloneToTempRegistryDir clones the repository to a temporary directory
+// cloneToTempRegistryDir clones the repository to an empty temporary directory
 func cloneToTempRegistryDir(gitURL, registriesDir, tmpDir string) error {
-	if err := os.RemoveAll(tmpDir); err != nil {
-		return fmt.Errorf("failed to remove existing temporary directory %s: %v", tmpDir, err)
-	}
-	if _, err := clone(gitURL, registriesDir, "tmp-registry-clone"); err != nil {
-		return fmt.Errorf("failed to clone repository from '%s' to %s: %v", gitURL, tmpDir, err)
+	if _, err := clone(gitURL, registriesDir, filepath.Base(tmpDir)); err != nil {
+		return fmt.Errorf("failed to clone repository from '%s' to %s: %w", gitURL, tmpDir, err)
 	}
 	return nil
 }
@@ -106,7 +111,7 @@ func checkRegistryNameDoesNotExist(registriesDir, registryName string) error {
 	}
 	for _, name := range registryNames {
 		if name == registryName {
-			return fmt.Errorf("registry '%s' already exists in registries.json", registryName)
+			return newCodedError(ErrCodeAlreadyExists, "registry '%s' already exists in registries.json", registryName)
 		}
 	}
 	return nil
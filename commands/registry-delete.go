@@ -28,7 +28,17 @@ func RegistryDelete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Load existing registry names
+	// Lock and load existing registry names
+	unlock, err := lockRegistriesList(filepath.Dir(config.registriesDir))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	unlockRegistry, err := lockRegistry(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+	defer unlockRegistry()
 	config.registryNames, err = loadRegistryNames(config.registriesDir)
 	if err != nil {
 		if !os.IsNotExist(err) && !strings.Contains(err.Error(), "no registries available") {
@@ -52,18 +62,18 @@ func RegistryDelete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Deleted registry '%s'\n", config.registryName)
-	return nil
+	fmt.Fprintf(messages, "Deleted registry '%s'\n", config.registryName)
+	return printJSONResult(registryResult{Action: "deleted", Registry: config.registryName})
 }
 
 // parseDeleteArgs parses and validates the registry name argument
 func parseDeleteArgs(cmd *cobra.Command, args []string) (*deleteRegistryConfig, error) {
 	if len(args) != 1 {
-		return nil, fmt.Errorf("exactly one argument required (e.g., cosm registry delete <registryName>)")
+		return nil, newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm registry delete <registryName>)")
 	}
 	registryName := args[0]
 	if registryName == "" {
-		return nil, fmt.Errorf("registry name cannot be empty")
+		return nil, newCodedError(ErrCodeInvalidArgs, "registry name cannot be empty")
 	}
 
 	cosmDir, err := getCosmDir()
@@ -95,7 +105,7 @@ func validateRegistryForDeletion(config *deleteRegistryConfig) error {
 		return err
 	}
 	if _, err := os.Stat(config.registryPath); os.IsNotExist(err) {
-		return fmt.Errorf("registry directory '%s' not found", config.registryPath)
+		return newCodedError(ErrCodeNotFound, "registry directory '%s' not found", config.registryPath)
 	}
 	return nil
 }
@@ -103,12 +113,12 @@ func validateRegistryForDeletion(config *deleteRegistryConfig) error {
 // promptForDeletion prompts the user for confirmation if not forced
 func promptForDeletion(config *deleteRegistryConfig) error {
 	if !config.force {
-		fmt.Printf("Are you sure you want to delete registry '%s'? [y/N]: ", config.registryName)
+		fmt.Fprintf(messages, "Are you sure you want to delete registry '%s'? [y/N]: ", config.registryName)
 		scanner := bufio.NewScanner(os.Stdin)
 		scanner.Scan()
 		response := strings.TrimSpace(strings.ToLower(scanner.Text()))
 		if response != "y" && response != "yes" {
-			fmt.Println("Registry deletion cancelled.")
+			fmt.Fprintln(messages, "Registry deletion cancelled.")
 			return nil
 		}
 	}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// registryDependentsResult is the JSON document printed by cosm registry dependents --output json
+type registryDependentsResult struct {
+	Registry string                    `json:"registry"`
+	Package  string                    `json:"package"`
+	Versions []versionDependentsResult `json:"versions"`
+}
+
+// versionDependentsResult lists the dependents of a single version
+type versionDependentsResult struct {
+	Version    string              `json:"version"`
+	Dependents []registryDependent `json:"dependents"`
+}
+
+// RegistryDependents lists the registered versions of a registry that require a version, or every
+// version, of a package
+func RegistryDependents(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return newCodedError(ErrCodeInvalidArgs, "requires registry name and package name, with optional version (e.g., cosm registry dependents <registry> <package> [<version>])")
+	}
+	registryName, packageName := args[0], args[1]
+	if len(args) == 3 && !strings.HasPrefix(args[2], "v") {
+		return newCodedError(ErrCodeInvalidArgs, "version must start with 'v' if provided")
+	}
+	registriesDir, err := getRegistriesDir()
+	if err != nil {
+		return err
+	}
+	if err := assertRegistryExists(registriesDir, registryName); err != nil {
+		return withCode(ErrCodeNotFound, err)
+	}
+	registry, _, err := LoadRegistryMetadata(registriesDir, registryName)
+	if err != nil {
+		return err
+	}
+	pkgInfo, exists := registry.Packages[packageName]
+	if !exists {
+		return newCodedError(ErrCodeNotFound, "package '%s' not found in registry '%s'", packageName, registryName)
+	}
+	versions, err := loadVersions(registriesDir, registryName, packageName)
+	if err != nil {
+		return err
+	}
+	if len(args) == 3 {
+		if !contains(versions, args[2]) {
+			return newCodedError(ErrCodeNotFound, "version '%s' not found for package '%s' in registry '%s'", args[2], packageName, registryName)
+		}
+		versions = []string{args[2]}
+	}
+	sortVersions(versions)
+
+	index, err := loadDependentsIndex(registriesDir, registryName)
+	if err != nil {
+		return err
+	}
+	result := registryDependentsResult{Registry: registryName, Package: packageName, Versions: []versionDependentsResult{}}
+	fmt.Fprintf(messages, "Dependents of '%s' in registry '%s':\n", packageName, registryName)
+	for _, version := range versions {
+		dependents := index.dependentsOf(packageName, pkgInfo.UUID, version)
+		fmt.Fprintf(messages, "  %s:\n", version)
+		printRegistryDependents(dependents, "    ")
+		result.Versions = append(result.Versions, versionDependentsResult{Version: version, Dependents: dependents})
+	}
+	return printJSONResult(result)
+}
+
+// printRegistryDependents prints dependents with the given indentation, marking whether they
+// require the version directly or through their build list
+func printRegistryDependents(dependents []registryDependent, indent string) {
+	if len(dependents) == 0 {
+		fmt.Fprintf(messages, "%s(none)\n", indent)
+	}
+	for _, dependent := range dependents {
+		kind := "build list"
+		if dependent.Direct {
+			kind = "direct"
+		}
+		fmt.Fprintf(messages, "%s%s %s (%s)\n", indent, dependent.Name, dependent.Version, kind)
+	}
+}
@@ -11,19 +11,31 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// registryResult is the JSON document printed by cosm registry init, clone and delete --output json
+type registryResult struct {
+	Action   string `json:"action"` // "initialized", "cloned" or "deleted"
+	Registry string `json:"registry"`
+	GitURL   string `json:"giturl,omitempty"`
+}
+
 // RegistryInit initializes a new package registry
 func RegistryInit(cmd *cobra.Command, args []string) error {
 	registryName, gitURL, registriesDir, err := setupAndParseInitArgs(args)
 	if err != nil {
 		return err
 	}
+	unlock, err := lockRegistriesList(filepath.Dir(registriesDir))
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	registryNames, err := loadAndCheckRegistries(registriesDir, registryName)
 	if err != nil {
 		return err
 	}
 	registrySubDir, err := cloneDir(registriesDir, registryName, gitURL)
 	if err != nil {
-		return err
+		return withCode(ErrCodeGit, err)
 	}
 	if err := ensureDirectoryEmpty(registrySubDir, gitURL); err != nil {
 		cleanupInit(registrySubDir)
@@ -40,25 +52,25 @@ func RegistryInit(cmd *cobra.Command, args []string) error {
 	}
 	if err := commitAndPushInitialRegistryChanges(registryName); err != nil {
 		cleanupInit(registrySubDir)
-		return err
+		return withCode(ErrCodeGit, err)
 	}
-	fmt.Printf("Initialized registry '%s' with Git URL: %s\n", registryName, gitURL)
-	return nil
+	fmt.Fprintf(messages, "Initialized registry '%s' with Git URL: %s\n", registryName, gitURL)
+	return printJSONResult(registryResult{Action: "initialized", Registry: registryName, GitURL: gitURL})
 }
 
 // setupAndParseInitArgs validates arguments and sets up directories for RegistryInit
 func setupAndParseInitArgs(args []string) (string, string, string, error) {
 	if len(args) != 2 {
-		return "", "", "", fmt.Errorf("exactly two arguments required (e.g., cosm registry init <registry name> <giturl>)")
+		return "", "", "", newCodedError(ErrCodeInvalidArgs, "exactly two arguments required (e.g., cosm registry init <registry name> <giturl>)")
 	}
 	registryName := args[0]
 	gitURL := args[1]
 
 	if registryName == "" {
-		return "", "", "", fmt.Errorf("registry name cannot be empty")
+		return "", "", "", newCodedError(ErrCodeInvalidArgs, "registry name cannot be empty")
 	}
 	if gitURL == "" {
-		return "", "", "", fmt.Errorf("git URL cannot be empty")
+		return "", "", "", newCodedError(ErrCodeInvalidArgs, "git URL cannot be empty")
 	}
 
 	cosmDir, err := getCosmDir()
@@ -88,7 +100,7 @@ func ensureDirectoryEmpty(dir, gitURL string) error {
 	}
 	for _, file := range files {
 		if file.Name() != ".git" { // Ignore .git directory
-			return fmt.Errorf("repository at '%s' cloned into %s is not empty (contains %s)", gitURL, dir, file.Name())
+			return newCodedError(ErrCodeInvalidArgs, "repository at '%s' cloned into %s is not empty (contains %s)", gitURL, dir, file.Name())
 		}
 	}
 	return nil
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"cosm/types"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,6 +21,7 @@ type rmRegistryConfig struct {
 	registryFile  string
 	packageDir    string
 	versionDir    string
+	dependents    dependentsIndex
 }
 
 // RegistryRm removes a package or a specific version from a registry
@@ -29,14 +29,27 @@ func RegistryRm(cmd *cobra.Command, args []string) error {
 	// Parse arguments and initialize config
 	config, err := parseRegistryRmArgs(cmd, args)
 	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	if err := requireOnline(fmt.Sprintf("remove packages from registry '%s'", config.registryName)); err != nil {
 		return err
 	}
 
-	// Validate registry and package
+	// Lock the registry, then validate registry and package
+	unlock, err := lockRegistry(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := validateRegistryAndPackage(config); err != nil {
 		return err
 	}
 
+	// Refuse to break registered versions that require the removed versions unless forced
+	if err := checkRemovalDependents(config); err != nil {
+		return err
+	}
+
 	// Prompt for confirmation if not forced
 	if err := promptForRm(config); err != nil {
 		return err
@@ -94,10 +107,10 @@ func parseRegistryRmArgs(cmd *cobra.Command, args []string) (*rmRegistryConfig,
 	return config, nil
 }
 
-// validateRegistryAndPackage updates the registry and validates the package and version
+// validateRegistryAndPackage updates the locked registry and validates the package and version
 func validateRegistryAndPackage(config *rmRegistryConfig) error {
-	if err := updateSingleRegistry(config.registriesDir, config.registryName); err != nil {
-		return fmt.Errorf("failed to update registry '%s': %v", config.registryName, err)
+	if err := syncRegistry(config.registriesDir, config.registryName); err != nil {
+		return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", config.registryName, err))
 	}
 
 	var err error
@@ -107,29 +120,65 @@ func validateRegistryAndPackage(config *rmRegistryConfig) error {
 	}
 
 	if _, exists := config.registry.Packages[config.packageName]; !exists {
-		return fmt.Errorf("package '%s' not found in registry '%s'", config.packageName, config.registryName)
+		return newCodedError(ErrCodeNotFound, "package '%s' not found in registry '%s'", config.packageName, config.registryName)
 	}
 
 	if config.versionTag != "" {
 		if _, err := os.Stat(config.versionDir); os.IsNotExist(err) {
-			return fmt.Errorf("version '%s' not found for package '%s' in registry '%s'", config.versionTag, config.packageName, config.registryName)
+			return newCodedError(ErrCodeNotFound, "version '%s' not found for package '%s' in registry '%s'", config.versionTag, config.packageName, config.registryName)
 		}
 		versionsFile := filepath.Join(config.packageDir, "versions.json")
-		var versions []string
-		data, err := os.ReadFile(versionsFile)
+		versions, _, err := loadVersionsFile(versionsFile)
 		if err != nil {
 			return fmt.Errorf("failed to read %s for package '%s': %v", versionsFile, config.packageName, err)
 		}
-		if err := json.Unmarshal(data, &versions); err != nil {
-			return fmt.Errorf("failed to parse %s for package '%s': %v", versionsFile, config.packageName, err)
-		}
 		if !contains(versions, config.versionTag) {
-			return fmt.Errorf("version '%s' not found in %s for package '%s'", config.versionTag, versionsFile, config.packageName)
+			return newCodedError(ErrCodeNotFound, "version '%s' not found in %s for package '%s'", config.versionTag, versionsFile, config.packageName)
 		}
 	}
 	return nil
 }
 
+// checkRemovalDependents loads the reverse-dependency index of the registry and refuses to remove
+// versions that other registered versions require, or only warns with --force
+func checkRemovalDependents(config *rmRegistryConfig) error {
+	var err error
+	config.dependents, err = loadDependentsIndex(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+	versions := []string{config.versionTag}
+	if config.versionTag == "" {
+		if versions, err = loadVersions(config.registriesDir, config.registryName, config.packageName); err != nil {
+			return err
+		}
+	}
+	packageUUID := config.registry.Packages[config.packageName].UUID
+	var dependents []registryDependent
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		for _, dependent := range config.dependents.dependentsOf(config.packageName, packageUUID, version) {
+			if !seen[dependent.String()] {
+				seen[dependent.String()] = true
+				dependents = append(dependents, dependent)
+			}
+		}
+	}
+	sortRegistryDependents(dependents)
+	if len(dependents) == 0 {
+		return nil
+	}
+	if config.force {
+		fmt.Fprintf(messages, "Warning: removing %s breaks %d registered versions: %s\n", getRemovalTarget(config), len(dependents), strings.Join(formatDependents(dependents), ", "))
+		return nil
+	}
+	return &CommandError{
+		Code:    ErrCodeHasDependents,
+		Err:     fmt.Errorf("cannot remove %s from registry '%s': required by %d registered versions: %s (use --force to remove it anyway)", getRemovalTarget(config), config.registryName, len(dependents), strings.Join(formatDependents(dependents), ", ")),
+		Details: dependents,
+	}
+}
+
 // promptForRm prompts the user for confirmation if not forced
 func promptForRm(config *rmRegistryConfig) error {
 	if !config.force {
@@ -157,44 +206,75 @@ func removePackageVersion(config *rmRegistryConfig) error {
 	}
 
 	versionsFile := filepath.Join(config.packageDir, "versions.json")
-	var versions []string
-	data, err := os.ReadFile(versionsFile)
+	versions, yanked, err := loadVersionsFile(versionsFile)
 	if err != nil {
 		return fmt.Errorf("failed to read %s for package '%s': %v", versionsFile, config.packageName, err)
 	}
-	if err := json.Unmarshal(data, &versions); err != nil {
-		return fmt.Errorf("failed to parse %s for package '%s': %v", versionsFile, config.packageName, err)
-	}
 	versions = removeString(versions, config.versionTag)
-	if err := savePackageVersions(versions, versionsFile); err != nil {
+	delete(yanked, config.versionTag)
+	if err := savePackageVersions(versions, yanked, versionsFile); err != nil {
+		return err
+	}
+	config.dependents.removeVersions(config.packageName, []string{config.versionTag})
+	if err := saveDependentsIndex(config.registriesDir, config.registryName, config.dependents); err != nil {
+		return err
+	}
+	if err := writeSearchIndex(config.registriesDir, config.registryName); err != nil {
 		return err
 	}
 
 	commitMsg := fmt.Sprintf("Removed version '%s' of package '%s'", config.versionTag, config.packageName)
 	if err := commitAndPushRegistryChanges(config.registriesDir, config.registryName, commitMsg); err != nil {
-		return fmt.Errorf("failed to commit changes for version '%s' of package '%s': %v", config.versionTag, config.packageName, err)
+		return newCodedError(ErrCodeGit, "failed to commit changes for version '%s' of package '%s': %v", config.versionTag, config.packageName, err)
 	}
 
-	fmt.Printf("Removed version '%s' of package '%s' from registry '%s'\n", config.versionTag, config.packageName, config.registryName)
-	return nil
+	fmt.Fprintf(messages, "Removed version '%s' of package '%s' from registry '%s'\n", config.versionTag, config.packageName, config.registryName)
+	return printJSONResult(newRegistryRmResult(config, config.registry.Packages[config.packageName], []string{config.versionTag}))
 }
 
 // removeEntirePackage removes an entire package from the registry
 func removeEntirePackage(config *rmRegistryConfig) error {
+	versions, err := loadVersions(config.registriesDir, config.registryName, config.packageName)
+	if err != nil {
+		return err
+	}
 	if err := os.RemoveAll(config.packageDir); err != nil {
 		return fmt.Errorf("failed to remove directory '%s' for package '%s': %v", config.packageDir, config.packageName, err)
 	}
 
+	pkgInfo := config.registry.Packages[config.packageName]
 	delete(config.registry.Packages, config.packageName)
 	if err := saveRegistryMetadata(config.registry, config.registryFile); err != nil {
 		return err
 	}
+	config.dependents.removeVersions(config.packageName, versions)
+	if err := saveDependentsIndex(config.registriesDir, config.registryName, config.dependents); err != nil {
+		return err
+	}
+	if err := writeSearchIndex(config.registriesDir, config.registryName); err != nil {
+		return err
+	}
 
 	commitMsg := fmt.Sprintf("Removed package '%s'", config.packageName)
 	if err := commitAndPushRegistryChanges(config.registriesDir, config.registryName, commitMsg); err != nil {
-		return fmt.Errorf("failed to commit changes for package '%s': %v", config.packageName, err)
+		return newCodedError(ErrCodeGit, "failed to commit changes for package '%s': %v", config.packageName, err)
 	}
 
-	fmt.Printf("Removed package '%s' from registry '%s'\n", config.packageName, config.registryName)
-	return nil
+	fmt.Fprintf(messages, "Removed package '%s' from registry '%s'\n", config.packageName, config.registryName)
+	return printJSONResult(newRegistryRmResult(config, pkgInfo, versions))
+}
+
+// newRegistryRmResult builds the JSON document for a package or versions removed from a registry
+func newRegistryRmResult(config *rmRegistryConfig, pkgInfo types.PackageInfo, versions []string) registryChangeResult {
+	if versions == nil {
+		versions = []string{}
+	}
+	return registryChangeResult{
+		Action:   "removed",
+		Registry: config.registryName,
+		Package:  config.packageName,
+		UUID:     pkgInfo.UUID,
+		GitURL:   pkgInfo.GitURL,
+		Versions: versions,
+	}
 }
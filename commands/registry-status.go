@@ -3,6 +3,7 @@ package commands
 import (
 	"cosm/types"
 	"fmt"
+	"sort"
 
 	"github.com/spf13/cobra"
 )
@@ -21,7 +22,7 @@ func RegistryStatus(cmd *cobra.Command, args []string) error {
 	// Parse arguments and initialize config
 	config, err := parseStatusArgs(args)
 	if err != nil {
-		return err
+		return withCode(ErrCodeInvalidArgs, err)
 	}
 
 	// Validate registry and load metadata
@@ -31,7 +32,7 @@ func RegistryStatus(cmd *cobra.Command, args []string) error {
 
 	// Print registry status
 	printRegistryStatus(config)
-	return nil
+	return printJSONResult(newRegistryStatusResult(config))
 }
 
 // parseStatusArgs parses and validates the registry name
@@ -63,7 +64,7 @@ func parseStatusArgs(args []string) (*statusRegistryConfig, error) {
 // validateRegistryForStatus checks if the registry exists and loads its metadata
 func validateRegistryForStatus(config *statusRegistryConfig) error {
 	if err := assertRegistryExists(config.registriesDir, config.registryName); err != nil {
-		return fmt.Errorf("failed to validate registry '%s': %v", config.registryName, err)
+		return newCodedError(ErrCodeNotFound, "failed to validate registry '%s': %v", config.registryName, err)
 	}
 
 	var err error
@@ -76,13 +77,43 @@ func validateRegistryForStatus(config *statusRegistryConfig) error {
 
 // printRegistryStatus displays the registry's package information
 func printRegistryStatus(config *statusRegistryConfig) {
-	fmt.Printf("Registry Status for '%s':\n", config.registryName)
+	fmt.Fprintf(messages, "Registry Status for '%s':\n", config.registryName)
 	if len(config.registry.Packages) == 0 {
-		fmt.Println("  No packages registered.")
+		fmt.Fprintln(messages, "  No packages registered.")
 	} else {
-		fmt.Println("  Packages:")
+		fmt.Fprintln(messages, "  Packages:")
 		for pkgName, pkgInfo := range config.registry.Packages {
-			fmt.Printf("    - %s (UUID: %s)\n", pkgName, pkgInfo.UUID)
+			fmt.Fprintf(messages, "    - %s (UUID: %s)\n", pkgName, pkgInfo.UUID)
 		}
 	}
 }
+
+// registryStatusResult is the JSON document printed by cosm registry status --output json
+type registryStatusResult struct {
+	Registry string                `json:"registry"`
+	UUID     string                `json:"uuid"`
+	GitURL   string                `json:"giturl"`
+	Packages []registryPackageJSON `json:"packages"`
+}
+
+// registryPackageJSON describes a registered package in JSON output
+type registryPackageJSON struct {
+	Name   string `json:"name"`
+	UUID   string `json:"uuid"`
+	GitURL string `json:"giturl"`
+}
+
+// newRegistryStatusResult builds the JSON document for the registry status, sorted by package name
+func newRegistryStatusResult(config *statusRegistryConfig) registryStatusResult {
+	packages := []registryPackageJSON{}
+	for pkgName, pkgInfo := range config.registry.Packages {
+		packages = append(packages, registryPackageJSON{Name: pkgName, UUID: pkgInfo.UUID, GitURL: pkgInfo.GitURL})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return registryStatusResult{
+		Registry: config.registryName,
+		UUID:     config.registry.UUID,
+		GitURL:   config.registry.GitURL,
+		Packages: packages,
+	}
+}
@@ -2,19 +2,39 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+// registryUpdateResult is the JSON document printed by cosm registry update --output json
+type registryUpdateResult struct {
+	Registries []registryUpdateStatus `json:"registries"`
+}
+
+// registryUpdateStatus reports the outcome of updating a single registry
+type registryUpdateStatus struct {
+	Name        string `json:"name"`
+	Updated     bool   `json:"updated"`
+	Duration    string `json:"duration,omitempty"`
+	LastUpdated string `json:"last_updated,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
 // RegistryUpdate updates and synchronizes a registry or all registries with their remotes
 func RegistryUpdate(cmd *cobra.Command, args []string) error {
 	all, _ := cmd.Flags().GetBool("all")
 	if all && len(args) != 0 {
-		return fmt.Errorf("no arguments allowed with --all flag")
+		return newCodedError(ErrCodeInvalidArgs, "no arguments allowed with --all flag")
 	}
 	if !all && len(args) != 1 {
-		return fmt.Errorf("exactly one argument required (e.g., cosm registry update <registry_name>)")
+		return newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm registry update <registry_name>)")
+	}
+
+	if err := requireOnline("update registries"); err != nil {
+		return err
 	}
 
 	cosmDir, err := getCosmDir()
@@ -23,29 +43,73 @@ func RegistryUpdate(cmd *cobra.Command, args []string) error {
 	}
 	registriesDir := setupRegistriesDir(cosmDir)
 
+	result := registryUpdateResult{Registries: []registryUpdateStatus{}}
 	if all {
 		registryNames, err := loadRegistryNames(registriesDir)
 		if err != nil {
 			return fmt.Errorf("failed to load registry names: %v", err)
 		}
 		if len(registryNames) == 0 {
-			fmt.Println("No registries to update.")
-			return nil
+			fmt.Fprintln(messages, "No registries to update.")
+			return printJSONResult(result)
+		}
+		result.Registries = updateRegistries(registriesDir, registryNames, true)
+		if err := printRegistryUpdateTable(registriesDir, result.Registries); err != nil {
+			return err
+		}
+		var failed []string
+		for _, status := range result.Registries {
+			if !status.Updated {
+				failed = append(failed, status.Name)
+			}
 		}
-		for _, name := range registryNames {
-			if err := updateSingleRegistry(registriesDir, name); err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to update registry '%s': %v\n", name, err)
-				continue
+		if len(failed) > 0 {
+			return &CommandError{
+				Code:    ErrCodeGit,
+				Err:     fmt.Errorf("failed to update registries: %s", strings.Join(failed, ", ")),
+				Details: result,
 			}
-			fmt.Printf("Updated registry '%s'\n", name)
 		}
-		return nil
+		return printJSONResult(result)
 	}
 
 	registryName := args[0]
 	if err := updateSingleRegistry(registriesDir, registryName); err != nil {
+		return withCode(ErrCodeGit, err)
+	}
+	fmt.Fprintf(messages, "Updated registry '%s'\n", registryName)
+	result.Registries = append(result.Registries, registryUpdateStatus{Name: registryName, Updated: true})
+	return printJSONResult(result)
+}
+
+// printRegistryUpdateTable fills in the time of the last successful pull of each registry and
+// prints a summary table followed by the failures
+func printRegistryUpdateTable(registriesDir string, statuses []registryUpdateStatus) error {
+	updateTimes, err := loadRegistryUpdateTimes(registriesDir)
+	if err != nil {
 		return err
 	}
-	fmt.Printf("Updated registry '%s'\n", registryName)
+	table := tabwriter.NewWriter(messages, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "REGISTRY\tSTATUS\tDURATION\tLAST UPDATED")
+	updated := 0
+	for i, status := range statuses {
+		state, lastUpdated := "failed", "never"
+		if status.Updated {
+			state = "updated"
+			updated++
+		}
+		if t, ok := updateTimes[status.Name]; ok {
+			statuses[i].LastUpdated = t.Format(time.RFC3339)
+			lastUpdated = statuses[i].LastUpdated
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", status.Name, state, status.Duration, lastUpdated)
+	}
+	table.Flush()
+	for _, status := range statuses {
+		if status.Error != "" {
+			fmt.Fprintf(messages, "Failed to update registry '%s': %s\n", status.Name, status.Error)
+		}
+	}
+	fmt.Fprintf(messages, "Updated %d of %d registries\n", updated, len(statuses))
 	return nil
 }
@@ -0,0 +1,476 @@
+package commands
+
+import (
+	"cosm/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Severities of registry verification findings
+const (
+	severityError   = "error"   // the registry cannot resolve a package or version correctly
+	severityWarning = "warning" // the registry is inconsistent but resolution is not affected
+)
+
+// registryVerifyConfig holds configuration for verifying the contents of a registry
+type registryVerifyConfig struct {
+	registryName  string
+	deep          bool
+	fix           bool
+	cosmDir       string
+	registriesDir string
+	registry      types.Registry
+}
+
+// registryFinding is an inconsistency found in a registry
+type registryFinding struct {
+	Severity string `json:"severity"`
+	Package  string `json:"package,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Message  string `json:"message"`
+	Fixable  bool   `json:"fixable"`
+	Fixed    bool   `json:"fixed,omitempty"`
+}
+
+// registryVerifyResult is the JSON document printed by cosm registry verify --output json
+type registryVerifyResult struct {
+	Registry string            `json:"registry"`
+	Findings []registryFinding `json:"findings"`
+	Errors   int               `json:"errors"`   // errors that were not fixed
+	Warnings int               `json:"warnings"` // warnings that were not fixed
+	Fixed    int               `json:"fixed"`
+}
+
+// registryVerifier collects the findings of a registry verification and the repairs to apply
+type registryVerifier struct {
+	config        *registryVerifyConfig
+	findings      []registryFinding
+	versionsFixes map[string]*versionsRepair // package name -> repair of its versions.json
+	indexFixes    map[string][]int           // index file name -> findings fixed by regenerating it
+}
+
+// versionsRepair is the content to write to versions.json of a package and the findings it fixes
+type versionsRepair struct {
+	versions []string
+	findings []int
+}
+
+// RegistryVerify cross-checks registry.json, versions.json, specs.json and buildlist.json of a
+// registry, optionally against the Git tags of the packages with --deep, and applies safe repairs
+// with --fix
+func RegistryVerify(cmd *cobra.Command, args []string) error {
+	config, err := parseRegistryVerifyArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// Repairs are pushed, so they are made in the locked and updated registry
+	if config.fix {
+		if err := requireOnline(fmt.Sprintf("repair registry '%s'", config.registryName)); err != nil {
+			return err
+		}
+		unlock, err := lockRegistry(config.registriesDir, config.registryName)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := syncRegistry(config.registriesDir, config.registryName); err != nil {
+			return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", config.registryName, err))
+		}
+	} else if _, err := refreshRegistry(config.registriesDir, config.registryName, false); err != nil {
+		return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", config.registryName, err))
+	}
+	config.registry, _, err = LoadRegistryMetadata(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+
+	verifier := &registryVerifier{config: config, versionsFixes: make(map[string]*versionsRepair), indexFixes: make(map[string][]int)}
+	if err := verifier.checkPackages(); err != nil {
+		return err
+	}
+	verifier.checkIndexes()
+	if config.fix {
+		if err := verifier.applyFixes(); err != nil {
+			return err
+		}
+	}
+
+	result := verifier.result()
+	printRegistryFindings(result)
+	if result.Errors > 0 {
+		return &CommandError{
+			Code:    ErrCodeVerification,
+			Err:     fmt.Errorf("registry '%s' has %d errors", config.registryName, result.Errors),
+			Details: result,
+		}
+	}
+	return printJSONResult(result)
+}
+
+// parseRegistryVerifyArgs validates the registry name and flags
+func parseRegistryVerifyArgs(cmd *cobra.Command, args []string) (*registryVerifyConfig, error) {
+	if len(args) != 1 {
+		return nil, newCodedError(ErrCodeInvalidArgs, "exactly one argument required (e.g., cosm registry verify <registry_name>)")
+	}
+	config := &registryVerifyConfig{registryName: args[0]}
+	config.deep, _ = cmd.Flags().GetBool("deep")
+	config.fix, _ = cmd.Flags().GetBool("fix")
+	var err error
+	config.cosmDir, err = getCosmDir()
+	if err != nil {
+		return nil, err
+	}
+	config.registriesDir = setupRegistriesDir(config.cosmDir)
+	if err := assertRegistryExists(config.registriesDir, config.registryName); err != nil {
+		return nil, withCode(ErrCodeNotFound, err)
+	}
+	return config, nil
+}
+
+// report records a finding and returns its index
+func (v *registryVerifier) report(severity, packageName, version string, fixable bool, format string, args ...interface{}) int {
+	v.findings = append(v.findings, registryFinding{
+		Severity: severity,
+		Package:  packageName,
+		Version:  version,
+		Message:  fmt.Sprintf(format, args...),
+		Fixable:  fixable,
+	})
+	return len(v.findings) - 1
+}
+
+// checkPackages checks every package of registry.json and reports package directories that are
+// not registered
+func (v *registryVerifier) checkPackages() error {
+	names := make([]string, 0, len(v.config.registry.Packages))
+	for name := range v.config.registry.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := v.checkPackage(name, v.config.registry.Packages[name]); err != nil {
+			return err
+		}
+	}
+
+	registryDir := filepath.Join(v.config.registriesDir, v.config.registryName)
+	letters, err := os.ReadDir(registryDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", registryDir, err)
+	}
+	for _, letter := range letters {
+		if !letter.IsDir() || strings.HasPrefix(letter.Name(), ".") {
+			continue
+		}
+		packages, err := os.ReadDir(filepath.Join(registryDir, letter.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", filepath.Join(registryDir, letter.Name()), err)
+		}
+		for _, pkg := range packages {
+			if _, registered := v.config.registry.Packages[pkg.Name()]; pkg.IsDir() && !registered {
+				v.report(severityWarning, pkg.Name(), "", false, "directory %s is not registered in registry.json", filepath.Join(letter.Name(), pkg.Name()))
+			}
+		}
+	}
+	return nil
+}
+
+// checkPackage compares versions.json of a package with its version directories and checks the
+// specs and build list of every version
+func (v *registryVerifier) checkPackage(name string, pkgInfo types.PackageInfo) error {
+	relativeDir := filepath.Join(strings.ToUpper(string(name[0])), name)
+	packageDir := filepath.Join(v.config.registriesDir, v.config.registryName, relativeDir)
+	if _, err := os.Stat(packageDir); os.IsNotExist(err) {
+		v.report(severityError, name, "", false, "package is registered in registry.json but directory %s does not exist", relativeDir)
+		return nil
+	}
+
+	var repaired []int
+	versions, yanked, err := loadVersionsFile(filepath.Join(packageDir, "versions.json"))
+	if err != nil && !os.IsNotExist(err) {
+		repaired = append(repaired, v.report(severityError, name, "", true, "versions.json cannot be read: %v", err))
+	}
+	dirs, err := listVersionDirs(packageDir)
+	if err != nil {
+		return err
+	}
+	// Listed versions keep their entry as long as their directory exists, even with unusable specs
+	fixedVersions := []string{}
+	for _, version := range versions {
+		if contains(dirs, version) {
+			fixedVersions = append(fixedVersions, version)
+			continue
+		}
+		repaired = append(repaired, v.report(severityError, name, version, true, "versions.json lists %s but its directory does not exist", version))
+	}
+	for version := range yanked {
+		if !contains(versions, version) {
+			repaired = append(repaired, v.report(severityWarning, name, version, true, "yanked.json yanks %s which is not listed in versions.json", version))
+		}
+	}
+
+	// Only unlisted version directories with usable specs are added to versions.json
+	validDirs := []string{}
+	for _, version := range dirs {
+		if !v.checkVersion(name, pkgInfo, version) {
+			continue
+		}
+		validDirs = append(validDirs, version)
+		if !contains(versions, version) {
+			fixedVersions = append(fixedVersions, version)
+			repaired = append(repaired, v.report(severityWarning, name, version, true, "directory %s is not listed in versions.json", version))
+		}
+	}
+	if len(repaired) > 0 {
+		v.versionsFixes[name] = &versionsRepair{versions: fixedVersions, findings: repaired}
+	}
+
+	if v.config.deep && len(validDirs) > 0 {
+		return v.checkTags(name, pkgInfo, validDirs)
+	}
+	return nil
+}
+
+// checkVersion checks specs.json and buildlist.json of a version and reports whether its specs
+// are usable
+func (v *registryVerifier) checkVersion(name string, pkgInfo types.PackageInfo, version string) bool {
+	registriesDir, registryName := v.config.registriesDir, v.config.registryName
+	specs, err := loadSpecs(registriesDir, registryName, name, version)
+	if err != nil {
+		v.report(severityError, name, version, false, "specs.json of %s cannot be loaded: %v", version, err)
+		return false
+	}
+	if specs.Name != name || specs.UUID != pkgInfo.UUID || specs.Version != version {
+		v.report(severityError, name, version, false, "specs.json of %s describes %s@%s (UUID %s) instead of %s@%s (UUID %s)", version, specs.Name, specs.Version, specs.UUID, name, version, pkgInfo.UUID)
+		return false
+	}
+
+	buildList, err := loadBuildList(registriesDir, registryName, name, version)
+	if err != nil {
+		v.report(severityError, name, version, false, "buildlist.json of %s cannot be loaded: %v", version, err)
+		return true
+	}
+	for _, key := range sortedBuildListKeys(buildList) {
+		entry := buildList.Dependencies[key]
+		registered, _, err := findDependency(entry.Name, entry.Version, entry.UUID, registriesDir)
+		if err != nil {
+			v.report(severityError, name, version, false, "buildlist.json of %s requires %s@%s which is not registered in any registry", version, entry.Name, entry.Version)
+			continue
+		}
+		if registered.SHA1 != entry.SHA1 {
+			v.report(severityError, name, version, false, "buildlist.json of %s records SHA1 %s for %s@%s but the registered SHA1 is %s", version, entry.SHA1, entry.Name, entry.Version, registered.SHA1)
+		}
+	}
+	return true
+}
+
+// checkTags compares the SHA1 in specs.json of every version with the Git tag in the clone of the
+// package, cloning it if needed
+func (v *registryVerifier) checkTags(name string, pkgInfo types.PackageInfo, versions []string) error {
+	unlock, err := lockClone(v.config.cosmDir, pkgInfo.UUID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	clonePath := filepath.Join(v.config.cosmDir, "clones", pkgInfo.UUID)
+	if _, err := os.Stat(clonePath); os.IsNotExist(err) {
+		if Offline() {
+			v.report(severityWarning, name, "", false, "tags not checked: no local clone at %s in offline mode", clonePath)
+			return nil
+		}
+		tmpClonePath, err := clonePackageToTempDir(v.config.cosmDir, pkgInfo.GitURL)
+		if err != nil {
+			v.report(severityError, name, "", false, "tags not checked: %v", err)
+			return nil
+		}
+		defer cleanupTempClone(tmpClonePath)
+		if clonePath, err = moveCloneToPermanentDir(v.config.cosmDir, tmpClonePath, pkgInfo.UUID); err != nil {
+			return err
+		}
+	} else if !Offline() {
+		// Tags may have been moved on the remote since the clone was made
+		if _, err := GitCommand(clonePath, "fetch", "--tags", "--force", "origin"); err != nil {
+			v.report(severityWarning, name, "", false, "tags checked against the local clone: fetching from '%s' failed: %v", pkgInfo.GitURL, err)
+		}
+	}
+
+	for _, version := range versions {
+		specs, err := loadSpecs(v.config.registriesDir, v.config.registryName, name, version)
+		if err != nil {
+			continue // Reported by checkVersion
+		}
+		output, err := GitCommand(clonePath, "rev-list", "-n", "1", version)
+		if err != nil {
+			v.report(severityError, name, version, false, "tag %s does not exist in the repository of the package", version)
+			continue
+		}
+		if sha1 := strings.TrimSpace(output); sha1 != specs.SHA1 {
+			v.report(severityError, name, version, false, "specs.json of %s records SHA1 %s but tag %s points to %s", version, specs.SHA1, version, sha1)
+		}
+	}
+	return nil
+}
+
+// checkIndexes compares index.json and dependents.json with indexes rebuilt from the packages
+func (v *registryVerifier) checkIndexes() {
+	registriesDir, registryName := v.config.registriesDir, v.config.registryName
+	registryDir := filepath.Join(registriesDir, registryName)
+	if searchIndex, err := buildSearchIndex(registriesDir, registryName); err == nil {
+		v.compareIndex(filepath.Join(registryDir, "index.json"), searchIndex)
+	}
+	if dependents, err := buildDependentsIndex(registriesDir, registryName); err == nil {
+		v.compareIndex(filepath.Join(registryDir, "dependents.json"), dependents)
+	}
+}
+
+// compareIndex reports an index file that is missing or differs from the rebuilt index
+func (v *registryVerifier) compareIndex(indexFile string, rebuilt interface{}) {
+	name := filepath.Base(indexFile)
+	data, err := os.ReadFile(indexFile)
+	if os.IsNotExist(err) {
+		v.indexFixes[name] = append(v.indexFixes[name], v.report(severityWarning, "", "", true, "%s does not exist", name))
+		return
+	}
+	if err != nil {
+		v.indexFixes[name] = append(v.indexFixes[name], v.report(severityWarning, "", "", true, "%s cannot be read: %v", name, err))
+		return
+	}
+	// Compare the decoded documents so that formatting differences are not reported
+	expected, err := json.Marshal(rebuilt)
+	if err != nil {
+		return
+	}
+	var stored, normalized interface{}
+	if json.Unmarshal(data, &stored) != nil || json.Unmarshal(expected, &normalized) != nil || !reflect.DeepEqual(stored, normalized) {
+		v.indexFixes[name] = append(v.indexFixes[name], v.report(severityWarning, "", "", true, "%s is out of date with the package directories", name))
+	}
+}
+
+// applyFixes repairs versions.json of the packages with fixable findings, regenerates the indexes
+// of the registry and pushes the repairs. Only the findings whose repair was written are fixed.
+func (v *registryVerifier) applyFixes() error {
+	if len(v.versionsFixes) == 0 && len(v.indexFixes) == 0 {
+		return nil
+	}
+	registriesDir, registryName := v.config.registriesDir, v.config.registryName
+	var repaired []int
+	for name, repair := range v.versionsFixes {
+		versionsFile := versionsFilePath(registriesDir, registryName, name)
+		_, yanked, err := loadVersionsFile(versionsFile)
+		if err != nil {
+			yanked = map[string]types.YankedVersion{}
+		}
+		for version := range yanked {
+			if !contains(repair.versions, version) {
+				delete(yanked, version)
+			}
+		}
+		sortVersions(repair.versions)
+		if err := savePackageVersions(repair.versions, yanked, versionsFile); err != nil {
+			return err
+		}
+		repaired = append(repaired, repair.findings...)
+	}
+
+	// The indexes cannot be rebuilt while a listed version has unusable specs, which is reported
+	// as an error of its own
+	if searchIndex, err := buildSearchIndex(registriesDir, registryName); err == nil {
+		if err := saveSearchIndex(registriesDir, registryName, searchIndex); err != nil {
+			return err
+		}
+		repaired = append(repaired, v.indexFixes["index.json"]...)
+	}
+	if dependents, err := buildDependentsIndex(registriesDir, registryName); err == nil {
+		if err := saveDependentsIndex(registriesDir, registryName, dependents); err != nil {
+			return err
+		}
+		repaired = append(repaired, v.indexFixes["dependents.json"]...)
+	}
+	if len(repaired) == 0 {
+		return nil
+	}
+	if err := commitAndPushRegistryChanges(registriesDir, registryName, "Repaired registry with cosm registry verify --fix"); err != nil {
+		return withCode(ErrCodeGit, err)
+	}
+	for _, i := range repaired {
+		v.findings[i].Fixed = true
+	}
+	return nil
+}
+
+// result counts the findings that remain after repairs
+func (v *registryVerifier) result() registryVerifyResult {
+	result := registryVerifyResult{Registry: v.config.registryName, Findings: []registryFinding{}}
+	for _, finding := range v.findings {
+		result.Findings = append(result.Findings, finding)
+		switch {
+		case finding.Fixed:
+			result.Fixed++
+		case finding.Severity == severityError:
+			result.Errors++
+		default:
+			result.Warnings++
+		}
+	}
+	return result
+}
+
+// listVersionDirs returns the version directories of a package sorted by precedence
+func listVersionDirs(packageDir string) ([]string, error) {
+	entries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", packageDir, err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "v") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// printRegistryFindings prints the findings grouped by severity followed by a summary
+func printRegistryFindings(result registryVerifyResult) {
+	for _, severity := range []string{severityError, severityWarning} {
+		var lines []string
+		for _, finding := range result.Findings {
+			if finding.Severity != severity {
+				continue
+			}
+			subject := finding.Package
+			if finding.Version != "" {
+				subject += "@" + finding.Version
+			}
+			if subject != "" {
+				subject += ": "
+			}
+			state := ""
+			if finding.Fixed {
+				state = " [fixed]"
+			} else if finding.Fixable {
+				state = " [fixable with --fix]"
+			}
+			lines = append(lines, fmt.Sprintf("  %s%s%s", subject, finding.Message, state))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if severity == severityError {
+			fmt.Fprintln(messages, "Errors:")
+		} else {
+			fmt.Fprintln(messages, "Warnings:")
+		}
+		fmt.Fprintln(messages, strings.Join(lines, "\n"))
+	}
+	fmt.Fprintf(messages, "Registry '%s': %d errors, %d warnings, %d fixed\n", result.Registry, result.Errors, result.Warnings, result.Fixed)
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"cosm/types"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// yankConfig holds configuration for yanking or unyanking a version in a registry
+type yankConfig struct {
+	registryName  string
+	packageName   string
+	versionTag    string
+	reason        string
+	registriesDir string
+	versionsFile  string
+}
+
+// yankResult is the JSON document printed by cosm registry yank and unyank --output json
+type yankResult struct {
+	Action   string `json:"action"`
+	Registry string `json:"registry"`
+	Package  string `json:"package"`
+	Version  string `json:"version"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RegistryYank marks a version as yanked in yanked.json of the package. The version stays resolvable for
+// existing requirements and build lists, but is no longer selected by add or upgrade.
+func RegistryYank(cmd *cobra.Command, args []string) error {
+	config, err := parseYankArgs(cmd, args)
+	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	err = updateYankedVersions(config, func(yanked map[string]types.YankedVersion) error {
+		if _, exists := yanked[config.versionTag]; exists {
+			return newCodedError(ErrCodeAlreadyExists, "version '%s' of package '%s' is already yanked in registry '%s'", config.versionTag, config.packageName, config.registryName)
+		}
+		yanked[config.versionTag] = types.YankedVersion{Reason: config.reason, Date: time.Now().UTC().Format(time.RFC3339)}
+		return nil
+	}, fmt.Sprintf("Yanked version '%s' of package '%s'", config.versionTag, config.packageName))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(messages, "Yanked version '%s' of package '%s' in registry '%s'\n", config.versionTag, config.packageName, config.registryName)
+	return printJSONResult(yankResult{Action: "yanked", Registry: config.registryName, Package: config.packageName, Version: config.versionTag, Reason: config.reason})
+}
+
+// RegistryUnyank makes a yanked version selectable again
+func RegistryUnyank(cmd *cobra.Command, args []string) error {
+	config, err := parseYankArgs(cmd, args)
+	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	err = updateYankedVersions(config, func(yanked map[string]types.YankedVersion) error {
+		if _, exists := yanked[config.versionTag]; !exists {
+			return newCodedError(ErrCodeNotFound, "version '%s' of package '%s' is not yanked in registry '%s'", config.versionTag, config.packageName, config.registryName)
+		}
+		delete(yanked, config.versionTag)
+		return nil
+	}, fmt.Sprintf("Unyanked version '%s' of package '%s'", config.versionTag, config.packageName))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(messages, "Unyanked version '%s' of package '%s' in registry '%s'\n", config.versionTag, config.packageName, config.registryName)
+	return printJSONResult(yankResult{Action: "unyanked", Registry: config.registryName, Package: config.packageName, Version: config.versionTag})
+}
+
+// parseYankArgs validates the registry name, package name and version
+func parseYankArgs(cmd *cobra.Command, args []string) (*yankConfig, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("requires registry name, package name and version (e.g., cosm registry yank <registry> <package> v<version>)")
+	}
+	config := &yankConfig{registryName: args[0], packageName: args[1], versionTag: args[2]}
+	if config.registryName == "" {
+		return nil, fmt.Errorf("registry name cannot be empty")
+	}
+	if config.packageName == "" {
+		return nil, fmt.Errorf("package name cannot be empty")
+	}
+	if !strings.HasPrefix(config.versionTag, "v") {
+		return nil, fmt.Errorf("version must start with 'v'")
+	}
+	if cmd.Flags().Lookup("reason") != nil {
+		config.reason, _ = cmd.Flags().GetString("reason")
+	}
+	registriesDir, err := getRegistriesDir()
+	if err != nil {
+		return nil, err
+	}
+	config.registriesDir = registriesDir
+	config.versionsFile = versionsFilePath(registriesDir, config.registryName, config.packageName)
+	return config, nil
+}
+
+// updateYankedVersions changes the yanked versions of a package in the locked and updated registry,
+// regenerates the search index and pushes the change
+func updateYankedVersions(config *yankConfig, change func(map[string]types.YankedVersion) error, commitMsg string) error {
+	if err := requireOnline(fmt.Sprintf("change yanked versions in registry '%s'", config.registryName)); err != nil {
+		return err
+	}
+	unlock, err := lockRegistry(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := syncRegistry(config.registriesDir, config.registryName); err != nil {
+		return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", config.registryName, err))
+	}
+
+	registry, _, err := LoadRegistryMetadata(config.registriesDir, config.registryName)
+	if err != nil {
+		return err
+	}
+	if _, exists := registry.Packages[config.packageName]; !exists {
+		return newCodedError(ErrCodeNotFound, "package '%s' not found in registry '%s'", config.packageName, config.registryName)
+	}
+	versions, yanked, err := loadVersionsFile(config.versionsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read versions.json for package '%s': %v", config.packageName, err)
+	}
+	if !contains(versions, config.versionTag) {
+		return newCodedError(ErrCodeNotFound, "version '%s' not found for package '%s' in registry '%s'", config.versionTag, config.packageName, config.registryName)
+	}
+	if err := change(yanked); err != nil {
+		return err
+	}
+	if err := savePackageVersions(versions, yanked, config.versionsFile); err != nil {
+		return err
+	}
+	if err := writeSearchIndex(config.registriesDir, config.registryName); err != nil {
+		return err
+	}
+	if err := commitAndPushRegistryChanges(config.registriesDir, config.registryName, commitMsg); err != nil {
+		return withCode(ErrCodeGit, err)
+	}
+	return nil
+}
@@ -25,12 +25,16 @@ func Release(cmd *cobra.Command, args []string) error {
 	// Parse arguments and initialize config
 	config, err := parseReleaseArgs(cmd, args)
 	if err != nil {
+		return withCode(ErrCodeInvalidArgs, err)
+	}
+	if err := requireOnline("publish a release"); err != nil {
 		return err
 	}
+	previousVersion := config.project.Version
 
 	// Validate repository state
 	if err := validateRepositoryState(config); err != nil {
-		return err
+		return withCode(ErrCodeGit, err)
 	}
 
 	// Validate new version
@@ -45,11 +49,24 @@ func Release(cmd *cobra.Command, args []string) error {
 
 	// Publish to Git remote
 	if err := publishToGitRemote(config); err != nil {
-		return err
+		return withCode(ErrCodeGit, err)
 	}
 
-	fmt.Printf("Released version '%s' for project '%s'\n", config.newVersion, config.project.Name)
-	return nil
+	fmt.Fprintf(messages, "Released version '%s' for project '%s'\n", config.newVersion, config.project.Name)
+	return printJSONResult(releaseResult{
+		Project:         config.project.Name,
+		UUID:            config.project.UUID,
+		Version:         config.newVersion,
+		PreviousVersion: previousVersion,
+	})
+}
+
+// releaseResult is the JSON document printed by cosm release --output json
+type releaseResult struct {
+	Project         string `json:"project"`
+	UUID            string `json:"uuid"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version"`
 }
 
 // parseReleaseArgs parses arguments and flags to initialize the release config
@@ -104,15 +121,39 @@ func parseReleaseArgs(cmd *cobra.Command, args []string) (*releaseConfig, error)
 	}
 	switch {
 	case config.patch:
-		config.newVersion = fmt.Sprintf("v%d.%d.%d", currentSemVer.Major, currentSemVer.Minor, currentSemVer.Patch+1)
+		config.newVersion = incrementPatch(currentSemVer).String()
 	case config.minor:
-		config.newVersion = fmt.Sprintf("v%d.%d.0", currentSemVer.Major, currentSemVer.Minor+1)
+		config.newVersion = incrementMinor(currentSemVer).String()
 	case config.major:
-		config.newVersion = fmt.Sprintf("v%d.0.0", currentSemVer.Major+1)
+		config.newVersion = incrementMajor(currentSemVer).String()
 	}
 	return config, nil
 }
 
+// incrementPatch returns the next patch release; a pre-release of vX.Y.Z is released as vX.Y.Z
+func incrementPatch(s semVer) semVer {
+	if s.IsPrerelease() {
+		return semVer{Major: s.Major, Minor: s.Minor, Patch: s.Patch}
+	}
+	return semVer{Major: s.Major, Minor: s.Minor, Patch: s.Patch + 1}
+}
+
+// incrementMinor returns the next minor release; a pre-release of vX.Y.0 is released as vX.Y.0
+func incrementMinor(s semVer) semVer {
+	if s.IsPrerelease() && s.Patch == 0 {
+		return semVer{Major: s.Major, Minor: s.Minor}
+	}
+	return semVer{Major: s.Major, Minor: s.Minor + 1}
+}
+
+// incrementMajor returns the next major release; a pre-release of vX.0.0 is released as vX.0.0
+func incrementMajor(s semVer) semVer {
+	if s.IsPrerelease() && s.Minor == 0 && s.Patch == 0 {
+		return semVer{Major: s.Major}
+	}
+	return semVer{Major: s.Major + 1}
+}
+
 // validateRepositoryState ensures the repository is clean and in sync with origin
 func validateRepositoryState(config *releaseConfig) error {
 	if err := ensureNoUncommittedChanges(config.projectDir); err != nil {
@@ -130,7 +171,7 @@ func validateReleaseVersion(config *releaseConfig) error {
 		return err
 	}
 	if err := ensureTagDoesNotExist(config.projectDir, config.newVersion); err != nil {
-		return fmt.Errorf("failed to validate tag '%s' in %s: %v", config.newVersion, config.projectDir, err)
+		return fmt.Errorf("failed to validate tag '%s' in %s: %w", config.newVersion, config.projectDir, err) // Keeps the already_exists code
 	}
 	return nil
 }
@@ -189,7 +230,7 @@ func ensureTagDoesNotExist(projectDir, newVersion string) error {
 	}
 	for _, tag := range tags {
 		if tag == newVersion {
-			return fmt.Errorf("tag '%s' already exists in the repository", newVersion)
+			return newCodedError(ErrCodeAlreadyExists, "tag '%s' already exists in the repository", newVersion)
 		}
 	}
 	return nil
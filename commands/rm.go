@@ -14,7 +14,7 @@ import (
 func Rm(cmd *cobra.Command, args []string) error {
 	packageName, err := parseRmArgs(args)
 	if err != nil {
-		return err
+		return withCode(ErrCodeInvalidArgs, err)
 	}
 
 	project, err := loadProject("Project.json")
@@ -22,26 +22,18 @@ func Rm(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	keys, deps, err := findDependencyKey(project, packageName)
+	depKey, err := selectDependencyKey(project, packageName, "remove")
 	if err != nil {
 		return err
 	}
 
-	var depKey string
-	if len(keys) > 1 {
-		depKey, err = promptUserForDependency(packageName, keys, deps)
-		if err != nil {
-			return err
-		}
-	} else {
-		depKey = keys[0]
-	}
-
+	removed := project.Deps[depKey]
+	removedUUID, _ := extractUUIDFromKey(depKey)
 	if err := removeDependency(project, depKey, packageName); err != nil {
 		return err
 	}
 
-	return nil
+	return printJSONResult(dependencyResult{Action: "removed", Name: packageName, UUID: removedUUID, Version: removed.Version})
 }
 
 // parseRmArgs validates the input arguments for the rm command
@@ -67,23 +59,23 @@ func findDependencyKey(project *types.Project, packageName string) ([]string, []
 		}
 	}
 	if len(keys) == 0 {
-		return nil, nil, fmt.Errorf("dependency '%s' not found in project", packageName)
+		return nil, nil, newCodedError(ErrCodeNotFound, "dependency '%s' not found in project", packageName)
 	}
 	return keys, deps, nil
 }
 
 // promptUserForDependency prompts the user to select a dependency when multiple have the same name
-func promptUserForDependency(packageName string, keys []string, deps []types.Dependency) (string, error) {
-	fmt.Printf("Multiple dependencies named '%s' found:\n", packageName)
+func promptUserForDependency(packageName string, keys []string, deps []types.Dependency, action string) (string, error) {
+	fmt.Fprintf(messages, "Multiple dependencies named '%s' found:\n", packageName)
 	for i, dep := range deps {
 		key := keys[i]
 		parts := strings.Split(key, "@")
 		if len(parts) != 2 {
 			return "", fmt.Errorf("invalid key format for dependency '%s': %s", packageName, key)
 		}
-		fmt.Printf("  %d. Version %s (UUID: %s, Major Version: %s)\n", i+1, dep.Version, parts[0], parts[1])
+		fmt.Fprintf(messages, "  %d. Version %s (UUID: %s, Major Version: %s)\n", i+1, dep.Version, parts[0], parts[1])
 	}
-	fmt.Printf("Please select a dependency to remove (enter number 1-%d): ", len(deps))
+	fmt.Fprintf(messages, "Please select a dependency to %s (enter number 1-%d): ", action, len(deps))
 
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
@@ -104,6 +96,6 @@ func removeDependency(project *types.Project, depKey, packageName string) error
 		return err
 	}
 
-	fmt.Printf("Removed dependency '%s' from project\n", packageName)
+	fmt.Fprintf(messages, "Removed dependency '%s' from project\n", packageName)
 	return nil
 }
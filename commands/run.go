@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// Run executes a command in the environment of the project in the current directory, making
+// all packages in the build list available first. The exit code of the command is propagated.
+func Run(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return newCodedError(ErrCodeInvalidArgs, "expected a command to run (e.g., cosm run -- terra src/main.t)")
+	}
+
+	jobs, err := parseJobs(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Progress messages go to stderr so that stdout only carries the output of the command
+	restore := redirectMessages(os.Stderr)
+	config, err := prepareEnvironment(false, jobs)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	command := exec.Command(args[0], args[1:]...)
+	command.Env = environWith(config.env)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			cmd.SilenceErrors = true // The command has reported its own failure
+			return &ExitStatusError{Command: args[0], Status: exitStatus(exitErr)}
+		}
+		return fmt.Errorf("failed to run '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+// exitStatus returns the exit code of a command, or 128 plus the signal number if it was killed
+// by a signal, as shells report it
+func exitStatus(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// searchConfig holds configuration for searching packages across registries
+type searchConfig struct {
+	query         string
+	registryNames []string
+	language      string
+	refresh       bool
+	registriesDir string
+}
+
+// searchResult is the JSON document printed by cosm search --output json
+type searchResult struct {
+	Query    string        `json:"query"`
+	Packages []searchMatch `json:"packages"`
+}
+
+// searchMatch is a package that matches a search query
+type searchMatch struct {
+	searchIndexEntry
+	Registry string `json:"registry"`
+	Score    int    `json:"score"`
+}
+
+// Search matches a query against the names, keywords and descriptions of the packages in all
+// registries and prints the matches ranked by relevance
+func Search(cmd *cobra.Command, args []string) error {
+	config, err := parseSearchArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	var matches []searchMatch
+	for _, registryName := range config.registryNames {
+		if _, err := refreshRegistry(config.registriesDir, registryName, config.refresh); err != nil {
+			return withCode(ErrCodeGit, fmt.Errorf("failed to update registry '%s': %w", registryName, err))
+		}
+		index, err := loadSearchIndex(config.registriesDir, registryName)
+		if err != nil {
+			return err
+		}
+		for _, entry := range index.Packages {
+			if config.language != "" && !strings.EqualFold(entry.Language, config.language) {
+				continue
+			}
+			if score := scoreSearchEntry(entry, config.query); score > 0 {
+				matches = append(matches, searchMatch{searchIndexEntry: entry, Registry: registryName, Score: score})
+			}
+		}
+	}
+	sortSearchMatches(matches)
+
+	result := searchResult{Query: config.query, Packages: []searchMatch{}}
+	if len(matches) == 0 {
+		fmt.Fprintf(messages, "No packages found matching '%s'\n", config.query)
+		return printJSONResult(result)
+	}
+	for _, match := range matches {
+		printSearchMatch(match)
+		result.Packages = append(result.Packages, match)
+	}
+	return printJSONResult(result)
+}
+
+// parseSearchArgs validates the query and filters and selects the registries to search
+func parseSearchArgs(cmd *cobra.Command, args []string) (*searchConfig, error) {
+	if len(args) > 1 {
+		return nil, newCodedError(ErrCodeInvalidArgs, "expected at most one query (e.g., cosm search json)")
+	}
+	config := &searchConfig{}
+	if len(args) == 1 {
+		config.query = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	config.language, _ = cmd.Flags().GetString("language")
+	config.refresh, _ = cmd.Flags().GetBool("refresh")
+	registryName, _ := cmd.Flags().GetString("registry")
+
+	var err error
+	config.registriesDir, err = getRegistriesDir()
+	if err != nil {
+		return nil, err
+	}
+	config.registryNames, err = loadRegistryNames(config.registriesDir)
+	if err != nil {
+		return nil, err
+	}
+	if registryName != "" {
+		if !contains(config.registryNames, registryName) {
+			return nil, newCodedError(ErrCodeNotFound, "registry '%s' not found in registries.json", registryName)
+		}
+		config.registryNames = []string{registryName}
+	}
+	return config, nil
+}
+
+// sortSearchMatches orders matches by descending score, then by name and registry
+func sortSearchMatches(matches []searchMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].Registry < matches[j].Registry
+	})
+}
+
+// printSearchMatch prints a package with its latest version, registry, description, available
+// major versions and Git URL
+func printSearchMatch(match searchMatch) {
+	latest := match.Latest
+	if latest == "" {
+		latest = "(no versions)"
+	}
+	fmt.Fprintf(messages, "%s %s [%s]\n", match.Name, latest, match.Registry)
+	if match.Description != "" {
+		fmt.Fprintf(messages, "  %s\n", match.Description)
+	}
+	if len(match.Majors) > 0 {
+		fmt.Fprintf(messages, "  majors: %s\n", strings.Join(match.Majors, ", "))
+	}
+	fmt.Fprintf(messages, "  giturl: %s\n", match.GitURL)
+}
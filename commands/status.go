@@ -1,7 +1,359 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"cosm/types"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
-// Status displays the current cosmic status
-func Status(cmd *cobra.Command, args []string) {
+	"github.com/spf13/cobra"
+)
+
+// ANSI escape codes used to highlight direct dependencies on a terminal
+const (
+	ansiBoldBlue = "\033[1;34m"
+	ansiReset    = "\033[0m"
+)
+
+// statusConfig holds configuration for displaying the project status
+type statusConfig struct {
+	project       *types.Project
+	cosmDir       string
+	registriesDir string
+	buildList     types.BuildList
+	highlight     bool
+}
+
+// dependencyStatus describes a dependency in the build list for display
+type dependencyStatus struct {
+	key          string
+	name         string
+	version      string
+	requirement  string // version required in Project.json, direct dependencies only
+	registryName string
+	develop      bool
+	path         string
+	update       string // newest registered version within the same major version
+	latestMajor  string // newest registered version of a newer major version
+	yanked       *types.YankedVersion
+}
+
+// Status displays the status of the project in the current directory
+func Status(cmd *cobra.Command, args []string) error {
+	config, err := parseStatusProjectArgs(args)
+	if err != nil {
+		return err
+	}
+
+	config.buildList, err = generateBuildList(config.project, config.registriesDir)
+	if err != nil {
+		return fmt.Errorf("failed to generate build list for %s: %v", config.project.Name, err)
+	}
+
+	direct, transitive, err := collectDependencyStatus(config)
+	if err != nil {
+		return err
+	}
+
+	printProjectStatus(config, direct, transitive)
+	printYankedWarnings(direct, transitive)
+	freshness, err := printBuildListFreshness(config)
+	if err != nil {
+		return err
+	}
+	return printJSONResult(newStatusResult(config, direct, transitive, freshness))
+}
+
+// parseStatusProjectArgs validates that status is run in a package root and loads the project
+func parseStatusProjectArgs(args []string) (*statusConfig, error) {
+	if len(args) != 0 {
+		return nil, newCodedError(ErrCodeInvalidArgs, "cosm status takes no arguments; run in package root with Project.json")
+	}
+	projectFile := "Project.json"
+	if _, err := os.Stat(projectFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil, newCodedError(ErrCodeNotFound, "Project.json not found in current directory")
+		}
+		return nil, fmt.Errorf("failed to stat Project.json: %v", err)
+	}
+	project, err := loadProject(projectFile)
+	if err != nil {
+		return nil, err
+	}
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cosm directory: %v", err)
+	}
+	return &statusConfig{
+		project:       project,
+		cosmDir:       cosmDir,
+		registriesDir: setupRegistriesDir(cosmDir),
+		highlight:     isTerminal(messages),
+	}, nil
+}
+
+// isTerminal reports whether the writer is a file attached to a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// collectDependencyStatus gathers the status of each build list entry, split into direct and
+// transitive dependencies and sorted by name
+func collectDependencyStatus(config *statusConfig) ([]dependencyStatus, []dependencyStatus, error) {
+	var direct, transitive []dependencyStatus
+	for key, entry := range config.buildList.Dependencies {
+		status := dependencyStatus{
+			key:     key,
+			name:    entry.Name,
+			version: entry.Version,
+			develop: entry.Develop,
+			path:    entry.Path,
+		}
+
+		// Registered versions are looked up with the required version for development checkouts
+		lookupVersion := entry.Version
+		dep, isDirect := config.project.Deps[key]
+		if isDirect {
+			status.requirement = dep.Version
+			if entry.Develop {
+				lookupVersion = dep.Version
+			}
+		}
+		regName, _, _, err := locateDependency(entry.Name, lookupVersion, entry.UUID, config.registriesDir)
+		if err == nil {
+			status.registryName = regName
+			yanked, err := loadYankedVersions(config.registriesDir, regName, entry.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			if yank, isYanked := yanked[lookupVersion]; isYanked {
+				status.yanked = &yank
+			}
+		}
+		if err := findAvailableUpdates(config, entry, lookupVersion, &status); err != nil {
+			return nil, nil, err
+		}
+
+		if isDirect {
+			direct = append(direct, status)
+		} else {
+			transitive = append(transitive, status)
+		}
+	}
+	sortDependencyStatus(direct)
+	sortDependencyStatus(transitive)
+	return direct, transitive, nil
+}
+
+// findAvailableUpdates records newer registered versions of a dependency, within the same
+// major version and across major versions
+func findAvailableUpdates(config *statusConfig, entry types.BuildListDependency, version string, status *dependencyStatus) error {
+	current, err := ParseSemVer(version)
+	if err != nil {
+		return fmt.Errorf("invalid version '%s' for dependency '%s': %v", version, entry.Name, err)
+	}
+	registered, err := findRegisteredVersions(entry.Name, entry.UUID, config.registriesDir)
+	if err != nil {
+		return err
+	}
+	var sameMajor, newerMajor []string
+	for _, rv := range registered {
+		s, err := ParseSemVer(rv.Version)
+		if err != nil || rv.Yanked {
+			continue
+		}
+		switch {
+		case s.Major == current.Major && compareSemVer(s, current) > 0:
+			sameMajor = append(sameMajor, rv.Version)
+		case s.Major > current.Major:
+			newerMajor = append(newerMajor, rv.Version)
+		}
+	}
+	if status.update, err = determineLatestVersion(sameMajor); err != nil {
+		return err
+	}
+	if status.latestMajor, err = determineLatestVersion(newerMajor); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sortDependencyStatus sorts dependencies by name, then by key
+func sortDependencyStatus(deps []dependencyStatus) {
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].name != deps[j].name {
+			return deps[i].name < deps[j].name
+		}
+		return deps[i].key < deps[j].key
+	})
+}
+
+// printProjectStatus displays the project information and its dependencies
+func printProjectStatus(config *statusConfig, direct, transitive []dependencyStatus) {
+	fmt.Fprintf(messages, "Project '%s' %s (UUID: %s)\n", config.project.Name, config.project.Version, config.project.UUID)
+	if len(direct) == 0 && len(transitive) == 0 {
+		fmt.Fprintln(messages, "  No dependencies.")
+		return
+	}
+	if len(direct) > 0 {
+		fmt.Fprintln(messages, "Direct dependencies:")
+		for _, dep := range direct {
+			fmt.Fprintf(messages, "  %s\n", formatDependencyStatus(config, dep, config.highlight))
+		}
+	}
+	if len(transitive) > 0 {
+		fmt.Fprintln(messages, "Transitive dependencies:")
+		for _, dep := range transitive {
+			fmt.Fprintf(messages, "  %s\n", formatDependencyStatus(config, dep, false))
+		}
+	}
+}
+
+// formatDependencyStatus formats a single dependency line
+func formatDependencyStatus(config *statusConfig, dep dependencyStatus, highlight bool) string {
+	name := dep.name
+	if highlight {
+		name = ansiBoldBlue + name + ansiReset
+	}
+	parts := []string{fmt.Sprintf("%s %s", name, dep.version)}
+	if dep.registryName != "" {
+		parts = append(parts, fmt.Sprintf("from '%s'", dep.registryName))
+	} else {
+		parts = append(parts, "from unknown registry")
+	}
+	var notes []string
+	if dep.requirement != "" && dep.requirement != dep.version && !dep.develop {
+		notes = append(notes, fmt.Sprintf("requires %s", dep.requirement))
+	}
+	if dep.develop {
+		notes = append(notes, fmt.Sprintf("develop: %s", filepath.Join(config.cosmDir, dep.path)))
+	}
+	if dep.yanked != nil {
+		notes = append(notes, "yanked")
+	}
+	if dep.update != "" {
+		notes = append(notes, fmt.Sprintf("update: %s", dep.update))
+	}
+	if dep.latestMajor != "" {
+		notes = append(notes, fmt.Sprintf("new major: %s", dep.latestMajor))
+	}
+	if len(notes) > 0 {
+		parts = append(parts, "["+strings.Join(notes, ", ")+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// printYankedWarnings warns about dependencies whose selected version is yanked in its registry
+func printYankedWarnings(direct, transitive []dependencyStatus) {
+	for _, dep := range append(append([]dependencyStatus{}, direct...), transitive...) {
+		if dep.yanked != nil {
+			fmt.Fprintf(messages, "Warning: %s %s is yanked in registry '%s'%s; upgrade or downgrade to another version\n", dep.name, dep.version, dep.registryName, formatYankReason(*dep.yanked))
+		}
+	}
+}
+
+// Build list states reported by printBuildListFreshness
+const (
+	buildListMissing  = "missing"
+	buildListStale    = "stale"
+	buildListUpToDate = "up-to-date"
+)
+
+// printBuildListFreshness warns if .cosm/buildlist.json is missing or out of date with Project.json,
+// the registries and development checkouts, and returns its state. Projects without dependencies
+// need no build list and have no state unless one was generated.
+func printBuildListFreshness(config *statusConfig) (string, error) {
+	buildListFile := filepath.Join(".cosm", "buildlist.json")
+	if _, err := os.Stat(buildListFile); os.IsNotExist(err) {
+		if len(config.project.Deps) == 0 {
+			return "", nil
+		}
+		fmt.Fprintf(messages, "Build list not generated yet; run 'cosm activate' to generate %s\n", buildListFile)
+		return buildListMissing, nil
+	}
+	digest, err := computeBuildListDigest(config.project, config.cosmDir, config.registriesDir)
+	if err != nil {
+		return "", err
+	}
+	stale, err := needsBuildListGeneration(digest)
+	if err != nil {
+		return "", err
+	}
+	if stale {
+		fmt.Fprintf(messages, "Warning: %s is out of date with Project.json or the registries; run 'cosm activate' to regenerate it\n", buildListFile)
+		return buildListStale, nil
+	}
+	return buildListUpToDate, nil
+}
+
+// statusResult is the JSON document printed by cosm status --output json
+type statusResult struct {
+	Project    projectResult          `json:"project"`
+	Direct     []dependencyStatusJSON `json:"direct"`
+	Transitive []dependencyStatusJSON `json:"transitive"`
+	BuildList  string                 `json:"buildlist,omitempty"`
+}
+
+// projectResult identifies a project in JSON output
+type projectResult struct {
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	Version string `json:"version"`
+}
+
+// dependencyStatusJSON is the JSON representation of a dependencyStatus
+type dependencyStatusJSON struct {
+	Key         string               `json:"key"`
+	Name        string               `json:"name"`
+	Version     string               `json:"version"`
+	Requirement string               `json:"requirement,omitempty"`
+	Registry    string               `json:"registry,omitempty"`
+	Develop     bool                 `json:"develop,omitempty"`
+	Path        string               `json:"path,omitempty"`
+	Update      string               `json:"update,omitempty"`
+	LatestMajor string               `json:"latest_major,omitempty"`
+	Yanked      *types.YankedVersion `json:"yanked,omitempty"`
+}
+
+// newStatusResult builds the JSON document for the project status
+func newStatusResult(config *statusConfig, direct, transitive []dependencyStatus, freshness string) statusResult {
+	convert := func(deps []dependencyStatus) []dependencyStatusJSON {
+		result := []dependencyStatusJSON{}
+		for _, dep := range deps {
+			path := ""
+			if dep.develop {
+				path = filepath.Join(config.cosmDir, dep.path)
+			}
+			result = append(result, dependencyStatusJSON{
+				Key:         dep.key,
+				Name:        dep.name,
+				Version:     dep.version,
+				Requirement: dep.requirement,
+				Registry:    dep.registryName,
+				Develop:     dep.develop,
+				Path:        path,
+				Update:      dep.update,
+				LatestMajor: dep.latestMajor,
+				Yanked:      dep.yanked,
+			})
+		}
+		return result
+	}
+	return statusResult{
+		Project:    projectResult{Name: config.project.Name, UUID: config.project.UUID, Version: config.project.Version},
+		Direct:     convert(direct),
+		Transitive: convert(transitive),
+		BuildList:  freshness,
+	}
 }
@@ -1,7 +1,314 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"cosm/types"
+	"fmt"
+	"sort"
 
-func Upgrade(cmd *cobra.Command, args []string) {
+	"github.com/spf13/cobra"
+)
 
+// upgradeConfig holds configuration for upgrading project dependencies
+type upgradeConfig struct {
+	packageName   string
+	query         *versionQuery
+	queryString   string
+	all           bool
+	latest        bool
+	refresh       bool
+	registriesDir string
+	project       *types.Project
+	projectFile   string
+}
+
+// upgradeTarget describes a dependency that is a candidate for an upgrade
+type upgradeTarget struct {
+	key        string // key in the project's deps or build list, formatted as <uuid>@<major version>
+	name       string
+	uuid       string
+	version    string // current requirement (direct) or selected version (transitive)
+	direct     bool
+	dependency types.Dependency
+}
+
+// requirementChangesResult is the JSON document printed by cosm upgrade and downgrade --output json
+type requirementChangesResult struct {
+	Action  string                    `json:"action"`            // "upgraded" or "downgraded"
+	Package string                    `json:"package,omitempty"` // empty for cosm upgrade --all
+	Changes []requirementChangeResult `json:"changes"`
+}
+
+// requirementChangeResult is a direct requirement that was added, changed or removed
+type requirementChangeResult struct {
+	Name string `json:"name"`
+	UUID string `json:"uuid"`
+	From string `json:"from,omitempty"` // empty if the requirement was added
+	To   string `json:"to,omitempty"`   // empty if the requirement was removed
+}
+
+// Upgrade upgrades a direct or transitive dependency, or all dependencies, to a newer version
+func Upgrade(cmd *cobra.Command, args []string) error {
+	config, err := parseUpgradeArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// Make sure registries are up-to-date before resolving versions
+	if err := updateAllRegistries(config.registriesDir, config.refresh); err != nil {
+		return err
+	}
+
+	targets, err := collectUpgradeTargets(config)
+	if err != nil {
+		return err
+	}
+
+	result := requirementChangesResult{Action: "upgraded", Package: config.packageName, Changes: []requirementChangeResult{}}
+	for _, target := range targets {
+		change, err := upgradeDependency(config, target)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			result.Changes = append(result.Changes, *change)
+		}
+	}
+
+	if len(result.Changes) > 0 {
+		if err := saveProject(config.project, config.projectFile); err != nil {
+			return err
+		}
+	}
+	return printJSONResult(result)
+}
+
+// parseUpgradeArgs validates arguments and flags and initializes the upgrade config
+func parseUpgradeArgs(cmd *cobra.Command, args []string) (*upgradeConfig, error) {
+	all, _ := cmd.Flags().GetBool("all")
+	latest, _ := cmd.Flags().GetBool("latest")
+	refresh, _ := cmd.Flags().GetBool("refresh")
+
+	config := &upgradeConfig{all: all, latest: latest, refresh: refresh, projectFile: "Project.json"}
+	if all {
+		if len(args) != 0 {
+			return nil, newCodedError(ErrCodeInvalidArgs, "no arguments allowed with --all flag")
+		}
+	} else {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, newCodedError(ErrCodeInvalidArgs, "expected a package name and optional version query (e.g., cosm upgrade <name> v<x.y>), or --all")
+		}
+		config.packageName = args[0]
+		if config.packageName == "" {
+			return nil, newCodedError(ErrCodeInvalidArgs, "package name cannot be empty")
+		}
+		if len(args) == 2 {
+			query, err := parseVersionQuery(args[1])
+			if err != nil {
+				return nil, withCode(ErrCodeInvalidArgs, err)
+			}
+			config.query = &query
+			config.queryString = args[1]
+		}
+	}
+
+	project, err := loadProject(config.projectFile)
+	if err != nil {
+		return nil, err
+	}
+	config.project = project
+
+	config.registriesDir, err = getRegistriesDir()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// collectUpgradeTargets determines which dependencies to consider for an upgrade
+func collectUpgradeTargets(config *upgradeConfig) ([]upgradeTarget, error) {
+	if config.all {
+		return collectAllUpgradeTargets(config)
+	}
+
+	// Direct dependencies take priority over transitive ones
+	if _, _, err := findDependencyKey(config.project, config.packageName); err == nil {
+		depKey, err := selectDependencyKey(config.project, config.packageName, "upgrade")
+		if err != nil {
+			return nil, err
+		}
+		target, err := newDirectUpgradeTarget(depKey, config.project.Deps[depKey])
+		if err != nil {
+			return nil, err
+		}
+		return []upgradeTarget{target}, nil
+	}
+
+	// Fall back to transitive dependencies from the build list
+	buildList, err := generateBuildList(config.project, config.registriesDir)
+	if err != nil {
+		return nil, err
+	}
+	var targets []upgradeTarget
+	for key, dep := range buildList.Dependencies {
+		if dep.Name == config.packageName {
+			targets = append(targets, upgradeTarget{key: key, name: dep.Name, uuid: dep.UUID, version: dep.Version})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, newCodedError(ErrCodeNotFound, "dependency '%s' not found in project or its build list", config.packageName)
+	}
+	if len(targets) > 1 {
+		return nil, fmt.Errorf("multiple major versions of transitive dependency '%s' found; add the one to upgrade with 'cosm add' first", config.packageName)
+	}
+	return targets, nil
+}
+
+// collectAllUpgradeTargets returns all direct and transitive dependencies, sorted by name and key
+func collectAllUpgradeTargets(config *upgradeConfig) ([]upgradeTarget, error) {
+	var targets []upgradeTarget
+	for key, dep := range config.project.Deps {
+		target, err := newDirectUpgradeTarget(key, dep)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	buildList, err := generateBuildList(config.project, config.registriesDir)
+	if err != nil {
+		return nil, err
+	}
+	for key, dep := range buildList.Dependencies {
+		if _, isDirect := config.project.Deps[key]; isDirect {
+			continue
+		}
+		targets = append(targets, upgradeTarget{key: key, name: dep.Name, uuid: dep.UUID, version: dep.Version})
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].name != targets[j].name {
+			return targets[i].name < targets[j].name
+		}
+		return targets[i].key < targets[j].key
+	})
+	return targets, nil
+}
+
+// newDirectUpgradeTarget creates an upgrade target for a direct dependency
+func newDirectUpgradeTarget(key string, dep types.Dependency) (upgradeTarget, error) {
+	depUUID, err := extractUUIDFromKey(key)
+	if err != nil {
+		return upgradeTarget{}, err
+	}
+	return upgradeTarget{key: key, name: dep.Name, uuid: depUUID, version: dep.Version, direct: true, dependency: dep}, nil
+}
+
+// resolveUpgradeVersion finds the newest registered version of the target allowed by the query and flags
+func resolveUpgradeVersion(config *upgradeConfig, target upgradeTarget) (string, error) {
+	current, err := ParseSemVer(target.version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version '%s' for dependency '%s': %v", target.version, target.name, err)
+	}
+	registered, err := findRegisteredVersions(target.name, target.uuid, config.registriesDir)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, rv := range registered {
+		s, err := ParseSemVer(rv.Version)
+		if err != nil || rv.Yanked {
+			continue
+		}
+		if config.query != nil {
+			if !config.query.matches(rv.Version) {
+				continue
+			}
+		} else if !config.latest && s.Major != current.Major {
+			continue
+		}
+		candidates = append(candidates, rv.Version)
+	}
+
+	version, err := determineLatestVersion(candidates)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		if config.query != nil {
+			return "", newCodedError(ErrCodeNotFound, "no registered version of '%s' matches '%s'", target.name, config.queryString)
+		}
+		return "", newCodedError(ErrCodeNotFound, "no registered versions found for '%s'", target.name)
+	}
+	return version, nil
+}
+
+// upgradeDependency resolves the new version for a target and records it in the project.
+// It returns the changed requirement, or nil if the project was not changed.
+func upgradeDependency(config *upgradeConfig, target upgradeTarget) (*requirementChangeResult, error) {
+	newVersion, err := resolveUpgradeVersion(config, target)
+	if err != nil {
+		return nil, err
+	}
+	currentMajor, err := GetMajorVersion(target.version)
+	if err != nil {
+		return nil, err
+	}
+	newMajor, err := GetMajorVersion(newVersion)
+	if err != nil {
+		return nil, err
+	}
+	if newMajor != currentMajor && !config.latest {
+		return nil, fmt.Errorf("version '%s' of '%s' is outside the current major version %s; use --latest to add major version %s", newVersion, target.name, currentMajor, newMajor)
+	}
+
+	if newMajor == currentMajor {
+		c, err := CompareSemVer(newVersion, target.version)
+		if err != nil {
+			return nil, err
+		}
+		if c < 0 {
+			if config.all {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("version '%s' of '%s' is older than the current version %s; use 'cosm downgrade' instead", newVersion, target.name, target.version)
+		}
+		if c == 0 {
+			if !config.all {
+				fmt.Fprintf(messages, "Dependency '%s' is already at %s\n", target.name, target.version)
+			}
+			return nil, nil
+		}
+	}
+
+	newKey := fmt.Sprintf("%s@%s", target.uuid, newMajor)
+	if existing, exists := config.project.Deps[newKey]; exists && newKey != target.key {
+		// The new major version is already a direct dependency; only raise its requirement
+		c, err := CompareSemVer(newVersion, existing.Version)
+		if err != nil {
+			return nil, err
+		}
+		if c <= 0 {
+			return nil, nil
+		}
+		target = upgradeTarget{key: newKey, name: existing.Name, uuid: target.uuid, version: existing.Version, direct: true, dependency: existing}
+	}
+
+	change := &requirementChangeResult{Name: target.name, UUID: target.uuid, To: newVersion}
+	dep := types.Dependency{Name: target.name, Version: newVersion}
+	if target.direct && newKey == target.key {
+		dep.Develop = target.dependency.Develop
+		change.From = target.version
+	}
+	config.project.Deps[newKey] = dep
+
+	switch {
+	case newKey != target.key:
+		fmt.Fprintf(messages, "Added dependency '%s' %s (new major version %s) to project\n", target.name, newVersion, newMajor)
+	case target.direct:
+		fmt.Fprintf(messages, "Upgraded dependency '%s' from %s to %s\n", target.name, target.version, newVersion)
+	default:
+		fmt.Fprintf(messages, "Upgraded transitive dependency '%s' from %s to %s (added as direct requirement)\n", target.name, target.version, newVersion)
+	}
+	return change, nil
 }
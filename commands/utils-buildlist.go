@@ -2,44 +2,327 @@ package commands
 
 import (
 	"cosm/types"
+	"crypto/sha256"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
-// generateBuildList creates a build list using Minimum Version Selection (MVS),
-// including direct dependencies from project.Deps and transitive dependencies
-// from dependency build lists, taking the maximum version for shared dependencies.
+// generateBuildList creates a build list using Minimum Version Selection (MVS). The
+// requirement graph is walked from the direct dependencies in project.Deps through the
+// deps recorded in each dependency's specs.json, and the maximum required version is
+// selected for every dependency reached. The build list snapshots stored in the registries
+// are only used to cross-check the result. Dependencies in development mode point to their
+// checkout in the depot's dev directory, and the checkout's own requirements take part in
+// the selection.
 func generateBuildList(project *types.Project, registriesDir string) (types.BuildList, error) {
-	buildList := types.BuildList{Dependencies: make(map[string]types.BuildListDependency)}
+	resolver, err := resolveProjectRequirements(project, registriesDir)
+	if err != nil {
+		return types.BuildList{}, err
+	}
+	return resolver.buildList()
+}
 
-	// Process direct dependencies
-	for key, dep := range project.Deps {
-		depUUID, err := extractUUIDFromKey(key)
-		if err != nil {
-			return types.BuildList{}, err
+// computeBuildListDigest computes a digest of the inputs that determine the build list of a
+// project: its normalized direct dependencies, the HEAD commits of the registries and the
+// Project.json of every development checkout. A change in any of them changes the digest.
+func computeBuildListDigest(project *types.Project, cosmDir, registriesDir string) (string, error) {
+	hash := sha256.New()
+	for _, key := range sortedDependencyKeys(project.Deps) {
+		dep := project.Deps[key]
+		fmt.Fprintf(hash, "dep %s %s %s %t\n", key, dep.Name, dep.Version, dep.Develop)
+		if !dep.Develop {
+			continue
 		}
-		specs, depBuildList, err := findDependency(dep.Name, dep.Version, depUUID, registriesDir)
+		majorVersion, err := GetMajorVersion(dep.Version)
 		if err != nil {
-			return types.BuildList{}, err
+			return "", fmt.Errorf("failed to get major version for '%s@%s': %v", dep.Name, dep.Version, err)
 		}
-		key, entry, err := createDependencyEntry(dep.Name, dep.Version, depUUID, specs)
+		data, err := os.ReadFile(filepath.Join(cosmDir, developPath(dep.Name, majorVersion), "Project.json"))
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read development checkout of '%s': %v", dep.Name, err)
+		}
+		fmt.Fprintf(hash, "develop %s %x\n", key, sha256.Sum256(data))
+	}
+	if len(project.Deps) == 0 {
+		return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil // Registries are not consulted
+	}
+
+	registryNames, err := loadRegistryNames(registriesDir)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(registryNames)
+	for _, name := range registryNames {
+		head, err := GitCommand(filepath.Join(registriesDir, name), "rev-parse", "HEAD")
 		if err != nil {
+			return "", fmt.Errorf("failed to get HEAD commit of registry '%s': %v", name, err)
+		}
+		fmt.Fprintf(hash, "registry %s %s\n", name, strings.TrimSpace(head))
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// resolveProjectRequirements walks the requirement graph of a project and returns the resolver
+// holding the selected versions and the requirements that led to them
+func resolveProjectRequirements(project *types.Project, registriesDir string) (*buildListResolver, error) {
+	resolver := newBuildListResolver(registriesDir)
+
+	// Process direct dependencies
+	for _, key := range sortedDependencyKeys(project.Deps) {
+		dep := project.Deps[key]
+		if dep.Develop {
+			depUUID, err := extractUUIDFromKey(key)
+			if err != nil {
+				return nil, err
+			}
+			key, entry, devProject, err := createDevelopEntry(dep.Name, dep.Version, depUUID, registriesDir)
+			if err != nil {
+				return nil, err
+			}
+			resolver.develop[key] = entry
+			devNode := developNode(key)
+			resolver.requirements[rootNode] = append(resolver.requirements[rootNode], requirement{key: key, name: dep.Name, version: entry.Version, node: devNode})
+			// Process requirements of the development checkout
+			for _, devKey := range sortedDependencyKeys(devProject.Deps) {
+				requiredBy := []string{fmt.Sprintf("%s@%s (development checkout)", dep.Name, entry.Version)}
+				if err := resolver.walk(devNode, devKey, devProject.Deps[devKey], requiredBy); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := resolver.walk(rootNode, key, dep, nil); err != nil {
+			return nil, err
+		}
+	}
+	return resolver, nil
+}
+
+// resolveDependencyBuildList computes the build list of a registered package version by
+// walking the requirement graph of its specs.json
+func resolveDependencyBuildList(depName, depVersion, depUUID, registriesDir string) (types.BuildList, error) {
+	specs, _, err := findDependency(depName, depVersion, depUUID, registriesDir)
+	if err != nil {
+		return types.BuildList{}, err
+	}
+	resolver := newBuildListResolver(registriesDir)
+	requiredBy := []string{fmt.Sprintf("%s@%s", depName, depVersion)}
+	for _, key := range sortedDependencyKeys(specs.Deps) {
+		if err := resolver.walk(rootNode, key, specs.Deps[key], requiredBy); err != nil {
 			return types.BuildList{}, err
 		}
-		if err := mergeDependencyEntry(&buildList, key, entry); err != nil {
+	}
+	return resolver.buildList()
+}
+
+// resolveDevelopBuildList computes the build list of the development checkout of a dependency
+// by walking the requirement graph of its Project.json
+func resolveDevelopBuildList(depName, depVersion, depUUID, registriesDir string) (types.BuildList, error) {
+	_, entry, devProject, err := createDevelopEntry(depName, depVersion, depUUID, registriesDir)
+	if err != nil {
+		return types.BuildList{}, err
+	}
+	resolver := newBuildListResolver(registriesDir)
+	requiredBy := []string{fmt.Sprintf("%s@%s (development checkout)", depName, entry.Version)}
+	for _, key := range sortedDependencyKeys(devProject.Deps) {
+		if err := resolver.walk(rootNode, key, devProject.Deps[key], requiredBy); err != nil {
 			return types.BuildList{}, err
 		}
-		// Process transitive dependencies
-		for transKey, transDep := range depBuildList.Dependencies {
-			if err := mergeDependencyEntry(&buildList, transKey, transDep); err != nil {
-				return types.BuildList{}, err
-			}
+	}
+	return resolver.buildList()
+}
+
+// buildListResolver walks the requirement graph of registered package versions and
+// selects the maximum required version of each dependency
+type buildListResolver struct {
+	registriesDir string
+	selected      map[string]types.BuildListDependency // selected entries keyed by <uuid>@<major version>
+	visited       map[string]bool                      // walked package versions keyed by <uuid>@<version>
+	develop       map[string]types.BuildListDependency // development checkouts keyed by <uuid>@<major version>
+	requirements  map[string][]requirement             // requirements keyed by the requiring node
+	snapshots     []buildListSnapshot
+}
+
+// rootNode identifies the project itself in the requirement graph
+const rootNode = ""
+
+// requirement records that a node of the requirement graph requires a dependency version
+type requirement struct {
+	key     string // <uuid>@<major version>
+	name    string
+	version string
+	node    string // the required node, <uuid>@<version> or develop:<key> for development checkouts
+}
+
+// developNode returns the requirement graph node of a development checkout
+func developNode(key string) string {
+	return "develop:" + key
+}
+
+// buildListSnapshot is the build list stored in a registry for a walked package version
+type buildListSnapshot struct {
+	name         string
+	version      string
+	registryName string
+	buildList    types.BuildList
+}
+
+// newBuildListResolver creates a resolver reading package versions from the given registries
+func newBuildListResolver(registriesDir string) *buildListResolver {
+	return &buildListResolver{
+		registriesDir: registriesDir,
+		selected:      make(map[string]types.BuildListDependency),
+		visited:       make(map[string]bool),
+		develop:       make(map[string]types.BuildListDependency),
+		requirements:  make(map[string][]requirement),
+	}
+}
+
+// walk records that parent requires a dependency version, and adds the version and,
+// recursively, its own requirements to the selection. Each package version is walked once,
+// which also breaks dependency cycles. requiredBy holds the chain of package versions that
+// led to this requirement.
+func (r *buildListResolver) walk(parent, key string, dep types.Dependency, requiredBy []string) error {
+	depUUID, err := extractUUIDFromKey(key)
+	if err != nil {
+		return err
+	}
+	node := fmt.Sprintf("%s@%s", depUUID, dep.Version)
+	r.requirements[parent] = append(r.requirements[parent], requirement{key: key, name: dep.Name, version: dep.Version, node: node})
+	if r.visited[node] {
+		return nil
+	}
+	r.visited[node] = true
+
+	regName, specs, snapshot, err := locateDependency(dep.Name, dep.Version, depUUID, r.registriesDir)
+	if err != nil {
+		if len(requiredBy) > 0 {
+			return fmt.Errorf("%v (required by %s)", err, strings.Join(requiredBy, " -> "))
+		}
+		return err
+	}
+	key, entry, err := createDependencyEntry(dep.Name, dep.Version, depUUID, specs)
+	if err != nil {
+		return err
+	}
+	if err := mergeDependencyEntry(&types.BuildList{Dependencies: r.selected}, key, entry); err != nil {
+		return err
+	}
+	r.snapshots = append(r.snapshots, buildListSnapshot{name: dep.Name, version: dep.Version, registryName: regName, buildList: snapshot})
+
+	// Process transitive dependencies
+	chain := append(append([]string{}, requiredBy...), fmt.Sprintf("%s@%s", dep.Name, dep.Version))
+	for _, transKey := range sortedDependencyKeys(specs.Deps) {
+		if err := r.walk(node, transKey, specs.Deps[transKey], chain); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+// buildList returns the selected dependencies after checking them against the registry
+// snapshots of every walked package version
+func (r *buildListResolver) buildList() (types.BuildList, error) {
+	if err := r.verifySnapshots(); err != nil {
+		return types.BuildList{}, err
+	}
+	buildList := types.BuildList{Dependencies: make(map[string]types.BuildListDependency, len(r.selected))}
+	for key, entry := range r.selected {
+		buildList.Dependencies[key] = entry
+	}
+	// Development checkouts take precedence over any registered version
+	for key, entry := range r.develop {
+		buildList.Dependencies[key] = entry
+	}
 	return buildList, nil
 }
 
+// verifySnapshots ensures no registry snapshot requires a dependency, or a version of it,
+// that the requirement graph does not reach. Such a snapshot is out of date.
+func (r *buildListResolver) verifySnapshots() error {
+	for _, snapshot := range r.snapshots {
+		for _, key := range sortedBuildListKeys(snapshot.buildList) {
+			entry := snapshot.buildList.Dependencies[key]
+			selected, exists := r.selected[key]
+			if !exists {
+				return fmt.Errorf("build list of '%s@%s' in registry '%s' requires '%s@%s', which is not reachable from the dependencies in its specs; the registry's build list is out of date", snapshot.name, snapshot.version, snapshot.registryName, entry.Name, entry.Version)
+			}
+			c, err := CompareSemVer(entry.Version, selected.Version)
+			if err != nil {
+				return fmt.Errorf("failed to compare versions for '%s': %v", entry.Name, err)
+			}
+			if c > 0 {
+				return fmt.Errorf("build list of '%s@%s' in registry '%s' requires '%s@%s', but its specs only require up to %s; the registry's build list is out of date", snapshot.name, snapshot.version, snapshot.registryName, entry.Name, entry.Version, selected.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedDependencyKeys returns the keys of a dependency map in sorted order
+func sortedDependencyKeys(deps map[string]types.Dependency) []string {
+	keys := make([]string, 0, len(deps))
+	for key := range deps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedBuildListKeys returns the keys of a build list in sorted order
+func sortedBuildListKeys(buildList types.BuildList) []string {
+	keys := make([]string, 0, len(buildList.Dependencies))
+	for key := range buildList.Dependencies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// createDevelopEntry builds a BuildListDependency entry pointing to the development checkout
+// of a dependency, and returns the checkout's project
+func createDevelopEntry(depName, depVersion, depUUID, registriesDir string) (string, types.BuildListDependency, *types.Project, error) {
+	majorVersion, err := GetMajorVersion(depVersion)
+	if err != nil {
+		return "", types.BuildListDependency{}, nil, fmt.Errorf("failed to get major version for '%s@%s': %v", depName, depVersion, err)
+	}
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return "", types.BuildListDependency{}, nil, err
+	}
+	devPath := developPath(depName, majorVersion)
+	devProject, err := loadProjectFromDir(filepath.Join(cosmDir, devPath))
+	if err != nil {
+		return "", types.BuildListDependency{}, nil, fmt.Errorf("development checkout of '%s' not found in %s (run 'cosm develop %s'): %v", depName, filepath.Join(cosmDir, devPath), depName, err)
+	}
+	specs, _, err := findDependency(depName, depVersion, depUUID, registriesDir)
+	if err != nil {
+		return "", types.BuildListDependency{}, nil, err
+	}
+	version := devProject.Version
+	if version == "" {
+		version = depVersion
+	}
+	key := fmt.Sprintf("%s@%s", depUUID, majorVersion)
+	entry := types.BuildListDependency{
+		Name:    depName,
+		UUID:    depUUID,
+		Version: version,
+		GitURL:  specs.GitURL,
+		Path:    devPath,
+		Develop: true,
+	}
+	return key, entry, devProject, nil
+}
+
+// developPath returns the path of a development checkout relative to the depot, dev/<name>@<major version>
+func developPath(packageName, majorVersion string) string {
+	return filepath.Join("dev", fmt.Sprintf("%s@%s", packageName, majorVersion))
+}
+
 // extractUUIDFromKey extracts the UUID from a dependency key formatted as <uuid>@<major version>
 func extractUUIDFromKey(key string) (string, error) {
 	parts := strings.Split(key, "@")
@@ -54,9 +337,16 @@ func extractUUIDFromKey(key string) (string, error) {
 
 // findDependency searches all registries for a dependency with matching name, UUID, and version
 func findDependency(depName, depVersion, depUUID, registriesDir string) (types.Specs, types.BuildList, error) {
+	_, specs, buildList, err := locateDependency(depName, depVersion, depUUID, registriesDir)
+	return specs, buildList, err
+}
+
+// locateDependency searches all registries for a dependency with matching name, UUID, and version,
+// and returns the name of the registry it was found in together with its specs and build list
+func locateDependency(depName, depVersion, depUUID, registriesDir string) (string, types.Specs, types.BuildList, error) {
 	registryNames, err := loadRegistryNames(registriesDir)
 	if err != nil {
-		return types.Specs{}, types.BuildList{}, fmt.Errorf("failed to load registry names: %v", err)
+		return "", types.Specs{}, types.BuildList{}, fmt.Errorf("failed to load registry names: %v", err)
 	}
 
 	for _, regName := range registryNames {
@@ -74,12 +364,12 @@ func findDependency(depName, depVersion, depUUID, registriesDir string) (types.S
 			}
 			buildList, err := loadBuildList(registriesDir, regName, depName, depVersion)
 			if err != nil {
-				return types.Specs{}, types.BuildList{}, fmt.Errorf("failed to load build list for '%s@%s' in registry '%s': %v", depName, depVersion, regName, err)
+				return "", types.Specs{}, types.BuildList{}, fmt.Errorf("failed to load build list for '%s@%s' in registry '%s': %v", depName, depVersion, regName, err)
 			}
-			return specs, buildList, nil
+			return regName, specs, buildList, nil
 		}
 	}
-	return types.Specs{}, types.BuildList{}, fmt.Errorf("dependency '%s@%s' with UUID '%s' not found in any registry", depName, depVersion, depUUID)
+	return "", types.Specs{}, types.BuildList{}, fmt.Errorf("dependency '%s@%s' with UUID '%s' not found in any registry", depName, depVersion, depUUID)
 }
 
 // createDependencyEntry builds a BuildListDependency entry with its key
@@ -90,12 +380,13 @@ func createDependencyEntry(depName, depVersion, depUUID string, specs types.Spec
 	}
 	key := fmt.Sprintf("%s@%s", depUUID, majorVersion)
 	entry := types.BuildListDependency{
-		Name:    depName,
-		UUID:    depUUID,
-		Version: depVersion,
-		GitURL:  specs.GitURL,
-		SHA1:    specs.SHA1,
-		Path:    fmt.Sprintf("packages/%s/%s", depName, specs.SHA1),
+		Name:     depName,
+		UUID:     depUUID,
+		Version:  depVersion,
+		GitURL:   specs.GitURL,
+		SHA1:     specs.SHA1,
+		TreeHash: specs.TreeHash,
+		Path:     fmt.Sprintf("packages/%s/%s", depName, specs.SHA1),
 	}
 	return key, entry, nil
 }
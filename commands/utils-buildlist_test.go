@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"cosm/types"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPackage describes a registered package version used to build a test registry
+type testPackage struct {
+	name, uuid, version string
+	deps                map[string]types.Dependency
+	snapshot            map[string]types.BuildListDependency // registry build list, may be nil
+}
+
+// writeTestRegistry writes a registry named "reg" containing the given package versions
+func writeTestRegistry(t *testing.T, packages []testPackage) string {
+	t.Helper()
+	registriesDir := t.TempDir()
+	writeTestJSON(t, filepath.Join(registriesDir, "registries.json"), []string{"reg"})
+	registry := types.Registry{Name: "reg", Packages: make(map[string]types.PackageInfo)}
+	for _, pkg := range packages {
+		registry.Packages[pkg.name] = types.PackageInfo{UUID: pkg.uuid}
+		versionDir := filepath.Join(registriesDir, "reg", strings.ToUpper(pkg.name[:1]), pkg.name, pkg.version)
+		writeTestJSON(t, filepath.Join(versionDir, "specs.json"), types.Specs{Name: pkg.name, UUID: pkg.uuid, Version: pkg.version, SHA1: pkg.version, Deps: pkg.deps})
+		if pkg.snapshot != nil {
+			writeTestJSON(t, filepath.Join(versionDir, "buildlist.json"), types.BuildList{Dependencies: pkg.snapshot})
+		}
+	}
+	writeTestJSON(t, filepath.Join(registriesDir, "reg", "registry.json"), registry)
+	return registriesDir
+}
+
+// writeTestJSON marshals v to a JSON file, creating parent directories
+func writeTestJSON(t *testing.T, file string, v interface{}) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", file, err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal %s: %v", file, err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
+
+// TestGenerateBuildListWalk tests the MVS walk over specs with shared and cyclic dependencies
+func TestGenerateBuildListWalk(t *testing.T) {
+	// A@v1.0.0 requires B@v1.0.0 and C@v1.0.0; C@v1.0.0 requires B@v1.1.0; B@v1.1.0 requires A@v1.0.0
+	registriesDir := writeTestRegistry(t, []testPackage{
+		{name: "A", uuid: "ua", version: "v1.0.0", deps: map[string]types.Dependency{
+			"ub@v1": {Name: "B", Version: "v1.0.0"},
+			"uc@v1": {Name: "C", Version: "v1.0.0"},
+		}},
+		{name: "B", uuid: "ub", version: "v1.0.0"},
+		{name: "B", uuid: "ub", version: "v1.1.0", deps: map[string]types.Dependency{
+			"ua@v1": {Name: "A", Version: "v1.0.0"},
+		}},
+		{name: "C", uuid: "uc", version: "v1.0.0", deps: map[string]types.Dependency{
+			"ub@v1": {Name: "B", Version: "v1.1.0"},
+		}},
+	})
+
+	project := &types.Project{Deps: map[string]types.Dependency{"ua@v1": {Name: "A", Version: "v1.0.0"}}}
+	buildList, err := generateBuildList(project, registriesDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"ua@v1": "v1.0.0", "ub@v1": "v1.1.0", "uc@v1": "v1.0.0"}
+	if len(buildList.Dependencies) != len(expected) {
+		t.Fatalf("Expected %d dependencies, got %+v", len(expected), buildList.Dependencies)
+	}
+	for key, version := range expected {
+		if got := buildList.Dependencies[key].Version; got != version {
+			t.Errorf("Expected %s at %s, got %q", key, version, got)
+		}
+	}
+}
+
+// TestGenerateBuildListErrors tests errors for missing versions and out-of-date registry build lists
+func TestGenerateBuildListErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []testPackage
+		expected string
+	}{
+		{
+			name: "missing_transitive_version",
+			packages: []testPackage{
+				{name: "A", uuid: "ua", version: "v1.0.0", deps: map[string]types.Dependency{"ub@v1": {Name: "B", Version: "v1.2.0"}}},
+				{name: "B", uuid: "ub", version: "v1.0.0"},
+			},
+			expected: "dependency 'B@v1.2.0' with UUID 'ub' not found in any registry (required by A@v1.0.0)",
+		},
+		{
+			name: "stale_snapshot_version",
+			packages: []testPackage{
+				{name: "A", uuid: "ua", version: "v1.0.0", deps: map[string]types.Dependency{"ub@v1": {Name: "B", Version: "v1.0.0"}},
+					snapshot: map[string]types.BuildListDependency{"ub@v1": {Name: "B", UUID: "ub", Version: "v1.1.0"}}},
+				{name: "B", uuid: "ub", version: "v1.0.0"},
+			},
+			expected: "build list of 'A@v1.0.0' in registry 'reg' requires 'B@v1.1.0', but its specs only require up to v1.0.0",
+		},
+		{
+			name: "stale_snapshot_dependency",
+			packages: []testPackage{
+				{name: "A", uuid: "ua", version: "v1.0.0",
+					snapshot: map[string]types.BuildListDependency{"ub@v1": {Name: "B", UUID: "ub", Version: "v1.0.0"}}},
+			},
+			expected: "build list of 'A@v1.0.0' in registry 'reg' requires 'B@v1.0.0', which is not reachable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registriesDir := writeTestRegistry(t, tt.packages)
+			project := &types.Project{Deps: map[string]types.Dependency{"ua@v1": {Name: "A", Version: "v1.0.0"}}}
+			_, err := generateBuildList(project, registriesDir)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
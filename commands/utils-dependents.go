@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// dependentsIndex is the reverse-dependency index in dependents.json at the root of a registry. It
+// maps <uuid>@<version> to the registered versions of the registry that require that version,
+// directly or through their build list.
+type dependentsIndex map[string][]registryDependent
+
+// registryDependent is a registered version that requires a package version
+type registryDependent struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Direct  bool   `json:"direct"` // required by specs.json rather than only by buildlist.json
+}
+
+// String returns the dependent as name@version
+func (d registryDependent) String() string {
+	return d.Name + "@" + d.Version
+}
+
+// loadDependentsIndex loads dependents.json of a registry, building the index from the package
+// directories of registries that do not have one yet
+func loadDependentsIndex(registriesDir, registryName string) (dependentsIndex, error) {
+	indexFile := filepath.Join(registriesDir, registryName, "dependents.json")
+	data, err := os.ReadFile(indexFile)
+	if os.IsNotExist(err) {
+		return buildDependentsIndex(registriesDir, registryName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", indexFile, err)
+	}
+	index := make(dependentsIndex)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", indexFile, err)
+	}
+	return index, nil
+}
+
+// buildDependentsIndex collects the requirements of every registered version of a registry
+func buildDependentsIndex(registriesDir, registryName string) (dependentsIndex, error) {
+	registry, _, err := LoadRegistryMetadata(registriesDir, registryName)
+	if err != nil {
+		return nil, err
+	}
+	index := make(dependentsIndex)
+	for packageName := range registry.Packages {
+		versions, err := loadVersions(registriesDir, registryName, packageName)
+		if err != nil {
+			return nil, err
+		}
+		if err := index.addVersions(registriesDir, registryName, packageName, versions); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// saveDependentsIndex writes dependents.json of a registry
+func saveDependentsIndex(registriesDir, registryName string, index dependentsIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dependents.json: %v", err)
+	}
+	indexFile := filepath.Join(registriesDir, registryName, "dependents.json")
+	if err := os.WriteFile(indexFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", indexFile, err)
+	}
+	return nil
+}
+
+// addVersions records the requirements of the given versions of a package
+func (index dependentsIndex) addVersions(registriesDir, registryName, packageName string, versions []string) error {
+	for _, version := range versions {
+		specs, err := loadSpecs(registriesDir, registryName, packageName, version)
+		if err != nil {
+			return fmt.Errorf("failed to load specs for '%s@%s' in registry '%s': %v", packageName, version, registryName, err)
+		}
+		buildList, err := loadBuildList(registriesDir, registryName, packageName, version)
+		if err != nil {
+			return err
+		}
+		dependent := registryDependent{Name: packageName, Version: version}
+		for _, entry := range buildList.Dependencies {
+			index.add(entry.UUID+"@"+entry.Version, dependent)
+		}
+		dependent.Direct = true
+		for key, dep := range specs.Deps {
+			depUUID, err := extractUUIDFromKey(key)
+			if err != nil {
+				return fmt.Errorf("invalid requirement of '%s@%s': %v", packageName, version, err)
+			}
+			index.add(depUUID+"@"+dep.Version, dependent)
+		}
+	}
+	return nil
+}
+
+// add records a dependent of a package version once, keeping the dependents sorted
+func (index dependentsIndex) add(key string, dependent registryDependent) {
+	for i, existing := range index[key] {
+		if existing.Name == dependent.Name && existing.Version == dependent.Version {
+			index[key][i].Direct = existing.Direct || dependent.Direct
+			return
+		}
+	}
+	index[key] = append(index[key], dependent)
+	sortRegistryDependents(index[key])
+}
+
+// removeVersions forgets the requirements of the given versions of a package. Their own dependents
+// are kept, so that they are still reported when a removed version is registered again.
+func (index dependentsIndex) removeVersions(packageName string, versions []string) {
+	for key, dependents := range index {
+		var kept []registryDependent
+		for _, dependent := range dependents {
+			if dependent.Name != packageName || !contains(versions, dependent.Version) {
+				kept = append(kept, dependent)
+			}
+		}
+		if len(kept) == 0 {
+			delete(index, key)
+		} else {
+			index[key] = kept
+		}
+	}
+}
+
+// dependentsOf returns the registered versions of other packages that require a package version
+func (index dependentsIndex) dependentsOf(packageName, packageUUID, version string) []registryDependent {
+	dependents := []registryDependent{}
+	for _, dependent := range index[packageUUID+"@"+version] {
+		if dependent.Name != packageName {
+			dependents = append(dependents, dependent)
+		}
+	}
+	return dependents
+}
+
+// updateDependentsIndex adds the requirements of newly registered versions of a package to
+// dependents.json; the caller must hold the registry lock
+func updateDependentsIndex(registriesDir, registryName, packageName string, versions []string) error {
+	index, err := loadDependentsIndex(registriesDir, registryName)
+	if err != nil {
+		return err
+	}
+	if err := index.addVersions(registriesDir, registryName, packageName, versions); err != nil {
+		return err
+	}
+	return saveDependentsIndex(registriesDir, registryName, index)
+}
+
+// sortRegistryDependents orders dependents by name and version precedence
+func sortRegistryDependents(dependents []registryDependent) {
+	sort.Slice(dependents, func(i, j int) bool {
+		if dependents[i].Name != dependents[j].Name {
+			return dependents[i].Name < dependents[j].Name
+		}
+		cmp, _ := CompareSemVer(dependents[i].Version, dependents[j].Version)
+		return cmp < 0
+	})
+}
+
+// formatDependents returns the dependents as a list of name@version
+func formatDependents(dependents []registryDependent) []string {
+	formatted := make([]string, len(dependents))
+	for i, dependent := range dependents {
+		formatted[i] = dependent.String()
+	}
+	return formatted
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDependentsIndex tests that dependents are recorded once, sorted, and forgotten when the
+// dependent version is removed
+func TestDependentsIndex(t *testing.T) {
+	index := make(dependentsIndex)
+	index.add("uuid-c@v0.1.0", registryDependent{Name: "pkgB", Version: "v0.10.0"})
+	index.add("uuid-c@v0.1.0", registryDependent{Name: "pkgB", Version: "v0.2.0", Direct: true})
+	index.add("uuid-c@v0.1.0", registryDependent{Name: "pkgA", Version: "v1.0.0"})
+	index.add("uuid-c@v0.1.0", registryDependent{Name: "pkgB", Version: "v0.10.0", Direct: true})
+	index.add("uuid-c@v0.1.0", registryDependent{Name: "pkgC", Version: "v0.2.0"})
+	index.add("uuid-b@v0.2.0", registryDependent{Name: "pkgA", Version: "v1.0.0", Direct: true})
+
+	expected := []registryDependent{
+		{Name: "pkgA", Version: "v1.0.0"},
+		{Name: "pkgB", Version: "v0.2.0", Direct: true},
+		{Name: "pkgB", Version: "v0.10.0", Direct: true},
+	}
+	if got := index.dependentsOf("pkgC", "uuid-c", "v0.1.0"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("dependentsOf = %v, expected %v", got, expected)
+	}
+
+	index.removeVersions("pkgA", []string{"v1.0.0"})
+	if _, ok := index["uuid-b@v0.2.0"]; ok {
+		t.Errorf("Expected entry without dependents to be removed, got %v", index)
+	}
+	if got := formatDependents(index.dependentsOf("pkgC", "uuid-c", "v0.1.0")); !reflect.DeepEqual(got, []string{"pkgB@v0.2.0", "pkgB@v0.10.0"}) {
+		t.Errorf("Expected only pkgB to remain, got %v", got)
+	}
+
+	// Dependents of a removed version are kept for when it is registered again
+	index.add("uuid-d@v1.0.0", registryDependent{Name: "pkgC", Version: "v0.1.0", Direct: true})
+	index.removeVersions("pkgC", []string{"v0.1.0"})
+	if _, ok := index["uuid-d@v1.0.0"]; ok {
+		t.Errorf("Expected requirements of removed version to be forgotten, got %v", index)
+	}
+	if got := formatDependents(index.dependentsOf("pkgC", "uuid-c", "v0.1.0")); !reflect.DeepEqual(got, []string{"pkgB@v0.2.0", "pkgB@v0.10.0"}) {
+		t.Errorf("Expected dependents of removed version to be kept, got %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"archive/tar"
 	"bufio"
 	"cosm/types"
 	"encoding/json"
@@ -8,7 +9,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // setupRegistriesDir constructs the registries directory path
@@ -145,7 +149,7 @@ func initializeCosmDepotVar() error {
 
 	// Prompt for location
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("COSM_DEPOT_PATH is not set or invalid. Enter the location for the .cosm directory (default: %s): ", defaultPath)
+	fmt.Fprintf(messages, "COSM_DEPOT_PATH is not set or invalid. Enter the location for the .cosm directory (default: %s): ", defaultPath)
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("failed to read input: %v", err)
@@ -188,8 +192,8 @@ func initializeCosmDepotVar() error {
 	}
 
 	// Print confirmation with export instruction
-	fmt.Printf("COSM_DEPOT_PATH set to %s and added to shell profile\n", depotPath)
-	fmt.Printf("To apply COSM_DEPOT_PATH in the current session, run: export COSM_DEPOT_PATH=%q\n", depotPath)
+	fmt.Fprintf(messages, "COSM_DEPOT_PATH set to %s and added to shell profile\n", depotPath)
+	fmt.Fprintf(messages, "To apply COSM_DEPOT_PATH in the current session, run: export COSM_DEPOT_PATH=%q\n", depotPath)
 	return nil
 }
 
@@ -334,7 +338,7 @@ func ensureProjectFileDoesNotExist(projectFile string) error {
 // loadProject loads and parses Project.json from the specified file path.
 func loadProject(filename string) (*types.Project, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no Project.json found at %s", filename)
+		return nil, newCodedError(ErrCodeNotFound, "no Project.json found at %s", filename)
 	}
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -380,6 +384,99 @@ func saveRegistryNames(registryNames []string, registriesDir string) error {
 	return nil
 }
 
+// loadProjectIndex loads the directories of activated projects from projects.json in the depot
+func loadProjectIndex(cosmDir string) ([]string, error) {
+	indexFile := filepath.Join(cosmDir, "projects.json")
+	data, err := os.ReadFile(indexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil // No project activated yet
+		}
+		return nil, fmt.Errorf("failed to read projects.json: %v", err)
+	}
+	var projectDirs []string
+	if err := json.Unmarshal(data, &projectDirs); err != nil {
+		return nil, fmt.Errorf("failed to parse projects.json: %v", err)
+	}
+	return projectDirs, nil
+}
+
+// saveProjectIndex writes the sorted directories of activated projects to projects.json
+func saveProjectIndex(cosmDir string, projectDirs []string) error {
+	sort.Strings(projectDirs)
+	data, err := json.MarshalIndent(projectDirs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal projects.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cosmDir, "projects.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write projects.json: %v", err)
+	}
+	return nil
+}
+
+// registerProject adds a project directory to the project index, so that cosm gc keeps the
+// packages of its build list
+func registerProject(cosmDir, projectDir string) error {
+	unlock, err := lockProjectIndex(cosmDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	projectDirs, err := loadProjectIndex(cosmDir)
+	if err != nil {
+		return err
+	}
+	if contains(projectDirs, projectDir) {
+		return nil
+	}
+	return saveProjectIndex(cosmDir, append(projectDirs, projectDir))
+}
+
+// loadRegistryUpdateTimes loads the time of the last pull of each registry from last_updated.json
+// in the registries directory
+func loadRegistryUpdateTimes(registriesDir string) (map[string]time.Time, error) {
+	updateTimes := make(map[string]time.Time)
+	data, err := os.ReadFile(filepath.Join(registriesDir, "last_updated.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return updateTimes, nil // No registry pulled yet
+		}
+		return nil, fmt.Errorf("failed to read last_updated.json: %v", err)
+	}
+	if err := json.Unmarshal(data, &updateTimes); err != nil {
+		return nil, fmt.Errorf("failed to parse last_updated.json: %v", err)
+	}
+	return updateTimes, nil
+}
+
+// lastUpdatedMutex serializes the updates of last_updated.json by the goroutines of
+// updateRegistries, since the depot lock does not guard them on platforms without flock
+var lastUpdatedMutex sync.Mutex
+
+// recordRegistryUpdate stores the time of the last pull of a registry in last_updated.json
+func recordRegistryUpdate(registriesDir, registryName string, updated time.Time) error {
+	lastUpdatedMutex.Lock()
+	defer lastUpdatedMutex.Unlock()
+	unlock, err := acquireDepotLock(filepath.Dir(registriesDir), "last-updated")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	updateTimes, err := loadRegistryUpdateTimes(registriesDir)
+	if err != nil {
+		return err
+	}
+	updateTimes[registryName] = updated.UTC()
+	data, err := json.MarshalIndent(updateTimes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal last_updated.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(registriesDir, "last_updated.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write last_updated.json: %v", err)
+	}
+	return nil
+}
+
 // saveRegistryMetadata marshals and writes the registry metadata to registry.json
 func saveRegistryMetadata(registry types.Registry, filename string) error {
 	data, err := json.MarshalIndent(registry, "", "  ")
@@ -392,8 +489,56 @@ func saveRegistryMetadata(registry types.Registry, filename string) error {
 	return nil
 }
 
-// savePackageVersions marshals and writes the list of package versions to versions.json
-func savePackageVersions(versions []string, versionsFile string) error {
+// legacyVersionsFile is the format of versions.json with yanked versions written by earlier
+// versions of cosm; it is still read and replaced by a plain list and yanked.json when saved
+type legacyVersionsFile struct {
+	Versions []string                       `json:"versions"`
+	Yanked   map[string]types.YankedVersion `json:"yanked"`
+}
+
+// yankedFilePath returns the path of yanked.json next to versions.json. Yanked versions are kept
+// out of versions.json so that it stays a plain list of versions readable by any cosm version.
+func yankedFilePath(versionsFile string) string {
+	return filepath.Join(filepath.Dir(versionsFile), "yanked.json")
+}
+
+// loadVersionsFile reads the versions from a versions.json file and the yanked versions from the
+// yanked.json file next to it
+func loadVersionsFile(versionsFile string) ([]string, map[string]types.YankedVersion, error) {
+	data, err := os.ReadFile(versionsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	yanked := map[string]types.YankedVersion{}
+	var versions []string
+	if err := json.Unmarshal(data, &versions); err != nil {
+		var legacy legacyVersionsFile
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", versionsFile, err)
+		}
+		versions = legacy.Versions
+		for version, yank := range legacy.Yanked {
+			yanked[version] = yank
+		}
+	}
+
+	yankedFile := yankedFilePath(versionsFile)
+	data, err = os.ReadFile(yankedFile)
+	if os.IsNotExist(err) {
+		return versions, yanked, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", yankedFile, err)
+	}
+	if err := json.Unmarshal(data, &yanked); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", yankedFile, err)
+	}
+	return versions, yanked, nil
+}
+
+// savePackageVersions writes versions.json as a plain list of versions and the yanked versions to
+// yanked.json, which is removed when no version is yanked
+func savePackageVersions(versions []string, yanked map[string]types.YankedVersion, versionsFile string) error {
 	data, err := json.MarshalIndent(versions, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal %s: %v", versionsFile, err)
@@ -401,26 +546,53 @@ func savePackageVersions(versions []string, versionsFile string) error {
 	if err := os.WriteFile(versionsFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write %s: %v", versionsFile, err)
 	}
+
+	yankedFile := yankedFilePath(versionsFile)
+	if len(yanked) == 0 {
+		if err := os.Remove(yankedFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", yankedFile, err)
+		}
+		return nil
+	}
+	data, err = json.MarshalIndent(yanked, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", yankedFile, err)
+	}
+	if err := os.WriteFile(yankedFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", yankedFile, err)
+	}
 	return nil
 }
 
-// loadVersions loads the list of versions for a package from versions.json
+// versionsFilePath returns the path of versions.json of a package in a registry
+func versionsFilePath(registriesDir, registryName, packageName string) string {
+	return filepath.Join(registriesDir, registryName, strings.ToUpper(string(packageName[0])), packageName, "versions.json")
+}
+
+// loadVersions loads the list of versions for a package from versions.json, including yanked versions
 func loadVersions(registriesDir, registryName, packageName string) ([]string, error) {
-	versionsFile := filepath.Join(registriesDir, registryName, strings.ToUpper(string(packageName[0])), packageName, "versions.json")
-	data, err := os.ReadFile(versionsFile)
+	versions, _, err := loadVersionsFile(versionsFilePath(registriesDir, registryName, packageName))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to read versions.json for '%s' in registry '%s': %v", packageName, registryName, err)
 	}
-	var versions []string
-	if err := json.Unmarshal(data, &versions); err != nil {
-		return nil, fmt.Errorf("failed to parse versions.json for '%s' in registry '%s': %v", packageName, registryName, err)
-	}
 	return versions, nil
 }
 
+// loadYankedVersions loads the yanked versions of a package from versions.json
+func loadYankedVersions(registriesDir, registryName, packageName string) (map[string]types.YankedVersion, error) {
+	_, yanked, err := loadVersionsFile(versionsFilePath(registriesDir, registryName, packageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]types.YankedVersion{}, nil
+		}
+		return nil, fmt.Errorf("failed to read versions.json for '%s' in registry '%s': %v", packageName, registryName, err)
+	}
+	return yanked, nil
+}
+
 // loadSpecs loads a package's specs from specs.json
 func loadSpecs(registriesDir, registryName, packageName, version string) (types.Specs, error) {
 	specsFile := filepath.Join(registriesDir, registryName, strings.ToUpper(string(packageName[0])), packageName, version, "specs.json")
@@ -457,28 +629,58 @@ func loadBuildListFile(buildListFile string) (types.BuildList, error) {
 	return buildList, nil
 }
 
-// copyFile copies a single file from src to dest using io.Copy
-func copyFile(src, dest string, mode os.FileMode) error {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file %s: %v", src, err)
+// extractTar writes the files of a tar stream into destPath, skipping .gitignore files and
+// rejecting entries that would end up outside of destPath
+func extractTar(r io.Reader, destPath string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		if filepath.Base(header.Name) == ".gitignore" {
+			continue
+		}
+		target := filepath.Join(destPath, header.Name)
+		if target != destPath && !strings.HasPrefix(target, destPath+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry '%s' points outside of %s", header.Name, destPath)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(target), err)
+			}
+			if err := writeFileFrom(tr, target, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(target), err)
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %v", target, err)
+			}
+		}
 	}
-	defer srcFile.Close()
+}
 
+// writeFileFrom copies the contents of r into a new file at dest with the given permissions
+func writeFileFrom(r io.Reader, dest string, mode os.FileMode) error {
 	destFile, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file %s: %v", dest, err)
+		return fmt.Errorf("failed to create file %s: %v", dest, err)
 	}
 	defer destFile.Close()
-
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy file from %s to %s: %v", src, dest, err)
+	if _, err := io.Copy(destFile, r); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", dest, err)
 	}
-
-	// Ensure the destination file has the same permissions as the source
-	if err := destFile.Chmod(mode); err != nil {
-		return fmt.Errorf("failed to set permissions on %s: %v", dest, err)
-	}
-
 	return nil
 }
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"cosm/types"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestVersionsFileFormats tests that versions.json stays a plain list when versions are yanked,
+// that yanks are kept in yanked.json, and that the earlier versions.json with yanks is still read
+func TestVersionsFileFormats(t *testing.T) {
+	versionsFile := filepath.Join(t.TempDir(), "versions.json")
+	yankedFile := filepath.Join(filepath.Dir(versionsFile), "yanked.json")
+	versions := []string{"v0.1.0", "v0.2.0"}
+	readBack := func() ([]string, map[string]types.YankedVersion, string) {
+		t.Helper()
+		gotVersions, gotYanked, err := loadVersionsFile(versionsFile)
+		if err != nil {
+			t.Fatalf("Failed to load versions.json: %v", err)
+		}
+		data, err := os.ReadFile(versionsFile)
+		if err != nil {
+			t.Fatalf("Failed to read versions.json: %v", err)
+		}
+		return gotVersions, gotYanked, string(data)
+	}
+
+	if err := savePackageVersions(versions, map[string]types.YankedVersion{}, versionsFile); err != nil {
+		t.Fatalf("Failed to save versions.json: %v", err)
+	}
+	gotVersions, gotYanked, data := readBack()
+	if !strings.HasPrefix(data, "[") || !reflect.DeepEqual(gotVersions, versions) || len(gotYanked) != 0 {
+		t.Errorf("Expected plain list without yanks, got %s", data)
+	}
+
+	yanked := map[string]types.YankedVersion{"v0.2.0": {Reason: "broken", Date: "2026-01-02T03:04:05Z"}}
+	if err := savePackageVersions(versions, yanked, versionsFile); err != nil {
+		t.Fatalf("Failed to save versions.json: %v", err)
+	}
+	gotVersions, gotYanked, data = readBack()
+	if !strings.HasPrefix(data, "[") || !reflect.DeepEqual(gotVersions, versions) || !reflect.DeepEqual(gotYanked, yanked) {
+		t.Errorf("Expected plain list with yanks in yanked.json, got %s", data)
+	}
+
+	if err := savePackageVersions(versions, map[string]types.YankedVersion{}, versionsFile); err != nil {
+		t.Fatalf("Failed to save versions.json: %v", err)
+	}
+	if _, err := os.Stat(yankedFile); !os.IsNotExist(err) {
+		t.Errorf("Expected yanked.json to be removed without yanks, got %v", err)
+	}
+
+	legacy := `{"versions": ["v0.1.0", "v0.2.0"], "yanked": {"v0.2.0": {"reason": "broken", "date": "2026-01-02T03:04:05Z"}}}`
+	if err := os.WriteFile(versionsFile, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write versions.json: %v", err)
+	}
+	gotVersions, gotYanked, _ = readBack()
+	if !reflect.DeepEqual(gotVersions, versions) || !reflect.DeepEqual(gotYanked, yanked) {
+		t.Errorf("Expected earlier format to be read, got %v %v", gotVersions, gotYanked)
+	}
+}
+
+// TestRecordRegistryUpdateConcurrent tests that parallel updates of last_updated.json within one
+// process keep every registry
+func TestRecordRegistryUpdateConcurrent(t *testing.T) {
+	registriesDir := filepath.Join(t.TempDir(), "registries")
+	if err := os.MkdirAll(registriesDir, 0755); err != nil {
+		t.Fatalf("Failed to create registries directory: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := recordRegistryUpdate(registriesDir, fmt.Sprintf("reg%d", i), time.Now()); err != nil {
+				t.Errorf("Failed to record update: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	updateTimes, err := loadRegistryUpdateTimes(registriesDir)
+	if err != nil {
+		t.Fatalf("Failed to load last_updated.json: %v", err)
+	}
+	if len(updateTimes) != 8 {
+		t.Errorf("Expected 8 registries in last_updated.json, got %v", updateTimes)
+	}
+}
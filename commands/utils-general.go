@@ -35,7 +35,7 @@ func removeString(slice []string, s string) []string {
 
 // promptUserForConfirmation prompts the user for confirmation and returns true if they enter 'y' or 'Y'
 func promptUserForConfirmation(prompt string) bool {
-	fmt.Print(prompt)
+	fmt.Fprint(messages, prompt)
 	var response string
 	if _, err := fmt.Scanln(&response); err != nil {
 		return false
@@ -1,8 +1,13 @@
 package commands
 
 import (
+	"bytes"
+	"cosm/types"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -26,6 +31,9 @@ func getCurrentBranch(dir string) (string, error) {
 
 // pullFromBranch pulls updates from the specified branch in the Git repository
 func pullFromBranch(dir, branch, context string) error {
+	if err := requireOnline("pull updates for " + context); err != nil {
+		return err
+	}
 	if _, err := GitCommand(dir, "pull", "origin", branch); err != nil {
 		return wrapGitError(dir, fmt.Sprintf("failed to pull updates from branch '%s' for %s", branch, context), err)
 	}
@@ -39,6 +47,9 @@ func wrapGitError(dir, msg string, err error) error {
 
 // pushToRemote pushes the specified target (branch or tag) to origin.
 func pushToRemote(dir, target string, ignoreUpToDate bool) error {
+	if err := requireOnline(fmt.Sprintf("push %s to origin in %s", target, dir)); err != nil {
+		return err
+	}
 	output, err := GitCommand(dir, "push", "origin", target)
 	if err != nil && !(ignoreUpToDate && strings.Contains(output, "Everything up-to-date")) {
 		return fmt.Errorf("failed to push %s to origin in %s: %v", target, dir, err)
@@ -48,6 +59,9 @@ func pushToRemote(dir, target string, ignoreUpToDate bool) error {
 
 // fetchOrigin fetches updates from origin.
 func fetchOrigin(dir string) error {
+	if err := requireOnline("fetch from origin in " + dir); err != nil {
+		return err
+	}
 	if _, err := GitCommand(dir, "fetch", "origin"); err != nil {
 		return wrapGitError(dir, "failed to fetch from origin", err)
 	}
@@ -80,18 +94,12 @@ func getGitAuthors() ([]string, error) {
 		email = ""
 	}
 	if name == "" || email == "" {
-		fmt.Println("Warning: Could not retrieve git user.name or user.email, defaulting to '[unknown]unknown@author.com'")
+		fmt.Fprintln(messages, "Warning: Could not retrieve git user.name or user.email, defaulting to '[unknown]unknown@author.com'")
 		return []string{"[unknown]unknown@author.com"}, nil
 	}
 	return []string{fmt.Sprintf("[%s]%s", name, email)}, nil
 }
 
-// revertClone returns the clone to its previous branch or state using 'git checkout -'
-func revertClone(clonePath string) error {
-	_, err := GitCommand(clonePath, "checkout", "-")
-	return err
-}
-
 // stageFiles stages the specified files or directories using git add.
 func stageFiles(dir string, paths ...string) error {
 	if len(paths) == 0 {
@@ -115,6 +123,9 @@ func commitChanges(dir, message string) error {
 
 // clone clones a repository from gitURL to the destination directory.
 func clone(gitURL, parentDir, destination string) (string, error) {
+	if err := requireOnline(fmt.Sprintf("clone '%s'", gitURL)); err != nil {
+		return "", err
+	}
 	if _, err := GitCommand(parentDir, "clone", gitURL, destination); err != nil {
 		return "", fmt.Errorf("failed to clone repository from '%s' to %s: %v", gitURL, destination, err)
 	}
@@ -145,19 +156,62 @@ func createTag(dir, tag string) error {
 	return nil
 }
 
-// checkoutVersion switches the clone to the specified SHA1
-func checkoutVersion(clonePath, sha1 string) error {
-	// Fetch updates to ensure we have the latest refs
+// ensureCommit fetches from origin if the commit is not yet in the object store of the clone,
+// which is an error in offline mode
+func ensureCommit(clonePath, sha1 string) error {
+	if _, err := GitCommand(clonePath, "cat-file", "-e", sha1+"^{commit}"); err == nil {
+		return nil
+	}
+	if Offline() {
+		return newCodedError(ErrCodeOffline, "commit %s is not in the local clone at %s and cannot be fetched in offline mode", sha1, clonePath)
+	}
 	if err := fetchOrigin(clonePath); err != nil {
 		return err
 	}
+	if _, err := GitCommand(clonePath, "cat-file", "-e", sha1+"^{commit}"); err != nil {
+		return fmt.Errorf("commit %s not found in %s", sha1, clonePath)
+	}
+	return nil
+}
+
+// archiveCommit extracts the tree of a commit into destPath with 'git archive', leaving the
+// working tree of the repository untouched
+func archiveCommit(dir, sha1, destPath string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "archive", "--format=tar", sha1)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open output of 'git archive' in %s: %v", dir, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run 'git archive %s' in %s: %v", sha1, dir, err)
+	}
+	extractErr := extractTar(stdout, destPath)
+	if extractErr != nil {
+		io.Copy(io.Discard, stdout) // let git finish writing before waiting for it
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to run 'git archive %s' in %s: %v\nOutput: %s", sha1, dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
 
-	// Checkout the specific SHA1
-	_, err := GitCommand(clonePath, "checkout", sha1)
+// loadProjectAtRef parses Project.json as committed at the given ref, without checking it out
+func loadProjectAtRef(dir, ref string) (*types.Project, error) {
+	output, err := GitCommand(dir, "show", ref+":Project.json")
 	if err != nil {
-		return fmt.Errorf("failed to checkout SHA1 %s in %s: %v", sha1, clonePath, err)
+		return nil, wrapGitError(dir, fmt.Sprintf("failed to read Project.json at '%s'", ref), err)
 	}
-	return nil
+	var project types.Project
+	if err := json.Unmarshal([]byte(output), &project); err != nil {
+		return nil, fmt.Errorf("failed to parse Project.json at '%s' in %s: %v", ref, dir, err)
+	}
+	if project.Deps == nil {
+		project.Deps = make(map[string]types.Dependency)
+	}
+	return &project, nil
 }
 
 // ensureNoUncommittedChanges checks for uncommitted changes in the Git repo
@@ -230,14 +284,17 @@ func commitAndPushInitialRegistryChanges(registryName string) error {
 	return pushToRemote(registryDir, branch, false)
 }
 
-// clonePackageToTempDir creates a temp clone directly in the clones directory
+// clonePackageToTempDir creates a uniquely named temp clone directly in the clones directory
 func clonePackageToTempDir(cosmDir, packageGitURL string) (string, error) {
 	clonesDir := filepath.Join(cosmDir, "clones")
 	if err := os.MkdirAll(clonesDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create clones directory: %v", err)
 	}
-	tmpClonePath := filepath.Join(clonesDir, "tmp-clone")
-	if _, err := clone(packageGitURL, clonesDir, "tmp-clone"); err != nil {
+	tmpClonePath, err := os.MkdirTemp(clonesDir, "tmp-clone-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary clone directory: %v", err)
+	}
+	if _, err := clone(packageGitURL, clonesDir, filepath.Base(tmpClonePath)); err != nil {
 		cleanupErr := cleanupTempClone(tmpClonePath)
 		if cleanupErr != nil {
 			return "", fmt.Errorf("failed to clone package repository at '%s': %v; cleanup failed: %v", packageGitURL, err, cleanupErr)
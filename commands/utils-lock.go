@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// acquireDepotLock takes an exclusive advisory lock on locks/<name>.lock in the depot, blocking
+// until it is available, and returns a function that releases it. Locks are not reentrant:
+// a holder must not acquire the same lock again.
+func acquireDepotLock(cosmDir, name string) (func(), error) {
+	locksDir := filepath.Join(cosmDir, "locks")
+	if err := os.MkdirAll(locksDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create locks directory: %v", err)
+	}
+	lockPath := filepath.Join(locksDir, name+".lock")
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %v", lockPath, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %v", lockPath, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// lockRegistriesList locks registries.json while registries are added or deleted
+func lockRegistriesList(cosmDir string) (func(), error) {
+	return acquireDepotLock(cosmDir, "registries")
+}
+
+// lockRegistry locks a registry for pulls and mutations
+func lockRegistry(registriesDir, registryName string) (func(), error) {
+	return acquireDepotLock(filepath.Dir(registriesDir), "registry-"+registryName)
+}
+
+// lockClone locks the clone of a package in clones/<uuid>
+func lockClone(cosmDir, packageUUID string) (func(), error) {
+	return acquireDepotLock(cosmDir, "clone-"+packageUUID)
+}
+
+// lockProjectIndex locks projects.json while projects are registered or garbage collected
+func lockProjectIndex(cosmDir string) (func(), error) {
+	return acquireDepotLock(cosmDir, "projects")
+}
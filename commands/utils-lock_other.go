@@ -0,0 +1,16 @@
+//go:build !unix
+
+package commands
+
+import "os"
+
+// lockFile is a no-op on platforms without flock; concurrent cosm invocations are not guarded,
+// and writers within one process must hold a mutex of their own
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without flock
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until an exclusive flock on f is acquired
+func lockFile(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the flock on f
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"bytes"
+	"cosm/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultLanguage is used for projects that do not declare a language in Project.json
+const defaultLanguage = "terra"
+
+// pluginExecutable is the name of an executable language plugin in plugins/<language>/
+const pluginExecutable = "env"
+
+// pluginInput is passed as JSON to a language plugin. The build list only contains the
+// dependencies written in the plugin's language; paths are relative to the depot. Plugins run in
+// the project root, so the sources of the project are referenced relative to it.
+type pluginInput struct {
+	Language       string          `json:"language"`
+	DepotPath      string          `json:"depot_path"`
+	IncludeProject bool            `json:"include_project"` // the project itself uses the language
+	BuildList      types.BuildList `json:"buildlist"`
+}
+
+// languagePlugin generates the environment variables that make packages of a language available
+type languagePlugin interface {
+	environment(input pluginInput) (map[string]string, error)
+}
+
+// builtinPlugins are used for languages without an executable plugin in the depot
+var builtinPlugins = map[string]languagePlugin{
+	"terra": terraPlugin{},
+	"lua":   luaPlugin{},
+}
+
+// terraPlugin sets TERRA_PATH to the src/ directories of Terra packages
+type terraPlugin struct{}
+
+func (terraPlugin) environment(input pluginInput) (map[string]string, error) {
+	var paths []string
+	if input.IncludeProject {
+		paths = append(paths, filepath.Join("src", "?.t"))
+	}
+	for _, key := range sortedBuildListKeys(input.BuildList) {
+		paths = append(paths, filepath.Join(input.DepotPath, input.BuildList.Dependencies[key].Path, "src", "?.t"))
+	}
+	return map[string]string{"TERRA_PATH": strings.Join(paths, ";") + ";;"}, nil
+}
+
+// luaPlugin sets LUA_PATH to the src/ directories and LUA_CPATH to the lib/ directories of Lua packages
+type luaPlugin struct{}
+
+func (luaPlugin) environment(input pluginInput) (map[string]string, error) {
+	var paths, cpaths []string
+	if input.IncludeProject {
+		paths = append(paths, filepath.Join("src", "?.lua"), filepath.Join("src", "?", "init.lua"))
+		cpaths = append(cpaths, filepath.Join("lib", "?.so"))
+	}
+	for _, key := range sortedBuildListKeys(input.BuildList) {
+		root := filepath.Join(input.DepotPath, input.BuildList.Dependencies[key].Path)
+		paths = append(paths, filepath.Join(root, "src", "?.lua"), filepath.Join(root, "src", "?", "init.lua"))
+		cpaths = append(cpaths, filepath.Join(root, "lib", "?.so"))
+	}
+	return map[string]string{
+		"LUA_PATH":  strings.Join(paths, ";") + ";;",
+		"LUA_CPATH": strings.Join(cpaths, ";") + ";;",
+	}, nil
+}
+
+// executablePlugin runs plugins/<language>/env, which reads a pluginInput on stdin and writes
+// a JSON object of environment variables to stdout
+type executablePlugin struct {
+	path string
+}
+
+func (p executablePlugin) environment(input pluginInput) (map[string]string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin input: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s failed: %v\nStderr: %s", p.path, err, strings.TrimSpace(stderr.String()))
+	}
+	var env map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &env); err != nil {
+		return nil, fmt.Errorf("failed to parse output of plugin %s: %v", p.path, err)
+	}
+	return env, nil
+}
+
+// findLanguagePlugin returns the executable plugin for a language if it exists in the depot,
+// and the built-in plugin otherwise
+func findLanguagePlugin(cosmDir, language string) (languagePlugin, error) {
+	pluginPath := filepath.Join(cosmDir, "plugins", language, pluginExecutable)
+	info, err := os.Stat(pluginPath)
+	if err == nil {
+		if info.IsDir() || info.Mode()&0111 == 0 {
+			return nil, fmt.Errorf("plugin %s for language '%s' is not executable", pluginPath, language)
+		}
+		return executablePlugin{path: pluginPath}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to check plugin %s: %v", pluginPath, err)
+	}
+	if plugin, ok := builtinPlugins[language]; ok {
+		return plugin, nil
+	}
+	return nil, fmt.Errorf("no plugin found for language '%s' (expected executable %s)", language, pluginPath)
+}
+
+// normalizeLanguage returns the lower-case language, falling back to the default language
+func normalizeLanguage(language string) string {
+	if language == "" {
+		return defaultLanguage
+	}
+	return strings.ToLower(language)
+}
+
+// groupBuildListByLanguage splits the build list by the language declared in the Project.json
+// of each available package
+func groupBuildListByLanguage(cosmDir string, buildList *types.BuildList) (map[string]types.BuildList, error) {
+	groups := make(map[string]types.BuildList)
+	for key, dep := range buildList.Dependencies {
+		project, err := loadProjectFromDir(filepath.Join(cosmDir, dep.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine language of '%s@%s': %v", dep.Name, dep.Version, err)
+		}
+		language := normalizeLanguage(project.Language)
+		if _, ok := groups[language]; !ok {
+			groups[language] = types.BuildList{Dependencies: make(map[string]types.BuildListDependency)}
+		}
+		groups[language].Dependencies[key] = dep
+	}
+	return groups, nil
+}
+
+// collectEnvironment runs the plugin of every language used by the project or its dependencies
+// and merges the environment variables they return
+func collectEnvironment(cosmDir string, project *types.Project, buildList *types.BuildList) (map[string]string, error) {
+	groups, err := groupBuildListByLanguage(cosmDir, buildList)
+	if err != nil {
+		return nil, err
+	}
+	projectLanguage := normalizeLanguage(project.Language)
+	if _, ok := groups[projectLanguage]; !ok {
+		groups[projectLanguage] = types.BuildList{Dependencies: make(map[string]types.BuildListDependency)}
+	}
+	languages := make([]string, 0, len(groups))
+	for language := range groups {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	env := make(map[string]string)
+	setBy := make(map[string]string)
+	for _, language := range languages {
+		plugin, err := findLanguagePlugin(cosmDir, language)
+		if err != nil {
+			return nil, err
+		}
+		input := pluginInput{Language: language, DepotPath: cosmDir, IncludeProject: language == projectLanguage, BuildList: groups[language]}
+		vars, err := plugin.environment(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate environment for language '%s': %v", language, err)
+		}
+		for name, value := range vars {
+			if other, exists := setBy[name]; exists {
+				return nil, fmt.Errorf("environment variable %s is set by the plugins for both '%s' and '%s'", name, other, language)
+			}
+			env[name] = value
+			setBy[name] = language
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"cosm/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCollectEnvironmentMixedLanguages tests that Lua and Terra dependencies of a project are
+// made available through their own built-in plugins
+func TestCollectEnvironmentMixedLanguages(t *testing.T) {
+	cosmDir := t.TempDir()
+	writeTestJSON(t, filepath.Join(cosmDir, "packages", "A", "sha-a", "Project.json"), types.Project{Name: "A", Language: "Terra"})
+	writeTestJSON(t, filepath.Join(cosmDir, "packages", "B", "sha-b", "Project.json"), types.Project{Name: "B", Language: "lua"})
+	buildList := types.BuildList{Dependencies: map[string]types.BuildListDependency{
+		"ua@v1": {Name: "A", Version: "v1.0.0", Path: filepath.Join("packages", "A", "sha-a")},
+		"ub@v1": {Name: "B", Version: "v1.0.0", Path: filepath.Join("packages", "B", "sha-b")},
+	}}
+
+	env, err := collectEnvironment(cosmDir, &types.Project{Name: "root"}, &buildList)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bRoot := filepath.Join(cosmDir, "packages", "B", "sha-b")
+	expected := map[string]string{
+		"TERRA_PATH": "src/?.t;" + filepath.Join(cosmDir, "packages", "A", "sha-a", "src", "?.t") + ";;",
+		"LUA_PATH":   filepath.Join(bRoot, "src", "?.lua") + ";" + filepath.Join(bRoot, "src", "?", "init.lua") + ";;",
+		"LUA_CPATH":  filepath.Join(bRoot, "lib", "?.so") + ";;",
+	}
+	if len(env) != len(expected) {
+		t.Errorf("Expected %d variables, got %v", len(expected), env)
+	}
+	for name, value := range expected {
+		if env[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, env[name])
+		}
+	}
+}
+
+// TestCollectEnvironmentExecutablePlugin tests that an executable plugin in the depot receives the
+// build list of its language and overrides the built-in plugin
+func TestCollectEnvironmentExecutablePlugin(t *testing.T) {
+	cosmDir := t.TempDir()
+	writeTestJSON(t, filepath.Join(cosmDir, "packages", "A", "sha-a", "Project.json"), types.Project{Name: "A", Language: "lua"})
+	buildList := types.BuildList{Dependencies: map[string]types.BuildListDependency{
+		"ua@v1": {Name: "A", Version: "v1.0.0", Path: filepath.Join("packages", "A", "sha-a")},
+	}}
+	pluginDir := filepath.Join(cosmDir, "plugins", "lua")
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatalf("Failed to create plugin directory: %v", err)
+	}
+	inputFile := filepath.Join(cosmDir, "input.json")
+	script := "#!/bin/sh\ncat > " + inputFile + "\necho '{\"LUA_PATH\": \"custom\"}'\n"
+	if err := os.WriteFile(filepath.Join(pluginDir, pluginExecutable), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+
+	env, err := collectEnvironment(cosmDir, &types.Project{Name: "root", Language: "lua"}, &buildList)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(env) != 1 || env["LUA_PATH"] != "custom" {
+		t.Errorf("Expected only LUA_PATH=custom, got %v", env)
+	}
+	input, err := os.ReadFile(inputFile)
+	if err != nil {
+		t.Fatalf("Plugin did not receive input: %v", err)
+	}
+	for _, want := range []string{`"language":"lua"`, `"depot_path":"` + cosmDir + `"`, `"include_project":true`, `"ua@v1"`} {
+		if !strings.Contains(string(input), want) {
+			t.Errorf("Expected plugin input to contain %s, got %s", want, input)
+		}
+	}
+
+	// Languages without a plugin are rejected
+	if _, err := collectEnvironment(cosmDir, &types.Project{Name: "root", Language: "cobol"}, &types.BuildList{}); err == nil || !strings.Contains(err.Error(), "no plugin found for language 'cobol'") {
+		t.Errorf("Expected missing plugin error, got %v", err)
+	}
+}
@@ -21,7 +21,10 @@ func createProject(packageName, projectUUID string, authors []string, language,
 // selectPackageFromResults handles the selection of a package from multiple matches
 func selectPackageFromResults(packageName, versionTag string, foundPackages []types.PackageLocation) (types.PackageLocation, error) {
 	if len(foundPackages) == 0 {
-		return types.PackageLocation{}, fmt.Errorf("package '%s' with version '%s' not found in any registry", packageName, versionTag)
+		if Offline() {
+			return types.PackageLocation{}, newCodedError(ErrCodeNotFound, "package '%s' with version '%s' not found in the local state of any registry (offline mode)", packageName, versionTag)
+		}
+		return types.PackageLocation{}, newCodedError(ErrCodeNotFound, "package '%s' with version '%s' not found in any registry", packageName, versionTag)
 	}
 	if len(foundPackages) == 1 {
 		return foundPackages[0], nil
@@ -29,14 +32,35 @@ func selectPackageFromResults(packageName, versionTag string, foundPackages []ty
 	return promptUserForRegistry(packageName, versionTag, foundPackages)
 }
 
-// MakePackageAvailable copies the contents of a cloned package for a specific version
-// from ~/.cosm/clones/<UUID> to ~/.cosm/packages/<packageName>/<SHA1>, excluding Git-related files,
-// and ensures the clone is reverted to its previous state even on error.
+// selectDependencyKey finds the key of a direct dependency by name, prompting the user
+// to choose when several major versions of the dependency are present
+func selectDependencyKey(project *types.Project, packageName, action string) (string, error) {
+	keys, deps, err := findDependencyKey(project, packageName)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) > 1 {
+		return promptUserForDependency(packageName, keys, deps, action)
+	}
+	return keys[0], nil
+}
+
+// MakePackageAvailable extracts the files of a specific version from the object store of
+// ~/.cosm/clones/<UUID> into ~/.cosm/packages/<packageName>/<SHA1>, excluding Git-related files,
+// and checks them against the tree hash of the specs if one is recorded. The files are extracted
+// into a temporary directory that is renamed into place, and the checkout of the clone is never
+// changed. The clone is locked while it is created or read.
 func MakePackageAvailable(cosmDir string, specs *types.Specs) error {
 	if err := validateSpecs(specs); err != nil {
 		return err
 	}
 
+	unlock, err := lockClone(cosmDir, specs.UUID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	destPath := filepath.Join(cosmDir, "packages", specs.Name, specs.SHA1)
 	if checkDestinationExists(destPath) {
 		return nil
@@ -45,6 +69,9 @@ func MakePackageAvailable(cosmDir string, specs *types.Specs) error {
 	// check out clone if it does not yet exist
 	clonePath := filepath.Join(cosmDir, "clones", specs.UUID)
 	if _, err := os.Stat(clonePath); os.IsNotExist(err) {
+		if Offline() {
+			return newCodedError(ErrCodeOffline, "package '%s@%s' has no local clone at %s and cannot be cloned from '%s' in offline mode", specs.Name, specs.Version, clonePath, specs.GitURL)
+		}
 		tmpClonePath, err := clonePackageToTempDir(cosmDir, specs.GitURL)
 		if err != nil {
 			return err
@@ -58,19 +85,12 @@ func MakePackageAvailable(cosmDir string, specs *types.Specs) error {
 		return fmt.Errorf("failed to check clone at %s: %v", clonePath, err)
 	}
 
-	if err := prepareClone(clonePath, specs.SHA1); err != nil {
-		return fmt.Errorf("failed to prepare clone for %s@%s: %v", specs.Name, specs.Version, err)
-	}
-
-	if err := copyPackageFiles(clonePath, destPath); err != nil {
-		if revertErr := revertClone(clonePath); revertErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to revert clone after error: %v\n", revertErr)
-		}
-		return fmt.Errorf("failed to copy package files for %s@%s: %v", specs.Name, specs.Version, err)
+	if err := ensureCommit(clonePath, specs.SHA1); err != nil {
+		return fmt.Errorf("failed to find commit for %s@%s: %v", specs.Name, specs.Version, err)
 	}
 
-	if err := revertClone(clonePath); err != nil {
-		return fmt.Errorf("failed to revert clone for %s@%s: %v", specs.Name, specs.Version, err)
+	if err := extractPackageFiles(clonePath, specs.SHA1, specs.TreeHash, destPath); err != nil {
+		return fmt.Errorf("failed to extract package files for %s@%s: %v", specs.Name, specs.Version, err)
 	}
 
 	return nil
@@ -105,52 +125,38 @@ func checkDestinationExists(destPath string) bool {
 	return true
 }
 
-// prepareClone verifies the clone directory exists and checks out the specified SHA1
-func prepareClone(clonePath, sha1 string) error {
-	if _, err := os.Stat(clonePath); os.IsNotExist(err) {
-		return fmt.Errorf("clone directory not found at %s", clonePath)
+// extractPackageFiles archives the commit into a temporary directory next to destPath, verifies
+// it against the recorded tree hash if there is one, and renames it into place, so that destPath
+// is either complete or absent
+func extractPackageFiles(clonePath, sha1, treeHash, destPath string) error {
+	parentDir := filepath.Dir(destPath)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", parentDir, err)
 	}
-	if err := checkoutVersion(clonePath, sha1); err != nil {
-		return fmt.Errorf("failed to checkout SHA1 %s: %v", sha1, err)
+	tmpPath, err := os.MkdirTemp(parentDir, "tmp-"+sha1+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory in %s: %v", parentDir, err)
 	}
-	return nil
-}
-
-// copyPackageFiles creates the destination directory and copies files, excluding Git-related ones
-func copyPackageFiles(clonePath, destPath string) error {
-	if err := os.MkdirAll(destPath, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory %s: %v", destPath, err)
+	defer os.RemoveAll(tmpPath)
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %v", tmpPath, err)
 	}
 
-	return filepath.Walk(clonePath, func(srcPath string, info os.FileInfo, err error) error {
-		if err != nil {
+	if err := archiveCommit(clonePath, sha1, tmpPath); err != nil {
+		return err
+	}
+	if treeHash != "" {
+		if err := verifyPackageTree(tmpPath, treeHash); err != nil {
 			return err
 		}
+	}
 
-		// Skip .git directory and .gitignore files
-		if info.Name() == ".git" || info.Name() == ".gitignore" {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Compute relative path and destination
-		relPath, err := filepath.Rel(clonePath, srcPath)
-		if err != nil {
-			return fmt.Errorf("failed to compute relative path for %s: %v", srcPath, err)
-		}
-		if relPath == "." {
-			return nil // Skip root directory itself
-		}
-		destFile := filepath.Join(destPath, relPath)
-
-		// Handle directories
-		if info.IsDir() {
-			return os.MkdirAll(destFile, info.Mode())
-		}
-
-		// Copy file
-		return copyFile(srcPath, destFile, info.Mode())
-	})
+	// Remove an incomplete copy left behind by an older version of cosm
+	if err := os.RemoveAll(destPath); err != nil {
+		return fmt.Errorf("failed to remove incomplete package directory %s: %v", destPath, err)
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return fmt.Errorf("failed to move package files to %s: %v", destPath, err)
+	}
+	return nil
 }
@@ -8,15 +8,17 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // promptUserForRegistry handles multiple registry matches by prompting the user
 func promptUserForRegistry(packageName, versionTag string, foundPackages []types.PackageLocation) (types.PackageLocation, error) {
-	fmt.Printf("Package '%s' v%s found in multiple registries:\n", packageName, versionTag)
+	fmt.Fprintf(messages, "Package '%s' v%s found in multiple registries:\n", packageName, versionTag)
 	for i, pkg := range foundPackages {
-		fmt.Printf("  %d. %s (Git URL: %s)\n", i+1, pkg.RegistryName, pkg.Specs.GitURL)
+		fmt.Fprintf(messages, "  %d. %s (Git URL: %s)\n", i+1, pkg.RegistryName, pkg.Specs.GitURL)
 	}
-	fmt.Printf("Please select a registry (enter number 1-%d): ", len(foundPackages))
+	fmt.Fprintf(messages, "Please select a registry (enter number 1-%d): ", len(foundPackages))
 
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
@@ -29,12 +31,13 @@ func promptUserForRegistry(packageName, versionTag string, foundPackages []types
 	return foundPackages[choiceNum-1], nil
 }
 
-// findPackageInRegistries searches for a package across all registries
-func findPackageInRegistries(packageName, versionTag, registriesDir string, registryNames []string) (types.PackageLocation, error) {
+// findPackageInRegistries searches for a package across all registries, pulling stale registries
+// first, or all of them with refresh
+func findPackageInRegistries(packageName, versionTag, registriesDir string, registryNames []string, refresh bool) (types.PackageLocation, error) {
 	var foundPackages []types.PackageLocation
 
 	for _, regName := range registryNames {
-		pkg, found, err := findPackageInRegistry(packageName, versionTag, registriesDir, regName)
+		pkg, found, err := findPackageInRegistry(packageName, versionTag, registriesDir, regName, refresh)
 		if err != nil {
 			return types.PackageLocation{}, err
 		}
@@ -47,9 +50,9 @@ func findPackageInRegistries(packageName, versionTag, registriesDir string, regi
 }
 
 // findPackageInRegistry searches for a package in a single registry
-func findPackageInRegistry(packageName, versionTag, registriesDir, registryName string) (types.PackageLocation, bool, error) {
-	// Update registry before loading metadata
-	if err := updateSingleRegistry(registriesDir, registryName); err != nil {
+func findPackageInRegistry(packageName, versionTag, registriesDir, registryName string, refresh bool) (types.PackageLocation, bool, error) {
+	// Update registry before loading metadata if it is stale
+	if _, err := refreshRegistry(registriesDir, registryName, refresh); err != nil {
 		return types.PackageLocation{}, false, err
 	}
 	registry, _, err := LoadRegistryMetadata(registriesDir, registryName)
@@ -61,7 +64,14 @@ func findPackageInRegistry(packageName, versionTag, registriesDir, registryName
 		return types.PackageLocation{}, false, nil
 	}
 
-	// Determine the version to use
+	// Determine the version to use; yanked versions are never added
+	yanked, err := loadYankedVersions(registriesDir, registryName, packageName)
+	if err != nil {
+		return types.PackageLocation{}, false, err
+	}
+	if yank, isYanked := yanked[versionTag]; isYanked {
+		return types.PackageLocation{}, false, newCodedError(ErrCodeNotFound, "version '%s' of package '%s' is yanked in registry '%s'%s", versionTag, packageName, registryName, formatYankReason(yank))
+	}
 	version := versionTag
 	if versionTag == "" {
 		latestVersion, err := findLatestVersionInRegistry(packageName, registriesDir, registryName)
@@ -89,10 +99,11 @@ func findPackageInRegistry(packageName, versionTag, registriesDir, registryName
 	return types.PackageLocation{RegistryName: registryName, Specs: specs}, true, nil
 }
 
-// findLatestVersionInRegistry finds the latest version of a package in a single registry
+// findLatestVersionInRegistry finds the latest version of a package in a single registry that is
+// not yanked
 func findLatestVersionInRegistry(packageName, registriesDir, registryName string) (string, error) {
 	// Load versions
-	versions, err := loadVersions(registriesDir, registryName, packageName)
+	versions, err := loadSelectableVersions(registriesDir, registryName, packageName)
 	if err != nil {
 		return "", err
 	}
@@ -109,27 +120,174 @@ func findLatestVersionInRegistry(packageName, registriesDir, registryName string
 	return latestVersion, nil
 }
 
-// determineLatestVersion finds the latest version from a list of versions
+// loadSelectableVersions loads the versions of a package in a registry that are not yanked
+func loadSelectableVersions(registriesDir, registryName, packageName string) ([]string, error) {
+	versions, err := loadVersions(registriesDir, registryName, packageName)
+	if err != nil {
+		return nil, err
+	}
+	yanked, err := loadYankedVersions(registriesDir, registryName, packageName)
+	if err != nil {
+		return nil, err
+	}
+	var selectable []string
+	for _, version := range versions {
+		if _, isYanked := yanked[version]; !isYanked {
+			selectable = append(selectable, version)
+		}
+	}
+	return selectable, nil
+}
+
+// formatYankReason returns the reason of a yank for messages, or an empty string
+func formatYankReason(yank types.YankedVersion) string {
+	if yank.Reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", yank.Reason)
+}
+
+// determineLatestVersion finds the latest version from a list of versions. Releases
+// take precedence over pre-releases; a pre-release is only returned if no release exists.
 func determineLatestVersion(versions []string) (string, error) {
-	var latestVersion string
+	var latestVersion, latestPrerelease string
+	var latest, latestPre semVer
 
 	for _, version := range versions {
-		if latestVersion == "" {
-			latestVersion = version
-		} else {
-			maxVersion, err := MaxSemVer(latestVersion, version)
-			if err != nil {
-				continue // Skip invalid versions
-			}
-			if maxVersion == version {
-				latestVersion = version
+		s, err := ParseSemVer(version)
+		if err != nil {
+			continue // Skip invalid versions
+		}
+		if s.IsPrerelease() {
+			if latestPrerelease == "" || compareSemVer(s, latestPre) > 0 {
+				latestPrerelease, latestPre = version, s
 			}
+			continue
+		}
+		if latestVersion == "" || compareSemVer(s, latest) > 0 {
+			latestVersion, latest = version, s
 		}
 	}
 
+	if latestVersion == "" {
+		return latestPrerelease, nil
+	}
 	return latestVersion, nil
 }
 
+// registeredVersion is a version of a package found in a specific registry
+type registeredVersion struct {
+	Version      string
+	RegistryName string
+	Yanked       bool // never selected for new requirements
+}
+
+// findRegisteredVersions collects the versions of a package with the given name and UUID across all registries
+func findRegisteredVersions(packageName, packageUUID, registriesDir string) ([]registeredVersion, error) {
+	registryNames, err := loadRegistryNames(registriesDir)
+	if err != nil {
+		return nil, err
+	}
+	var found []registeredVersion
+	for _, regName := range registryNames {
+		registry, _, err := LoadRegistryMetadata(registriesDir, regName)
+		if err != nil {
+			return nil, err
+		}
+		if pkgInfo, exists := registry.Packages[packageName]; !exists || pkgInfo.UUID != packageUUID {
+			continue
+		}
+		versions, err := loadVersions(registriesDir, regName, packageName)
+		if err != nil {
+			return nil, err
+		}
+		yanked, err := loadYankedVersions(registriesDir, regName, packageName)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			_, isYanked := yanked[version]
+			found = append(found, registeredVersion{Version: version, RegistryName: regName, Yanked: isYanked})
+		}
+	}
+	return found, nil
+}
+
+// defaultRegistryTTL is how long a pulled registry is considered fresh by commands that read it
+const defaultRegistryTTL = 5 * time.Minute
+
+// registryTTL returns the freshness TTL of registries from COSM_REGISTRY_TTL, a duration such as
+// 10m or 0 to always pull, or the default TTL
+func registryTTL() (time.Duration, error) {
+	value := os.Getenv("COSM_REGISTRY_TTL")
+	if value == "" {
+		return defaultRegistryTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid COSM_REGISTRY_TTL '%s': expected a duration such as 10m, or 0 to always pull", value)
+	}
+	return ttl, nil
+}
+
+// refreshRegistry pulls a registry before it is read, unless it was pulled within the TTL and
+// force is not set, and reports whether it was pulled. Registries are never pulled offline.
+func refreshRegistry(registriesDir, registryName string, force bool) (bool, error) {
+	if !force {
+		ttl, err := registryTTL()
+		if err != nil {
+			return false, err
+		}
+		updateTimes, err := loadRegistryUpdateTimes(registriesDir)
+		if err != nil {
+			return false, err
+		}
+		if lastUpdated, ok := updateTimes[registryName]; ok && time.Since(lastUpdated) < ttl {
+			return false, nil
+		}
+	}
+	if err := updateSingleRegistry(registriesDir, registryName); err != nil {
+		return false, err
+	}
+	return !Offline(), nil
+}
+
+// updateRegistries refreshes the given registries in parallel and reports the outcome of each
+// in the order of registryNames
+func updateRegistries(registriesDir string, registryNames []string, force bool) []registryUpdateStatus {
+	statuses := make([]registryUpdateStatus, len(registryNames))
+	var wg sync.WaitGroup
+	for i, name := range registryNames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			start := time.Now()
+			updated, err := refreshRegistry(registriesDir, name, force)
+			statuses[i] = registryUpdateStatus{Name: name, Updated: updated, Duration: time.Since(start).Round(time.Millisecond).String()}
+			if err != nil {
+				statuses[i].Error = err.Error()
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// updateAllRegistries refreshes every registry in registries.json before versions are resolved,
+// pulling only stale registries unless refresh is set
+func updateAllRegistries(registriesDir string, refresh bool) error {
+	registryNames, err := loadRegistryNames(registriesDir)
+	if err != nil {
+		return err
+	}
+	for _, status := range updateRegistries(registriesDir, registryNames, refresh) {
+		if status.Error != "" {
+			return fmt.Errorf("failed to update registry '%s': %s", status.Name, status.Error)
+		}
+	}
+	return nil
+}
+
 // updateRegistryConfig holds configuration for updating a registry
 type updateRegistryConfig struct {
 	registryName  string
@@ -137,8 +295,19 @@ type updateRegistryConfig struct {
 	registryDir   string
 }
 
-// updateSingleRegistry pulls updates for a single registry
+// updateSingleRegistry pulls updates for a single registry while holding its lock
 func updateSingleRegistry(registriesDir, registryName string) error {
+	unlock, err := lockRegistry(registriesDir, registryName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return syncRegistry(registriesDir, registryName)
+}
+
+// syncRegistry pulls updates for a single registry; the caller must hold the registry lock. In
+// offline mode the local state of the registry is used as is.
+func syncRegistry(registriesDir, registryName string) error {
 	// Parse arguments and initialize config
 	config, err := parseUpdateArgs(registriesDir, registryName)
 	if err != nil {
@@ -149,13 +318,16 @@ func updateSingleRegistry(registriesDir, registryName string) error {
 	if err := validateRegistryForUpdate(config); err != nil {
 		return err
 	}
+	if Offline() {
+		return nil
+	}
 
 	// Pull updates from the registry's Git repository
 	if err := pullRegistryUpdates(config); err != nil {
 		return err
 	}
 
-	return nil
+	return recordRegistryUpdate(registriesDir, registryName, time.Now())
 }
 
 // parseUpdateArgs validates the registry name and initializes the config
@@ -178,7 +350,7 @@ func parseUpdateArgs(registriesDir, registryName string) (*updateRegistryConfig,
 // validateRegistryForUpdate checks if the registry exists
 func validateRegistryForUpdate(config *updateRegistryConfig) error {
 	if err := assertRegistryExists(config.registriesDir, config.registryName); err != nil {
-		return fmt.Errorf("failed to validate registry '%s': %v", config.registryName, err)
+		return newCodedError(ErrCodeNotFound, "failed to validate registry '%s': %v", config.registryName, err)
 	}
 	return nil
 }
@@ -224,7 +396,7 @@ func commitAndPushRegistryChanges(registriesDir, registryName, commitMsg string)
 func assertRegistryExists(registriesDir, registryName string) error {
 	registriesFile := filepath.Join(registriesDir, "registries.json")
 	if _, err := os.Stat(registriesFile); os.IsNotExist(err) {
-		return fmt.Errorf("no registries found (run 'cosm registry init' first)")
+		return newCodedError(ErrCodeNotFound, "no registries found (run 'cosm registry init' first)")
 	}
 	var registryNames []string
 	data, err := os.ReadFile(registriesFile)
@@ -239,7 +411,7 @@ func assertRegistryExists(registriesDir, registryName string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("registry '%s' not found in registries.json", registryName)
+	return newCodedError(ErrCodeNotFound, "registry '%s' not found in registries.json", registryName)
 }
 
 // loadAndCheckRegistries loads registries.json and checks for duplicate registry names
@@ -256,7 +428,7 @@ func loadAndCheckRegistries(registriesDir, registryName string) ([]string, error
 
 	for _, name := range registryNames {
 		if name == registryName {
-			return nil, fmt.Errorf("registry '%s' already exists", registryName)
+			return nil, newCodedError(ErrCodeAlreadyExists, "registry '%s' already exists", registryName)
 		}
 	}
 
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// searchIndex is the prebuilt index.json at the root of a registry that cosm search reads
+// instead of the specs of every package
+type searchIndex struct {
+	Packages []searchIndexEntry `json:"packages"`
+}
+
+// searchIndexEntry describes the latest version of a package in a registry; yanked versions are
+// left out
+type searchIndexEntry struct {
+	Name        string   `json:"name"`
+	UUID        string   `json:"uuid"`
+	GitURL      string   `json:"giturl"`
+	Latest      string   `json:"latest,omitempty"` // empty if no version is registered
+	Majors      []string `json:"majors"`
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	Language    string   `json:"language,omitempty"`
+}
+
+// buildSearchIndex collects the search metadata of the latest version of every package in a
+// registry
+func buildSearchIndex(registriesDir, registryName string) (searchIndex, error) {
+	registry, _, err := LoadRegistryMetadata(registriesDir, registryName)
+	if err != nil {
+		return searchIndex{}, err
+	}
+	index := searchIndex{Packages: []searchIndexEntry{}}
+	for packageName, pkgInfo := range registry.Packages {
+		entry := searchIndexEntry{Name: packageName, UUID: pkgInfo.UUID, GitURL: pkgInfo.GitURL, Majors: []string{}}
+		versions, err := loadSelectableVersions(registriesDir, registryName, packageName)
+		if err != nil {
+			return searchIndex{}, err
+		}
+		entry.Majors = collectMajorVersions(versions)
+		entry.Latest, err = determineLatestVersion(versions)
+		if err != nil {
+			return searchIndex{}, err
+		}
+		if entry.Latest != "" {
+			specs, err := loadSpecs(registriesDir, registryName, packageName, entry.Latest)
+			if err != nil {
+				return searchIndex{}, fmt.Errorf("failed to load specs for '%s@%s' in registry '%s': %v", packageName, entry.Latest, registryName, err)
+			}
+			entry.Description, entry.Keywords, entry.Language = specs.Description, specs.Keywords, specs.Language
+		}
+		index.Packages = append(index.Packages, entry)
+	}
+	sort.Slice(index.Packages, func(i, j int) bool { return index.Packages[i].Name < index.Packages[j].Name })
+	return index, nil
+}
+
+// collectMajorVersions returns the distinct major versions of a list of versions in order
+func collectMajorVersions(versions []string) []string {
+	sorted := append([]string{}, versions...)
+	sortVersions(sorted)
+	majors := []string{}
+	for _, version := range sorted {
+		major, err := GetMajorVersion(version)
+		if err != nil {
+			continue // Skip invalid versions
+		}
+		if !contains(majors, major) {
+			majors = append(majors, major)
+		}
+	}
+	return majors
+}
+
+// writeSearchIndex regenerates index.json of a registry after packages or versions changed; the
+// caller must hold the registry lock
+func writeSearchIndex(registriesDir, registryName string) error {
+	index, err := buildSearchIndex(registriesDir, registryName)
+	if err != nil {
+		return fmt.Errorf("failed to build search index of registry '%s': %v", registryName, err)
+	}
+	return saveSearchIndex(registriesDir, registryName, index)
+}
+
+// saveSearchIndex writes index.json of a registry
+func saveSearchIndex(registriesDir, registryName string, index searchIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index.json: %v", err)
+	}
+	indexFile := filepath.Join(registriesDir, registryName, "index.json")
+	if err := os.WriteFile(indexFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", indexFile, err)
+	}
+	return nil
+}
+
+// loadSearchIndex loads index.json of a registry, building the index from the package directories
+// of registries that do not have one yet
+func loadSearchIndex(registriesDir, registryName string) (searchIndex, error) {
+	indexFile := filepath.Join(registriesDir, registryName, "index.json")
+	data, err := os.ReadFile(indexFile)
+	if os.IsNotExist(err) {
+		return buildSearchIndex(registriesDir, registryName)
+	}
+	if err != nil {
+		return searchIndex{}, fmt.Errorf("failed to read %s: %v", indexFile, err)
+	}
+	var index searchIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return searchIndex{}, fmt.Errorf("failed to parse %s: %v", indexFile, err)
+	}
+	return index, nil
+}
+
+// scoreSearchEntry ranks how well a package matches a lowercase query: name matches rank above
+// keyword matches, which rank above description matches. A score of 0 means no match; an empty
+// query matches every package.
+func scoreSearchEntry(entry searchIndexEntry, query string) int {
+	if query == "" {
+		return 1
+	}
+	name := strings.ToLower(entry.Name)
+	switch {
+	case name == query:
+		return 100
+	case strings.HasPrefix(name, query):
+		return 80
+	case strings.Contains(name, query):
+		return 60
+	}
+	score := 0
+	for _, keyword := range entry.Keywords {
+		keyword = strings.ToLower(keyword)
+		if keyword == query {
+			score = 40
+			break
+		}
+		if strings.Contains(keyword, query) {
+			score = 30
+		}
+	}
+	if score == 0 && strings.Contains(strings.ToLower(entry.Description), query) {
+		score = 20
+	}
+	return score
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestScoreSearchEntry tests that name matches rank above keyword and description matches
+func TestScoreSearchEntry(t *testing.T) {
+	entry := searchIndexEntry{Name: "JsonKit", Keywords: []string{"Encoding", "serialization"}, Description: "Tools for YAML and TOML"}
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{query: "", expected: 1},
+		{query: "jsonkit", expected: 100},
+		{query: "json", expected: 80},
+		{query: "kit", expected: 60},
+		{query: "encoding", expected: 40},
+		{query: "serial", expected: 30},
+		{query: "toml", expected: 20},
+		{query: "xml", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := scoreSearchEntry(entry, tt.query); got != tt.expected {
+				t.Errorf("scoreSearchEntry(%q) = %d, expected %d", tt.query, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCollectMajorVersions tests that major versions are distinct and ordered by precedence
+func TestCollectMajorVersions(t *testing.T) {
+	got := collectMajorVersions([]string{"v2.0.0", "v0.1.0", "v1.2.0", "v1.0.0-rc.1", "v0.2.0", "invalid"})
+	expected := []string{"v0", "v1", "v2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("collectMajorVersions = %v, expected %v", got, expected)
+	}
+}
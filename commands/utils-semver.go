@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -14,63 +15,231 @@ func validateVersion(version string) error {
 	return nil
 }
 
-// ParseSemVer parses a semantic version string into its components
+// semVer represents a semantic version (vX.Y.Z-<prerelease>+<build>)
+type semVer struct {
+	Major, Minor, Patch int
+	Prerelease          []string // dot-separated pre-release identifiers, e.g. ["alpha", "1"]
+	Build               []string // dot-separated build metadata, ignored for precedence
+}
+
+// ParseSemVer parses a semantic version string into its components following
+// Semantic Versioning 2.0.0. The patch number may be omitted (vX.Y).
 func ParseSemVer(version string) (semVer, error) {
-	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
-	if len(parts) < 2 {
+	rest := strings.TrimPrefix(version, "v")
+
+	// Split off build metadata first, since it may itself contain '-'
+	var build []string
+	if idx := strings.Index(rest, "+"); idx >= 0 {
+		var err error
+		build, err = parseIdentifiers(rest[idx+1:], false)
+		if err != nil {
+			return semVer{}, fmt.Errorf("invalid build metadata in '%s': %v", version, err)
+		}
+		rest = rest[:idx]
+	}
+
+	// Split off pre-release identifiers
+	var prerelease []string
+	if idx := strings.Index(rest, "-"); idx >= 0 {
+		var err error
+		prerelease, err = parseIdentifiers(rest[idx+1:], true)
+		if err != nil {
+			return semVer{}, fmt.Errorf("invalid pre-release in '%s': %v", version, err)
+		}
+		rest = rest[:idx]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) < 2 || len(parts) > 3 {
 		return semVer{}, fmt.Errorf("invalid version format '%s': must be vX.Y.Z or vX.Y", version)
 	}
-	major, err := strconv.Atoi(parts[0])
+	major, err := parseVersionNumber(parts[0])
 	if err != nil {
 		return semVer{}, fmt.Errorf("invalid major version in '%s': %v", version, err)
 	}
-	minor, err := strconv.Atoi(parts[1])
+	minor, err := parseVersionNumber(parts[1])
 	if err != nil {
 		return semVer{}, fmt.Errorf("invalid minor version in '%s': %v", version, err)
 	}
 	patch := 0
 	if len(parts) > 2 {
-		patch, err = strconv.Atoi(parts[2])
+		patch, err = parseVersionNumber(parts[2])
 		if err != nil {
 			return semVer{}, fmt.Errorf("invalid patch version in '%s': %v", version, err)
 		}
 	}
-	return semVer{Major: major, Minor: minor, Patch: patch}, nil
+	return semVer{Major: major, Minor: minor, Patch: patch, Prerelease: prerelease, Build: build}, nil
 }
 
-// semVer represents a semantic version (vX.Y.Z)
-type semVer struct {
-	Major, Minor, Patch int
+// parseVersionNumber parses a numeric version component, rejecting signs and leading zeros
+func parseVersionNumber(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty version number")
+	}
+	if !isNumeric(s) {
+		return 0, fmt.Errorf("'%s' is not a non-negative integer", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("'%s' must not contain leading zeros", s)
+	}
+	return strconv.Atoi(s)
 }
 
-// MaxSemVer returns the higher of two semantic versions
-func MaxSemVer(v1, v2 string) (string, error) {
+// parseIdentifiers splits and validates dot-separated pre-release or build identifiers
+func parseIdentifiers(s string, isPrerelease bool) ([]string, error) {
+	identifiers := strings.Split(s, ".")
+	for _, id := range identifiers {
+		if id == "" {
+			return nil, fmt.Errorf("empty identifier")
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return nil, fmt.Errorf("identifier '%s' contains invalid character '%c'", id, r)
+			}
+		}
+		if isPrerelease && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return nil, fmt.Errorf("numeric identifier '%s' must not contain leading zeros", id)
+		}
+	}
+	return identifiers, nil
+}
+
+// isNumeric reports whether s consists only of ASCII digits
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the semantic version as v<major>.<minor>.<patch>[-<prerelease>][+<build>]
+func (s semVer) String() string {
+	version := fmt.Sprintf("v%d.%d.%d", s.Major, s.Minor, s.Patch)
+	if len(s.Prerelease) > 0 {
+		version += "-" + strings.Join(s.Prerelease, ".")
+	}
+	if len(s.Build) > 0 {
+		version += "+" + strings.Join(s.Build, ".")
+	}
+	return version
+}
+
+// IsPrerelease reports whether the version carries pre-release identifiers
+func (s semVer) IsPrerelease() bool {
+	return len(s.Prerelease) > 0
+}
+
+// compareSemVer compares two semantic versions by precedence, returning -1, 0 or 1.
+// Build metadata is ignored, as required by Semantic Versioning 2.0.0.
+func compareSemVer(s1, s2 semVer) int {
+	if c := compareInts(s1.Major, s2.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(s1.Minor, s2.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(s1.Patch, s2.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(s1.Prerelease, s2.Prerelease)
+}
+
+// comparePrerelease compares pre-release identifier lists; a version without
+// pre-release identifiers has higher precedence than one with them
+func comparePrerelease(p1, p2 []string) int {
+	if len(p1) == 0 && len(p2) == 0 {
+		return 0
+	}
+	if len(p1) == 0 {
+		return 1
+	}
+	if len(p2) == 0 {
+		return -1
+	}
+	for i := 0; i < len(p1) && i < len(p2); i++ {
+		id1, id2 := p1[i], p2[i]
+		num1, num2 := isNumeric(id1), isNumeric(id2)
+		switch {
+		case num1 && num2:
+			// Identifiers are validated to have no leading zeros, so length orders first
+			if c := compareInts(len(id1), len(id2)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(id1, id2); c != 0 {
+				return c
+			}
+		case num1:
+			return -1 // numeric identifiers have lower precedence than alphanumeric ones
+		case num2:
+			return 1
+		default:
+			if c := strings.Compare(id1, id2); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(p1), len(p2))
+}
+
+// compareInts returns -1, 0 or 1 depending on the order of a and b
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// CompareSemVer parses and compares two version strings by semantic version precedence
+func CompareSemVer(v1, v2 string) (int, error) {
 	s1, err := ParseSemVer(v1)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 	s2, err := ParseSemVer(v2)
 	if err != nil {
-		return "", err
-	}
-	if s1.Major > s2.Major {
-		return v1, nil
-	}
-	if s1.Major < s2.Major {
-		return v2, nil
-	}
-	if s1.Minor > s2.Minor {
-		return v1, nil
+		return 0, err
 	}
-	if s1.Minor < s2.Minor {
-		return v2, nil
+	return compareSemVer(s1, s2), nil
+}
+
+// MaxSemVer returns the higher of two semantic versions
+func MaxSemVer(v1, v2 string) (string, error) {
+	c, err := CompareSemVer(v1, v2)
+	if err != nil {
+		return "", err
 	}
-	if s1.Patch >= s2.Patch {
+	if c >= 0 {
 		return v1, nil
 	}
 	return v2, nil
 }
 
+// sortVersions sorts versions in ascending order of precedence; versions that
+// fail to parse are ordered lexically after all valid versions
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		s1, err1 := ParseSemVer(versions[i])
+		s2, err2 := ParseSemVer(versions[j])
+		switch {
+		case err1 != nil && err2 != nil:
+			return versions[i] < versions[j]
+		case err1 != nil:
+			return false
+		case err2 != nil:
+			return true
+		}
+		return compareSemVer(s1, s2) < 0
+	})
+}
+
 // GetMajorVersion extracts the major version number as a string (e.g., "v1" from "v1.2.0")
 func GetMajorVersion(version string) (string, error) {
 	s, err := ParseSemVer(version)
@@ -97,17 +266,77 @@ func validateNewVersion(newVersion, currentVersion string) error {
 		return nil // Tag existence checked later by ensureTagDoesNotExist
 	}
 
-	// Compare versions: newVer must be greater than currVer
-	if newVer.Major < currVer.Major {
+	// Compare versions: newVer must take precedence over currVer
+	if compareSemVer(newVer, currVer) <= 0 {
 		return fmt.Errorf("new version %q must be greater than current version %q", newVersion, currentVersion)
 	}
-	if newVer.Major == currVer.Major {
-		if newVer.Minor < currVer.Minor {
-			return fmt.Errorf("new version %q must be greater than current version %q", newVersion, currentVersion)
+	return nil
+}
+
+// versionQuery represents a version prefix query such as v1, v1.2, v1.2.3 or v1.2.3-alpha
+type versionQuery struct {
+	Major, Minor, Patch int
+	Prerelease          []string
+	Components          int // number of numeric components given (1-3)
+}
+
+// parseVersionQuery parses a version prefix query of the form v<x>, v<x.y>, v<x.y.z> or v<x.y.z-prerelease>
+func parseVersionQuery(query string) (versionQuery, error) {
+	if err := validateVersion(query); err != nil {
+		return versionQuery{}, err
+	}
+	rest := strings.TrimPrefix(query, "v")
+	if strings.Contains(rest, "+") {
+		return versionQuery{}, fmt.Errorf("version query '%s' must not contain build metadata", query)
+	}
+
+	var prerelease []string
+	if idx := strings.Index(rest, "-"); idx >= 0 {
+		var err error
+		prerelease, err = parseIdentifiers(rest[idx+1:], true)
+		if err != nil {
+			return versionQuery{}, fmt.Errorf("invalid pre-release in '%s': %v", query, err)
 		}
-		if newVer.Minor == currVer.Minor && newVer.Patch <= currVer.Patch {
-			return fmt.Errorf("new version %q must be greater than current version %q", newVersion, currentVersion)
+		rest = rest[:idx]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return versionQuery{}, fmt.Errorf("invalid version query '%s': must be v<x>, v<x.y> or v<x.y.z>", query)
+	}
+	if prerelease != nil && len(parts) != 3 {
+		return versionQuery{}, fmt.Errorf("invalid version query '%s': a pre-release requires v<x.y.z>", query)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := parseVersionNumber(part)
+		if err != nil {
+			return versionQuery{}, fmt.Errorf("invalid version query '%s': %v", query, err)
 		}
+		numbers[i] = n
 	}
-	return nil
+	return versionQuery{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], Prerelease: prerelease, Components: len(parts)}, nil
+}
+
+// matches reports whether a version satisfies the query. Prefix queries (v<x>, v<x.y>)
+// match any version in the series; v<x.y.z> matches the release and its build variants;
+// v<x.y.z-prerelease> matches that exact pre-release.
+func (q versionQuery) matches(version string) bool {
+	s, err := ParseSemVer(version)
+	if err != nil {
+		return false
+	}
+	if s.Major != q.Major {
+		return false
+	}
+	if q.Components >= 2 && s.Minor != q.Minor {
+		return false
+	}
+	if q.Components == 3 {
+		if s.Patch != q.Patch {
+			return false
+		}
+		return comparePrerelease(s.Prerelease, q.Prerelease) == 0
+	}
+	return true
 }
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseSemVer tests parsing of release, pre-release and build metadata versions
+func TestParseSemVer(t *testing.T) {
+	tests := []struct {
+		version     string
+		expected    semVer
+		expectError bool
+	}{
+		{version: "v1.2.3", expected: semVer{Major: 1, Minor: 2, Patch: 3}},
+		{version: "v1.2", expected: semVer{Major: 1, Minor: 2}},
+		{version: "v1.2.3-alpha.1", expected: semVer{Major: 1, Minor: 2, Patch: 3, Prerelease: []string{"alpha", "1"}}},
+		{version: "v1.2.3+build.5", expected: semVer{Major: 1, Minor: 2, Patch: 3, Build: []string{"build", "5"}}},
+		{version: "v1.2.3-rc.1+exp.sha.5114f85", expected: semVer{Major: 1, Minor: 2, Patch: 3, Prerelease: []string{"rc", "1"}, Build: []string{"exp", "sha", "5114f85"}}},
+		{version: "v1.0.0-x-y-z.--", expected: semVer{Major: 1, Prerelease: []string{"x-y-z", "--"}}},
+		{version: "v1.0.0+001", expected: semVer{Major: 1, Build: []string{"001"}}},
+		{version: "v1", expectError: true},
+		{version: "v1.2.3.4", expectError: true},
+		{version: "v01.2.3", expectError: true},
+		{version: "v1.2.-3", expectError: true},
+		{version: "v1.2.3-", expectError: true},
+		{version: "v1.2.3-alpha..1", expectError: true},
+		{version: "v1.2.3-01", expectError: true},
+		{version: "v1.2.3+", expectError: true},
+		{version: "v1.2.3-alpha_1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseSemVer(tt.version)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error parsing %q, got %+v", tt.version, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %v", tt.version, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestCompareSemVerPrecedence tests the precedence example chain from Semantic Versioning 2.0.0
+func TestCompareSemVerPrecedence(t *testing.T) {
+	ordered := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.1.0",
+		"v2.0.0",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		c, err := CompareSemVer(ordered[i], ordered[i+1])
+		if err != nil {
+			t.Fatalf("Unexpected error comparing %q and %q: %v", ordered[i], ordered[i+1], err)
+		}
+		if c != -1 {
+			t.Errorf("Expected %q < %q, got comparison %d", ordered[i], ordered[i+1], c)
+		}
+		max, err := MaxSemVer(ordered[i], ordered[i+1])
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if max != ordered[i+1] {
+			t.Errorf("Expected MaxSemVer(%q, %q) = %q, got %q", ordered[i], ordered[i+1], ordered[i+1], max)
+		}
+	}
+
+	// Build metadata does not affect precedence
+	if c, _ := CompareSemVer("v1.0.0+build.1", "v1.0.0+build.2"); c != 0 {
+		t.Errorf("Expected build metadata to be ignored, got comparison %d", c)
+	}
+}
+
+// TestDetermineLatestVersion tests that releases are preferred over pre-releases
+func TestDetermineLatestVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		expected string
+	}{
+		{name: "releases", versions: []string{"v1.2.0", "v1.10.0", "v1.9.0"}, expected: "v1.10.0"},
+		{name: "release_over_prerelease", versions: []string{"v1.0.0", "v1.1.0-rc.1"}, expected: "v1.0.0"},
+		{name: "only_prereleases", versions: []string{"v1.0.0-beta.2", "v1.0.0-beta.11", "v1.0.0-alpha"}, expected: "v1.0.0-beta.11"},
+		{name: "skip_invalid", versions: []string{"latest", "v0.1.0"}, expected: "v0.1.0"},
+		{name: "empty", versions: nil, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := determineLatestVersion(tt.versions)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestSortVersions tests ordering of versions by precedence
+func TestSortVersions(t *testing.T) {
+	versions := []string{"v1.10.0", "v1.2.0-rc.1", "v1.2.0", "invalid", "v0.9.0"}
+	sortVersions(versions)
+	expected := []string{"v0.9.0", "v1.2.0-rc.1", "v1.2.0", "v1.10.0", "invalid"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected %v, got %v", expected, versions)
+	}
+}
+
+// TestValidateNewVersion tests release validation with pre-release versions
+func TestValidateNewVersion(t *testing.T) {
+	tests := []struct {
+		newVersion, currentVersion string
+		expectError                bool
+	}{
+		{newVersion: "v1.2.4", currentVersion: "v1.2.3"},
+		{newVersion: "v1.3.0-rc.1", currentVersion: "v1.2.3"},
+		{newVersion: "v1.3.0-rc.2", currentVersion: "v1.3.0-rc.1"},
+		{newVersion: "v1.3.0", currentVersion: "v1.3.0-rc.2"},
+		{newVersion: "v1.3.0-rc.1", currentVersion: "v1.3.0", expectError: true},
+		{newVersion: "v1.2.3+build.2", currentVersion: "v1.2.3+build.1", expectError: true},
+		{newVersion: "v1.2.2", currentVersion: "v1.2.3", expectError: true},
+	}
+	for _, tt := range tests {
+		err := validateNewVersion(tt.newVersion, tt.currentVersion)
+		if tt.expectError && err == nil {
+			t.Errorf("Expected error for %q -> %q, got none", tt.currentVersion, tt.newVersion)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("Unexpected error for %q -> %q: %v", tt.currentVersion, tt.newVersion, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Shells supported for printing the environment of a project
+const (
+	shellBash = "bash"
+	shellZsh  = "zsh"
+	shellFish = "fish"
+	shellJSON = "json"
+)
+
+// detectShell returns the shell named by the flag, or the shell in $SHELL, defaulting to bash
+func detectShell(flag string) (string, error) {
+	if flag != "" {
+		switch flag {
+		case shellBash, shellZsh, shellFish, shellJSON:
+			return flag, nil
+		}
+		return "", newCodedError(ErrCodeInvalidArgs, "unsupported shell '%s' (use bash, zsh, fish or json)", flag)
+	}
+	switch shell := filepath.Base(os.Getenv("SHELL")); shell {
+	case shellZsh, shellFish:
+		return shell, nil
+	}
+	return shellBash, nil
+}
+
+// formatEnvironment formats environment variables as commands for the shell, sorted by name
+func formatEnvironment(env map[string]string, shell string) string {
+	if shell == shellJSON {
+		data, _ := json.MarshalIndent(env, "", "  ") // a map of strings always marshals
+		return string(data) + "\n"
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		if shell == shellFish {
+			fmt.Fprintf(&b, "set -gx %s %s\n", name, quoteFish(env[name]))
+		} else {
+			fmt.Fprintf(&b, "export %s=%s\n", name, quotePOSIX(env[name]))
+		}
+	}
+	return b.String()
+}
+
+// quotePOSIX quotes a value for bash and zsh
+func quotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes a value for fish
+func quoteFish(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// environWith returns the environment of the current process extended with env
+func environWith(env map[string]string) []string {
+	environ := os.Environ()
+	for name, value := range env {
+		environ = append(environ, name+"="+value)
+	}
+	return environ
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// hashPackageTree computes a content hash of the file tree of a package, excluding .git
+// directories and .gitignore files. Entries are hashed in path order with their type, executable
+// bit and contents, so the hash does not depend on timestamps or the order of the file system.
+func hashPackageTree(dir string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Name() == ".git" && entry.IsDir() {
+			return filepath.SkipDir
+		}
+		if entry.Name() == ".gitignore" || entry.IsDir() {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to walk %s: %v", dir, err)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to compute relative path for %s: %v", path, err)
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %v", path, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read symlink %s: %v", path, err)
+			}
+			fmt.Fprintf(hash, "symlink %s %s\n", rel, target)
+			continue
+		}
+		contentHash, err := hashFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "file %s %t %x\n", rel, info.Mode()&0111 != 0, contentHash)
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// hashFile returns the sha256 of the contents of a file
+func hashFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return hash.Sum(nil), nil
+}
+
+// hashCommitTree computes the tree hash of a commit by extracting it into a temporary directory
+// next to the repository
+func hashCommitTree(dir, sha1 string) (string, error) {
+	tmpPath, err := os.MkdirTemp(filepath.Dir(dir), "tmp-tree-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpPath)
+	if err := archiveCommit(dir, sha1, tmpPath); err != nil {
+		return "", err
+	}
+	return hashPackageTree(tmpPath)
+}
+
+// verifyPackageTree checks that the files in dir match the recorded tree hash
+func verifyPackageTree(dir, treeHash string) error {
+	actual, err := hashPackageTree(dir)
+	if err != nil {
+		return err
+	}
+	if actual != treeHash {
+		return fmt.Errorf("tree hash mismatch in %s: expected %s, got %s", dir, treeHash, actual)
+	}
+	return nil
+}
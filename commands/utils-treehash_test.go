@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashPackageTree tests that the tree hash covers file contents and executable bits but
+// ignores Git metadata
+func TestHashPackageTree(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string, mode os.FileMode) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to set mode of %s: %v", name, err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := hashPackageTree(dir)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return h
+	}
+
+	writeFile("Project.json", "{}", 0644)
+	writeFile(filepath.Join("src", "main.t"), "return {}", 0644)
+	original := hash()
+	if !strings.HasPrefix(original, "sha256:") {
+		t.Errorf("Expected sha256 tree hash, got %q", original)
+	}
+
+	// Git metadata does not contribute
+	writeFile(filepath.Join(".git", "HEAD"), "ref: refs/heads/main", 0644)
+	writeFile(".gitignore", "build/", 0644)
+	if h := hash(); h != original {
+		t.Errorf("Expected Git metadata to be ignored, hash changed from %s to %s", original, h)
+	}
+
+	// Contents and executable bits do
+	writeFile(filepath.Join("src", "main.t"), "return nil", 0644)
+	modified := hash()
+	if modified == original {
+		t.Errorf("Expected hash to change with file contents")
+	}
+	writeFile(filepath.Join("src", "main.t"), "return nil", 0755)
+	if h := hash(); h == modified {
+		t.Errorf("Expected hash to change with the executable bit")
+	}
+	if err := verifyPackageTree(dir, original); err == nil || !strings.Contains(err.Error(), "tree hash mismatch") {
+		t.Errorf("Expected tree hash mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"cosm/types"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Verification states of a materialized package
+const (
+	verifyOK         = "ok"
+	verifyModified   = "modified"   // files differ from the recorded tree hash
+	verifyMissing    = "missing"    // package directory or its Project.json does not exist
+	verifyUnverified = "unverified" // no tree hash is recorded for the package
+)
+
+// packageVerification reports the integrity of a package in the depot
+type packageVerification struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	SHA1     string `json:"sha1"`
+	TreeHash string `json:"treehash,omitempty"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+}
+
+// specsProblem is a registered version whose specs.json cannot be loaded, so that the packages of
+// that version cannot be verified
+type specsProblem struct {
+	Registry string `json:"registry"`
+	Package  string `json:"package"`
+	Version  string `json:"version"`
+	Message  string `json:"message"`
+}
+
+// verifyResult is the JSON document printed by cosm verify --output json
+type verifyResult struct {
+	Packages []packageVerification `json:"packages"`
+	Problems []specsProblem        `json:"problems,omitempty"` // only checked with --all
+	Failed   int                   `json:"failed"`
+}
+
+// Verify re-hashes the packages of the build list of the current project, or all packages in the
+// depot with --all, and reports packages that were modified or are incomplete
+func Verify(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return newCodedError(ErrCodeInvalidArgs, "cosm verify takes no arguments")
+	}
+	all, _ := cmd.Flags().GetBool("all")
+	cosmDir, err := getCosmDir()
+	if err != nil {
+		return err
+	}
+	registriesDir := setupRegistriesDir(cosmDir)
+
+	var packages []packageVerification
+	var problems []specsProblem
+	if all {
+		packages, problems, err = collectDepotPackages(cosmDir, registriesDir)
+	} else {
+		packages, err = collectBuildListPackages(registriesDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := verifyResult{Packages: []packageVerification{}, Problems: problems}
+	for _, problem := range problems {
+		fmt.Fprintf(messages, "%-10s %s@%s in registry '%s': %s\n", "unreadable", problem.Package, problem.Version, problem.Registry, problem.Message)
+	}
+	counts := make(map[string]int)
+	for _, pkg := range packages {
+		pkg.Status, err = verifyPackage(cosmDir, pkg)
+		if err != nil {
+			return err
+		}
+		counts[pkg.Status]++
+		if pkg.Status == verifyModified || pkg.Status == verifyMissing {
+			result.Failed++
+		}
+		result.Packages = append(result.Packages, pkg)
+		fmt.Fprintf(messages, "%-10s %s (%s)\n", pkg.Status, formatPackageVersion(pkg), pkg.Path)
+	}
+	fmt.Fprintf(messages, "Verified %d packages: %d ok, %d modified, %d missing, %d unverified\n",
+		len(packages), counts[verifyOK], counts[verifyModified], counts[verifyMissing], counts[verifyUnverified])
+
+	if result.Failed > 0 {
+		return &CommandError{
+			Code:    ErrCodeVerification,
+			Err:     fmt.Errorf("%d packages failed verification; remove them from the depot and run 'cosm activate' to restore them", result.Failed),
+			Details: result,
+		}
+	}
+	if len(problems) > 0 {
+		return &CommandError{
+			Code:    ErrCodeVerification,
+			Err:     fmt.Errorf("%d registered versions have unreadable specs; run 'cosm registry verify' on their registries", len(problems)),
+			Details: result,
+		}
+	}
+	return printJSONResult(result)
+}
+
+// formatPackageVersion returns name@version, or the name alone if the version is unknown
+func formatPackageVersion(pkg packageVerification) string {
+	if pkg.Version == "" {
+		return pkg.Name
+	}
+	return pkg.Name + "@" + pkg.Version
+}
+
+// collectBuildListPackages returns the packages in .cosm/buildlist.json of the current project,
+// looking up tree hashes in the registries for build lists written before they were recorded
+func collectBuildListPackages(registriesDir string) ([]packageVerification, error) {
+	buildListFile := filepath.Join(".cosm", "buildlist.json")
+	if _, err := os.Stat(buildListFile); os.IsNotExist(err) {
+		return nil, newCodedError(ErrCodeNotFound, "no build list found in %s; run 'cosm activate' first or use --all", buildListFile)
+	}
+	buildList, err := loadBuildListFile(buildListFile)
+	if err != nil {
+		return nil, err
+	}
+	var packages []packageVerification
+	for _, dep := range buildList.Dependencies {
+		if dep.Develop {
+			continue // Development checkouts are expected to change
+		}
+		treeHash := dep.TreeHash
+		if treeHash == "" {
+			if specs, _, err := findDependency(dep.Name, dep.Version, dep.UUID, registriesDir); err == nil {
+				treeHash = specs.TreeHash
+			}
+		}
+		packages = append(packages, packageVerification{
+			Name:     dep.Name,
+			Version:  dep.Version,
+			SHA1:     dep.SHA1,
+			TreeHash: treeHash,
+			Path:     dep.Path,
+		})
+	}
+	sortPackageVerifications(packages)
+	return packages, nil
+}
+
+// collectDepotPackages returns every package in the packages directory of the depot together with
+// the tree hash recorded for it in any registry, and the registered versions whose specs cannot
+// be loaded
+func collectDepotPackages(cosmDir, registriesDir string) ([]packageVerification, []specsProblem, error) {
+	index, problems, err := indexRegisteredSpecs(registriesDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	paths, err := listPackageDirs(cosmDir) // Skips extractions in progress
+	if err != nil {
+		return nil, nil, err
+	}
+	var packages []packageVerification
+	for _, path := range paths {
+		name, sha1 := filepath.Base(filepath.Dir(path)), filepath.Base(path)
+		pkg := packageVerification{Name: name, SHA1: sha1, Path: path}
+		if specs, ok := index[name+"@"+sha1]; ok {
+			pkg.Version = specs.Version
+			pkg.TreeHash = specs.TreeHash
+		}
+		packages = append(packages, pkg)
+	}
+	sortPackageVerifications(packages)
+	return packages, problems, nil
+}
+
+// indexRegisteredSpecs maps <name>@<sha1> to the specs of every version in every registry. Versions
+// whose specs cannot be loaded are returned as problems instead.
+func indexRegisteredSpecs(registriesDir string) (map[string]types.Specs, []specsProblem, error) {
+	registryNames, err := loadRegistryNames(registriesDir)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no registries") {
+			return nil, nil, err
+		}
+		registryNames = []string{} // Packages of a depot without registries are unverified
+	}
+	index := make(map[string]types.Specs)
+	var problems []specsProblem
+	for _, registryName := range registryNames {
+		registry, _, err := LoadRegistryMetadata(registriesDir, registryName)
+		if err != nil {
+			return nil, nil, err
+		}
+		for packageName := range registry.Packages {
+			versions, err := loadVersions(registriesDir, registryName, packageName)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, version := range versions {
+				specs, err := loadSpecs(registriesDir, registryName, packageName, version)
+				if err != nil {
+					problems = append(problems, specsProblem{Registry: registryName, Package: packageName, Version: version, Message: err.Error()})
+					continue
+				}
+				key := specs.Name + "@" + specs.SHA1
+				if existing, ok := index[key]; !ok || existing.TreeHash == "" {
+					index[key] = specs
+				}
+			}
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].Registry != problems[j].Registry {
+			return problems[i].Registry < problems[j].Registry
+		}
+		if problems[i].Package != problems[j].Package {
+			return problems[i].Package < problems[j].Package
+		}
+		return problems[i].Version < problems[j].Version
+	})
+	return index, problems, nil
+}
+
+// sortPackageVerifications orders packages by name, version and SHA1
+func sortPackageVerifications(packages []packageVerification) {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		if packages[i].Version != packages[j].Version {
+			return packages[i].Version < packages[j].Version
+		}
+		return packages[i].SHA1 < packages[j].SHA1
+	})
+}
+
+// verifyPackage re-hashes a package and compares it with its recorded tree hash
+func verifyPackage(cosmDir string, pkg packageVerification) (string, error) {
+	dir := filepath.Join(cosmDir, pkg.Path)
+	if !checkDestinationExists(dir) {
+		return verifyMissing, nil
+	}
+	if pkg.TreeHash == "" {
+		return verifyUnverified, nil
+	}
+	actual, err := hashPackageTree(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", formatPackageVersion(pkg), err)
+	}
+	if actual != pkg.TreeHash {
+		return verifyModified, nil
+	}
+	return verifyOK, nil
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// requirementPath is a chain of requirements from the project root to a dependency
+type requirementPath struct {
+	steps   []requirement
+	version string // version of the dependency required at the end of the path
+}
+
+// whyResult is the JSON document printed by cosm why --output json
+type whyResult struct {
+	Package  string             `json:"package"`
+	Versions []whyVersionResult `json:"versions"` // one per major version in the build list
+}
+
+// whyVersionResult lists the requirement paths to a selected version
+type whyVersionResult struct {
+	Version string          `json:"version"`
+	Develop bool            `json:"develop,omitempty"`
+	Paths   []whyPathResult `json:"paths"`
+}
+
+// whyPathResult is a requirement path starting at the project
+type whyPathResult struct {
+	Path     []string `json:"path"` // project name followed by <name>@<version> of each requirement
+	Selected bool     `json:"selected"`
+}
+
+// Why explains how a dependency got into the build list by printing every requirement path
+// from the project to the dependency, marking the paths that set the selected version
+func Why(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return newCodedError(ErrCodeInvalidArgs, "expected a package name (e.g., cosm why mypkg)")
+	}
+	packageName := args[0]
+	project, err := loadProject("Project.json")
+	if err != nil {
+		return err
+	}
+	registriesDir, err := getRegistriesDir()
+	if err != nil {
+		return err
+	}
+
+	resolver, err := resolveProjectRequirements(project, registriesDir)
+	if err != nil {
+		return fmt.Errorf("failed to generate build list for %s: %v", project.Name, err)
+	}
+	buildList, err := resolver.buildList()
+	if err != nil {
+		return fmt.Errorf("failed to generate build list for %s: %v", project.Name, err)
+	}
+
+	var keys []string
+	for key, entry := range buildList.Dependencies {
+		if entry.Name == packageName {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return newCodedError(ErrCodeNotFound, "dependency '%s' is not in the build list of %s", packageName, project.Name)
+	}
+	sort.Strings(keys)
+
+	result := whyResult{Package: packageName, Versions: []whyVersionResult{}}
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Fprintln(messages)
+		}
+		entry := buildList.Dependencies[key]
+		selected := entry.Version
+		if entry.Develop {
+			selected += " (development checkout)"
+		}
+		fmt.Fprintf(messages, "Dependency '%s' %s is required by:\n", packageName, selected)
+		versionResult := whyVersionResult{Version: entry.Version, Develop: entry.Develop, Paths: []whyPathResult{}}
+		for _, path := range findRequirementPaths(resolver.requirements, key) {
+			marker := ""
+			last := path.steps[len(path.steps)-1]
+			isSelected := entry.Develop && last.node == developNode(key) || !entry.Develop && path.version == entry.Version
+			if isSelected {
+				marker = " (selected)"
+			}
+			fmt.Fprintf(messages, "  %s%s\n", formatRequirementPath(project.Name, path), marker)
+			versionResult.Paths = append(versionResult.Paths, whyPathResult{Path: requirementPathParts(project.Name, path), Selected: isSelected})
+		}
+		result.Versions = append(result.Versions, versionResult)
+	}
+	return printJSONResult(result)
+}
+
+// findRequirementPaths returns every path through the requirement graph from the project
+// root to a requirement of the given key. Paths do not revisit a node, so cycles are skipped.
+func findRequirementPaths(requirements map[string][]requirement, key string) []requirementPath {
+	var paths []requirementPath
+	onPath := map[string]bool{rootNode: true}
+	var visit func(node string, steps []requirement)
+	visit = func(node string, steps []requirement) {
+		for _, req := range requirements[node] {
+			if onPath[req.node] {
+				continue
+			}
+			next := append(append([]requirement{}, steps...), req)
+			if req.key == key {
+				paths = append(paths, requirementPath{steps: next, version: req.version})
+			}
+			onPath[req.node] = true
+			visit(req.node, next)
+			onPath[req.node] = false
+		}
+	}
+	visit(rootNode, nil)
+	return paths
+}
+
+// formatRequirementPath formats a path as <project> -> <name>@<version> -> ...
+func formatRequirementPath(projectName string, path requirementPath) string {
+	return strings.Join(requirementPathParts(projectName, path), " -> ")
+}
+
+// requirementPathParts returns the project name followed by <name>@<version> of each step of a path
+func requirementPathParts(projectName string, path requirementPath) []string {
+	parts := []string{projectName}
+	for _, step := range path.steps {
+		parts = append(parts, fmt.Sprintf("%s@%s", step.name, step.version))
+	}
+	return parts
+}
@@ -1,6 +1,14 @@
 // cosm --version
+// cosm <command> --output json
+// cosm <command> --offline
 // cosm status
-// cosm activate
+// cosm activate [--shell bash|zsh|fish] [--refresh] [--jobs N]
+// cosm run [--jobs N] -- <command> [args...]
+// cosm env [--shell bash|zsh|fish|json] [--jobs N]
+// cosm verify [--all]
+// cosm gc [--dry-run] [--keep-latest N]
+// cosm search <query> [--registry <registry name>] [--language <language>]
+// cosm info <name>[@v<version>] [--registry <registry name>]
 
 // cosm registry status <registry name>
 // cosm registry init <registry name> <giturl>
@@ -11,6 +19,10 @@
 // cosm registry add <registry name> <giturl>
 // cosm registry rm <registry name> <package name> [--force]
 // cosm registry rm <registry name> <package name> v<version> [--force]
+// cosm registry yank <registry name> <package name> v<version> [--reason <reason>]
+// cosm registry unyank <registry name> <package name> v<version>
+// cosm registry dependents <registry name> <package name> [v<version>]
+// cosm registry verify <registry name> [--deep] [--fix]
 
 // cosm init <package name>
 // cosm init <package name> --language <language>
@@ -39,6 +51,9 @@
 
 // cosm downgrade <name> v<version>
 
+// cosm why <name>
+// cosm graph [--format dot|mermaid|json] [--all]
+
 package main
 
 import (
@@ -53,7 +68,7 @@ var version string // Populated by -ldflags during build
 
 // PrintVersion prints the version of the cosm tool and exits
 func PrintVersion() {
-	fmt.Printf("cosm version %s\n", version)
+	fmt.Fprintf(commands.Messages(), "cosm version %s\n", version)
 	os.Exit(0)
 }
 
@@ -69,22 +84,28 @@ func main() {
 		Use:   "cosm",
 		Short: "A cosmic package manager",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Welcome to Cosm! Use a subcommand like 'status', 'activate', or 'registry'.")
+			fmt.Fprintln(commands.Messages(), "Welcome to Cosm! Use a subcommand like 'status', 'activate', or 'registry'.")
 		},
 	}
 
 	var versionFlag bool
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Print the version number")
-	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+	rootCmd.PersistentFlags().String("output", "text", "Output format: text or json")
+	rootCmd.PersistentFlags().Bool("offline", false, "Never contact Git remotes; use local registries and clones (also set by COSM_OFFLINE)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		if versionFlag {
 			PrintVersion()
 		}
+		offline, _ := cmd.Flags().GetBool("offline")
+		commands.SetOffline(offline)
+		return nil
 	}
 
 	var statusCmd = &cobra.Command{
-		Use:   "status",
-		Short: "Show the current cosmic status",
-		Run:   commands.Status, // Call from commands package,
+		Use:          "status",
+		Short:        "Show the status of the current project and its dependencies",
+		RunE:         commands.Status,
+		SilenceUsage: true, // Prevent usage output in stderr
 	}
 
 	var activateCmd = &cobra.Command{
@@ -93,6 +114,68 @@ func main() {
 		RunE:         commands.Activate,
 		SilenceUsage: true, // Prevent usage output in stderr
 	}
+	activateCmd.Flags().String("shell", "", "Shell to start: bash, zsh or fish (default: $SHELL)")
+	activateCmd.Flags().Bool("refresh", false, "Regenerate the build list even if it is up-to-date")
+	activateCmd.Flags().IntP("jobs", "j", 0, "Number of packages to fetch concurrently (default: number of CPUs)")
+
+	var runCmd = &cobra.Command{
+		Use:          "run -- <command> [args...]",
+		Short:        "Run a command in the environment of the current project",
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         commands.Run,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	runCmd.Flags().IntP("jobs", "j", 0, "Number of packages to fetch concurrently (default: number of CPUs)")
+
+	var envCmd = &cobra.Command{
+		Use:          "env",
+		Short:        "Print the environment of the current project for eval",
+		Args:         cobra.NoArgs,
+		RunE:         commands.Env,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	envCmd.Flags().String("shell", "", "Shell to print the environment for: bash, zsh, fish or json (default: $SHELL)")
+	envCmd.Flags().IntP("jobs", "j", 0, "Number of packages to fetch concurrently (default: number of CPUs)")
+
+	var verifyCmd = &cobra.Command{
+		Use:          "verify",
+		Short:        "Check the packages of the build list or the depot against their tree hashes",
+		Args:         cobra.NoArgs,
+		RunE:         commands.Verify,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	verifyCmd.Flags().Bool("all", false, "Verify every package in the depot instead of the build list of the current project")
+
+	var gcCmd = &cobra.Command{
+		Use:          "gc",
+		Short:        "Remove packages and clones not used by any activated project from the depot",
+		Args:         cobra.NoArgs,
+		RunE:         commands.Gc,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	gcCmd.Flags().Bool("dry-run", false, "Report what would be removed without removing anything")
+	gcCmd.Flags().Int("keep-latest", 0, "Also keep the N latest versions of every package")
+
+	var searchCmd = &cobra.Command{
+		Use:          "search [query]",
+		Short:        "Search packages by name, keyword or description across all registries",
+		Args:         cobra.MaximumNArgs(1),
+		RunE:         commands.Search,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	searchCmd.Flags().String("registry", "", "Only search the given registry")
+	searchCmd.Flags().String("language", "", "Only show packages written in the given language")
+	searchCmd.Flags().Bool("refresh", false, "Pull all registries even if they were updated recently")
+
+	var infoCmd = &cobra.Command{
+		Use:          "info <package>[@v<version>]",
+		Short:        "Show the registered versions, dependencies and dependents of a package",
+		Args:         cobra.ExactArgs(1),
+		RunE:         commands.Info,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	infoCmd.Flags().String("registry", "", "Only show the package in the given registry")
+	infoCmd.Flags().Bool("refresh", false, "Pull all registries even if they were updated recently")
 
 	// initCmd initializes a new project
 	var initCmd = &cobra.Command{
@@ -113,6 +196,7 @@ func main() {
 		RunE:         commands.Add,
 		SilenceUsage: true,
 	}
+	addCmd.Flags().Bool("refresh", false, "Pull all registries even if they were updated recently")
 
 	var rmCmd = &cobra.Command{
 		Use:          "rm [name]",
@@ -135,40 +219,64 @@ func main() {
 	releaseCmd.Flags().String("registry", "", "Specify a registry to release to")
 
 	var developCmd = &cobra.Command{
-		Use:   "develop [package-name]",
-		Short: "Switch an existing dependency to development mode",
-		Args:  cobra.ExactArgs(1),
-		Run:   commands.Develop,
+		Use:          "develop [package-name]",
+		Short:        "Switch an existing dependency to development mode",
+		Args:         cobra.ExactArgs(1),
+		RunE:         commands.Develop,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+
+	var whyCmd = &cobra.Command{
+		Use:          "why [package-name]",
+		Short:        "Show the requirement paths that bring a dependency into the build list",
+		Args:         cobra.ExactArgs(1),
+		RunE:         commands.Why,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+
+	var graphCmd = &cobra.Command{
+		Use:          "graph",
+		Short:        "Export the dependency graph of the current project",
+		Args:         cobra.NoArgs,
+		RunE:         commands.Graph,
+		SilenceUsage: true, // Prevent usage output in stderr
 	}
+	graphCmd.Flags().String("format", "dot", "Output format: dot, mermaid or json")
+	graphCmd.Flags().Bool("all", false, "Include requirement edges of superseded versions")
 
 	var freeCmd = &cobra.Command{
-		Use:   "free [package-name]",
-		Short: "Close development mode for an existing dependency",
-		Args:  cobra.ExactArgs(1),
-		Run:   commands.Free,
+		Use:          "free [package-name]",
+		Short:        "Close development mode for an existing dependency",
+		Args:         cobra.ExactArgs(1),
+		RunE:         commands.Free,
+		SilenceUsage: true, // Prevent usage output in stderr
 	}
 
 	var upgradeCmd = &cobra.Command{
-		Use:   "upgrade [name] [v<version>]",
-		Short: "Upgrade a dependency or all dependencies",
-		Args:  cobra.RangeArgs(0, 2),
-		Run:   commands.Upgrade,
+		Use:          "upgrade [name] [v<version>]",
+		Short:        "Upgrade a dependency or all dependencies",
+		Args:         cobra.RangeArgs(0, 2),
+		RunE:         commands.Upgrade,
+		SilenceUsage: true, // Prevent usage output in stderr
 	}
-	upgradeCmd.Flags().Bool("all", false, "Upgrade all direct dependencies")
+	upgradeCmd.Flags().Bool("all", false, "Upgrade all direct and transitive dependencies")
 	upgradeCmd.Flags().Bool("latest", false, "Use the latest version instead of the latest compatible version")
+	upgradeCmd.Flags().Bool("refresh", false, "Pull all registries even if they were updated recently")
 
 	var downgradeCmd = &cobra.Command{
-		Use:   "downgrade [name] v<version>",
-		Short: "Downgrade a dependency to an older version",
-		Args:  cobra.ExactArgs(2),
-		Run:   commands.Downgrade,
+		Use:          "downgrade [name] v<version>",
+		Short:        "Downgrade a dependency to an older version",
+		Args:         cobra.ExactArgs(2),
+		RunE:         commands.Downgrade,
+		SilenceUsage: true, // Prevent usage output in stderr
 	}
+	downgradeCmd.Flags().Bool("refresh", false, "Pull all registries even if they were updated recently")
 
 	var registryCmd = &cobra.Command{
 		Use:   "registry",
 		Short: "Manage package registries",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Registry command requires a subcommand (e.g., 'status', 'init').")
+			fmt.Fprintln(commands.Messages(), "Registry command requires a subcommand (e.g., 'status', 'init').")
 		},
 	}
 
@@ -231,7 +339,42 @@ func main() {
 		RunE:         commands.RegistryRm,
 		SilenceUsage: true, // Prevent usage output in stderr
 	}
-	registryRmCmd.Flags().BoolP("force", "f", false, "Force removal of the package or version")
+	registryRmCmd.Flags().BoolP("force", "f", false, "Remove without confirmation, even if registered versions require it")
+
+	var registryYankCmd = &cobra.Command{
+		Use:          "yank [registry-name] [package-name] v<version>",
+		Short:        "Mark a version as yanked so that it is no longer selected for new requirements",
+		Args:         cobra.ExactArgs(3),
+		RunE:         commands.RegistryYank,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	registryYankCmd.Flags().String("reason", "", "Reason for yanking the version, shown to its users")
+
+	var registryUnyankCmd = &cobra.Command{
+		Use:          "unyank [registry-name] [package-name] v<version>",
+		Short:        "Make a yanked version selectable again",
+		Args:         cobra.ExactArgs(3),
+		RunE:         commands.RegistryUnyank,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+
+	var registryDependentsCmd = &cobra.Command{
+		Use:          "dependents [registry-name] [package-name] [v<version>]",
+		Short:        "List the registered versions that require a package or version",
+		Args:         cobra.RangeArgs(2, 3),
+		RunE:         commands.RegistryDependents,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+
+	var registryVerifyCmd = &cobra.Command{
+		Use:          "verify [registry-name]",
+		Short:        "Cross-check versions, specs and build lists of a registry",
+		Args:         cobra.ExactArgs(1),
+		RunE:         commands.RegistryVerify,
+		SilenceUsage: true, // Prevent usage output in stderr
+	}
+	registryVerifyCmd.Flags().Bool("deep", false, "Also check the SHA1 of every version against the Git tags of the package")
+	registryVerifyCmd.Flags().Bool("fix", false, "Regenerate versions.json and the indexes from the package directories and push the repairs")
 
 	registryCmd.AddCommand(registryStatusCmd)
 	registryCmd.AddCommand(registryInitCmd)
@@ -240,9 +383,19 @@ func main() {
 	registryCmd.AddCommand(registryUpdateCmd)
 	registryCmd.AddCommand(registryAddCmd)
 	registryCmd.AddCommand(registryRmCmd)
+	registryCmd.AddCommand(registryDependentsCmd)
+	registryCmd.AddCommand(registryYankCmd)
+	registryCmd.AddCommand(registryUnyankCmd)
+	registryCmd.AddCommand(registryVerifyCmd)
 
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(activateCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(infoCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(rmCmd)
@@ -251,9 +404,43 @@ func main() {
 	rootCmd.AddCommand(freeCmd)
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(downgradeCmd)
+	rootCmd.AddCommand(whyCmd)
+	rootCmd.AddCommand(graphCmd)
 	rootCmd.AddCommand(registryCmd)
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1) // Remove manual error printing, let Cobra handle it
+	// Usage errors detected by Cobra are reported as invalid arguments
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return commands.InvalidArgs(err)
+	})
+	codeArgsErrors(rootCmd)
+
+	// The output format is selected before Cobra validates the arguments, so that usage errors
+	// are reported as JSON as well
+	restoreOutput, err := commands.SetOutputFormat(commands.OutputFormatArg(os.Args[1:]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(commands.ExitCode(err))
+	}
+	rootCmd.SilenceErrors = commands.JSONOutput() // Errors are reported as JSON by commands.HandleError
+
+	err = rootCmd.Execute()
+	if err != nil {
+		exitCode := commands.HandleError(err) // Cobra prints errors unless JSON output is selected
+		restoreOutput()
+		os.Exit(exitCode)
+	}
+	restoreOutput()
+}
+
+// codeArgsErrors marks the errors of the argument validators of cmd and its subcommands as
+// invalid arguments
+func codeArgsErrors(cmd *cobra.Command) {
+	for _, sub := range cmd.Commands() {
+		if validate := sub.Args; validate != nil {
+			sub.Args = func(cmd *cobra.Command, args []string) error {
+				return commands.InvalidArgs(validate(cmd, args))
+			}
+		}
+		codeArgsErrors(sub)
 	}
 }
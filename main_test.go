@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"cosm/commands"
 	"cosm/types"
@@ -60,7 +63,52 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+// TestStatus tests the cosm status command in a package root
 func TestStatus(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Package B with a newer patch and a newer major version
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	releasePackage(t, packageDir, "v2.0.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Package A depends on B@v1.0.0
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	addDependencyToProject(t, packageDir, "B", "v1.0.0")
+	commitAndPushPackageChanges(t, packageDir, "added B@v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Project without dependencies
+	projectDir := initPackage(t, tempDir, "myproject")
+	project := loadProjectFile(t, filepath.Join(projectDir, "Project.json"))
+	stdout, stderr, err := runCommand(t, projectDir, "status")
+	expectedOutput := fmt.Sprintf("Project 'myproject' v0.1.0 (UUID: %s)\n  No dependencies.\n", project.UUID)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+
+	// Project depends on A only, B is transitive
+	addDependencyToProject(t, projectDir, "A", "v1.1.0")
+	stdout, stderr, err = runCommand(t, projectDir, "status")
+	expectedOutput = fmt.Sprintf("Project 'myproject' v0.1.0 (UUID: %s)\n", project.UUID) +
+		"Direct dependencies:\n" +
+		"  A v1.1.0 from 'myreg'\n" +
+		"Transitive dependencies:\n" +
+		"  B v1.0.0 from 'myreg' [update: v1.1.0, new major: v2.0.0]\n" +
+		"Build list not generated yet; run 'cosm activate' to generate .cosm/buildlist.json\n"
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+
+	// Status outside a package root fails
+	_, stderr, err = runCommand(t, tempDir, "status")
+	if err == nil || !strings.Contains(stderr, "Project.json not found") {
+		t.Errorf("Expected missing Project.json error, got err=%v stderr=%q", err, stderr)
+	}
 }
 
 func TestActivateSuccess(t *testing.T) {
@@ -428,6 +476,50 @@ func TestRegistryAdd(t *testing.T) {
 	}
 }
 
+func TestRegistryAddPrerelease(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Create registry
+	registryName := "myreg"
+	_, registryDir := setupRegistry(t, tempDir, registryName)
+
+	// Create a package with release candidates
+	packageName := "mypkg"
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, packageName, "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0-rc.1")
+	releasePackage(t, packageDir, "v1.1.0-rc.2")
+	releasePackage(t, packageDir, "v1.1.0-rc.10+build.7")
+
+	// Releasing a pre-release with --minor publishes the final version
+	stdout, stderr := releasePackage(t, packageDir, "--minor")
+	expectedOutput := fmt.Sprintf("Released version '%s' for project '%s'\n", "v1.1.0", packageName)
+	if stdout != expectedOutput {
+		t.Errorf("Expected output %q, got %q\nStderr: %s", expectedOutput, stdout, stderr)
+	}
+
+	// A pre-release older than the current version is rejected
+	_, _, err := runCommand(t, packageDir, "release", "v1.1.0-rc.11")
+	if err == nil {
+		t.Errorf("Expected error releasing a pre-release older than v1.1.0, got none")
+	}
+
+	// Add package to registry, versions are ordered by precedence
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	project := loadProjectFile(t, filepath.Join(packageDir, "Project.json"))
+	expectedVersions := []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0-rc.2", "v1.1.0-rc.10+build.7", "v1.1.0"}
+	verifyVersionsJSON(t, filepath.Join(registryDir, strings.ToUpper(string(packageName[0])), packageName, "versions.json"), expectedVersions)
+	for _, version := range expectedVersions {
+		verifyRegistryPackage(t, registryDir, packageName, project.UUID, gitURL, version)
+	}
+
+	// Adding a release candidate explicitly keeps the major version key
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, packageName, "v1.1.0-rc.2")
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), packageName, "v1.1.0-rc.2")
+}
+
 func TestRegistryAddSingle(t *testing.T) {
 	tempDir, cleanup := setupTestEnv(t)
 	defer cleanup()
@@ -673,6 +765,166 @@ func TestRmDependencyMultipleMatches(t *testing.T) {
 	}
 }
 
+func TestUpgradeDependency(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Setup package with multiple versions
+	packageName := "mypkg"
+	packageVersions := []string{"v1.0.0", "v1.1.0", "v1.1.1", "v1.2.0", "v1.3.0-rc.1", "v2.0.0"}
+	packageDir, packageGitURL := setupPackageWithGit(t, tempDir, packageName, packageVersions[0])
+	for _, version := range packageVersions {
+		releasePackage(t, packageDir, version)
+	}
+	addPackageToRegistry(t, tempDir, registryName, packageGitURL)
+
+	// Initialize project and add the oldest version
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, packageName, "v1.0.0")
+
+	// Upgrade to the latest patch in the v1.1 series
+	stdout, stderr, err := runCommand(t, projectDir, "upgrade", packageName, "v1.1")
+	expectedOutput := fmt.Sprintf("Upgraded dependency '%s' from v1.0.0 to v1.1.1\n", packageName)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), packageName, "v1.1.1")
+
+	// Upgrade within the current major version, skipping the release candidate
+	stdout, stderr, err = runCommand(t, projectDir, "upgrade", packageName)
+	expectedOutput = fmt.Sprintf("Upgraded dependency '%s' from v1.1.1 to v1.2.0\n", packageName)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), packageName, "v1.2.0")
+
+	// An older version query is rejected
+	_, stderr, err = runCommand(t, projectDir, "upgrade", packageName, "v1.0")
+	if err == nil || !strings.Contains(stderr, "use 'cosm downgrade' instead") {
+		t.Errorf("Expected downgrade error, got err=%v stderr=%q", err, stderr)
+	}
+
+	// Crossing a major version requires --latest
+	_, stderr, err = runCommand(t, projectDir, "upgrade", packageName, "v2")
+	if err == nil || !strings.Contains(stderr, "use --latest") {
+		t.Errorf("Expected major version error, got err=%v stderr=%q", err, stderr)
+	}
+
+	// --latest adds the new major version as a separate dependency
+	stdout, stderr, err = runCommand(t, projectDir, "upgrade", packageName, "--latest")
+	expectedOutput = fmt.Sprintf("Added dependency '%s' v2.0.0 (new major version v2) to project\n", packageName)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), packageName, "v1.2.0")
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), packageName, "v2.0.0")
+}
+
+func TestUpgradeTransitiveDependency(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Package B with multiple versions
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Package A depends on B@v1.0.0
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	addDependencyToProject(t, packageDir, "B", "v1.0.0")
+	commitAndPushPackageChanges(t, packageDir, "added B@v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Project depends on A only
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "A", "v1.1.0")
+
+	// Upgrading the transitive dependency adds an explicit requirement
+	stdout, stderr, err := runCommand(t, projectDir, "upgrade", "B")
+	expectedOutput := "Upgraded transitive dependency 'B' from v1.0.0 to v1.1.0 (added as direct requirement)\n"
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "A", "v1.1.0")
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "B", "v1.1.0")
+
+	// Everything is up-to-date now
+	stdout, stderr, err = runCommand(t, projectDir, "upgrade", "--all")
+	checkOutput(t, stdout, stderr, "", err, false, 0)
+}
+
+func TestDowngradeDependency(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Package E
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "E", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	releasePackage(t, packageDir, "v1.2.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Package D requires increasingly newer versions of E
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "D", "v1.0.0")
+	addDependencyToProject(t, packageDir, "E", "v1.0.0")
+	commitAndPushPackageChanges(t, packageDir, "added E@v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	removeDependencyFromProject(t, packageDir, "E")
+	addDependencyToProject(t, packageDir, "E", "v1.2.0")
+	commitAndPushPackageChanges(t, packageDir, "added E@v1.2.0")
+	releasePackage(t, packageDir, "v1.2.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Project requires D@v1.2.0, which selects E@v1.2.0
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "D", "v1.2.0")
+
+	// Upgrading to a newer version is rejected
+	_, stderr, err := runCommand(t, projectDir, "downgrade", "E", "v1.3.0")
+	if err == nil || !strings.Contains(stderr, "use 'cosm upgrade' instead") {
+		t.Errorf("Expected upgrade error, got err=%v stderr=%q", err, stderr)
+	}
+
+	// Downgrading the transitive dependency E lowers D to its newest compatible version
+	stdout, stderr, err := runCommand(t, projectDir, "downgrade", "E", "v1.1.0")
+	expectedOutput := "Downgrading 'E' to v1.1.0 changes the following direct requirements:\n" +
+		"  E: added v1.1.0\n" +
+		"  D: v1.2.0 -> v1.1.0\n" +
+		"Downgraded dependency 'E' from v1.2.0 to v1.1.0\n"
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "D", "v1.1.0")
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "E", "v1.1.0")
+
+	// Downgrading the now direct dependency E only changes its own requirement
+	stdout, stderr, err = runCommand(t, projectDir, "downgrade", "E", "v1.0.0")
+	expectedOutput = "Downgrading 'E' to v1.0.0 changes the following direct requirements:\n" +
+		"  E: v1.1.0 -> v1.0.0\n" +
+		"Downgraded dependency 'E' from v1.1.0 to v1.0.0\n"
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "D", "v1.1.0")
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "E", "v1.0.0")
+
+	// A development checkout requiring a newer version is not lowered and refuses the downgrade
+	if _, stderr, err := runCommand(t, projectDir, "develop", "D"); err != nil {
+		t.Fatalf("Failed to develop D: %v\nStderr: %s", err, stderr)
+	}
+	devDir := filepath.Join(tempDir, ".cosm", "dev", "D@v1")
+	removeDependencyFromProject(t, devDir, "E")
+	addDependencyToProject(t, devDir, "E", "v1.2.0")
+	_, stderr, err = runCommand(t, projectDir, "downgrade", "E", "v1.1.0")
+	if err == nil || !strings.Contains(stderr, "development checkout of 'D' requires 'E' v1.2.0, newer than v1.1.0") {
+		t.Errorf("Expected downgrade to be refused by the development checkout, got err=%v stderr=%q", err, stderr)
+	}
+	verifyProjectDependencies(t, filepath.Join(projectDir, "Project.json"), "E", "v1.0.0")
+}
+
 func TestMinimalVersionSelectionBuildList(t *testing.T) {
 	tempDir, cleanup := setupTestEnv(t)
 	defer cleanup()
@@ -738,12 +990,15 @@ func TestMinimalVersionSelectionBuildList(t *testing.T) {
 	addDependencyToProject(t, packageDir, "B", "v1.2.0")
 	addDependencyToProject(t, packageDir, "C", "v1.2.0")
 
-	// Run cosm activate
-	stdout, stderr, err := runCommand(t, packageDir, "activate")
+	// Run cosm activate with a single job, which makes the progress output deterministic
+	stdout, stderr, err := runCommand(t, packageDir, "activate", "--jobs", "1")
 	if err != nil {
 		t.Errorf("Unexpected error: %v\nStderr: %s", err, stderr)
 	}
-	expectedOutput := fmt.Sprintf("Generated build list for %s in .cosm/buildlist.json\nStarting interactive shell. Press ctrl-d or type 'exit' to quit.\n", "A")
+	expectedOutput := fmt.Sprintf("Generated build list for %s in .cosm/buildlist.json\n"+
+		"Making 4 packages available (jobs: 1)\n"+
+		"[1/4] Available B@v1.2.0\n[2/4] Available C@v1.2.0\n[3/4] Available D@v1.4.0\n[4/4] Available E@v1.2.0\n"+
+		"Starting interactive shell. Press ctrl-d or type 'exit' to quit.\n", "A")
 	if stdout != expectedOutput {
 		t.Errorf("Expected output %q, got %q\nStderr: %s", expectedOutput, stdout, stderr)
 	}
@@ -790,6 +1045,87 @@ func TestMinimalVersionSelectionBuildList(t *testing.T) {
 	}
 }
 
+func TestDevelopAndFree(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Packages B and C
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "C", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Project depends on B
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "B", "v1.0.0")
+
+	// Switch B to development mode
+	devDir := filepath.Join(tempDir, ".cosm", "dev", "B@v1")
+	stdout, stderr, err := runCommand(t, projectDir, "develop", "B")
+	expectedOutput := fmt.Sprintf("Dependency 'B' is now in development mode at %s\n", devDir)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	project := loadProjectFile(t, filepath.Join(projectDir, "Project.json"))
+	for _, dep := range project.Deps {
+		if dep.Name == "B" && !dep.Develop {
+			t.Errorf("Expected dependency 'B' to be in development mode")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(devDir, "Project.json")); os.IsNotExist(err) {
+		t.Fatalf("Expected development checkout with Project.json at %s", devDir)
+	}
+
+	// Requirements of the development checkout take part in MVS
+	addDependencyToProject(t, devDir, "C", "v1.0.0")
+	if _, stderr, err := runCommand(t, projectDir, "activate"); err != nil {
+		t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+	}
+	buildList := loadBuildList(t, filepath.Join(projectDir, ".cosm", "buildlist.json"))
+	if len(buildList.Dependencies) != 2 {
+		t.Errorf("Expected 2 dependencies, got %d: %v", len(buildList.Dependencies), buildList.Dependencies)
+	}
+	for _, dep := range buildList.Dependencies {
+		if dep.Name == "B" && (!dep.Develop || dep.Path != filepath.Join("dev", "B@v1")) {
+			t.Errorf("Expected 'B' to point to its development checkout, got %+v", dep)
+		}
+	}
+	envData, err := os.ReadFile(filepath.Join(projectDir, ".cosm", ".env"))
+	if err != nil {
+		t.Fatalf("Failed to read .cosm/.env: %v", err)
+	}
+	if !strings.Contains(string(envData), filepath.Join(devDir, "src", "?.t")) {
+		t.Errorf("Expected .cosm/.env to contain development path, got %q", string(envData))
+	}
+
+	// Free B again
+	stdout, stderr, err = runCommand(t, projectDir, "free", "B")
+	expectedOutput = fmt.Sprintf("Dependency 'B' is no longer in development mode and uses release v1.0.0 (development checkout kept at %s)\n", devDir)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	if _, stderr, err := runCommand(t, projectDir, "activate"); err != nil {
+		t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+	}
+	buildList = loadBuildList(t, filepath.Join(projectDir, ".cosm", "buildlist.json"))
+	if len(buildList.Dependencies) != 1 {
+		t.Errorf("Expected 1 dependency, got %d: %v", len(buildList.Dependencies), buildList.Dependencies)
+	}
+	for _, dep := range buildList.Dependencies {
+		if dep.Develop || dep.Path != filepath.Join("packages", "B", dep.SHA1) {
+			t.Errorf("Expected 'B' to point to its registered release, got %+v", dep)
+		}
+	}
+
+	// Freeing a dependency that is not in development mode fails
+	_, stderr, err = runCommand(t, projectDir, "free", "B")
+	if err == nil || !strings.Contains(stderr, "is not in development mode") {
+		t.Errorf("Expected error freeing 'B' twice, got err=%v stderr=%q", err, stderr)
+	}
+}
+
 // TestMakePackageAvailable tests the MakePackageAvailable function
 func TestMakePackageAvailable(t *testing.T) {
 	tempDir, cleanup := setupTestEnv(t)
@@ -818,8 +1154,14 @@ func TestMakePackageAvailable(t *testing.T) {
 		t.Fatalf("Failed to create packages dir: %v", err)
 	}
 
-	// Capture initial branch
+	// Capture initial branch and commit
 	initialBranch := getCloneBranch(t, cloneDir)
+	initialHead := getCloneHead(t, cloneDir)
+
+	// Leave an incomplete copy behind, which must be replaced
+	if err := os.MkdirAll(filepath.Join(destPath, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create incomplete package dir: %v", err)
+	}
 
 	// Call MakePackageAvailable
 	err := commands.MakePackageAvailable(cosmDir, &specs)
@@ -847,6 +1189,1365 @@ func TestMakePackageAvailable(t *testing.T) {
 		t.Errorf("Expected project name %q, got %q", packageName, project.Name)
 	}
 
-	// Verify clone branch is reverted
+	// Verify the checkout of the clone is untouched
 	verifyCloneBranch(t, cloneDir, initialBranch)
+	if head := getCloneHead(t, cloneDir); head != initialHead {
+		t.Errorf("Expected clone HEAD to stay at %s, got %s", initialHead, head)
+	}
+
+	// Verify no temporary directories are left behind
+	entries, err := os.ReadDir(filepath.Dir(destPath))
+	if err != nil {
+		t.Fatalf("Failed to read package dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != specs.SHA1 {
+		t.Errorf("Expected only %s in %s, got %v", specs.SHA1, filepath.Dir(destPath), entries)
+	}
+}
+
+// TestWhy tests that cosm why prints every requirement path and marks the selected one
+func TestWhy(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Package B with two versions
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Package A depends on B@v1.1.0
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	addDependencyToProject(t, packageDir, "B", "v1.1.0")
+	commitAndPushPackageChanges(t, packageDir, "added B@v1.1.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Project depends on A and directly on the older B
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "A", "v1.1.0")
+	addDependencyToProject(t, projectDir, "B", "v1.0.0")
+
+	stdout, stderr, err := runCommand(t, projectDir, "why", "B")
+	paths := []string{
+		"  myproject -> A@v1.1.0 -> B@v1.1.0 (selected)\n",
+		"  myproject -> B@v1.0.0\n",
+	}
+	if err != nil || !strings.HasPrefix(stdout, "Dependency 'B' v1.1.0 is required by:\n") {
+		t.Fatalf("Unexpected output: err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+	for _, path := range paths {
+		if !strings.Contains(stdout, path) {
+			t.Errorf("Expected output to contain %q, got %q", path, stdout)
+		}
+	}
+
+	// Unknown dependencies are reported
+	_, stderr, err = runCommand(t, projectDir, "why", "C")
+	if err == nil || !strings.Contains(stderr, "dependency 'C' is not in the build list of myproject") {
+		t.Errorf("Expected not found error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestGraph tests exporting the dependency graph with and without superseded versions
+func TestGraph(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+
+	// Package B with two versions
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	bUUID := loadProjectFile(t, filepath.Join(packageDir, "Project.json")).UUID
+
+	// Package A depends on B@v1.0.0
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	addDependencyToProject(t, packageDir, "B", "v1.0.0")
+	commitAndPushPackageChanges(t, packageDir, "added B@v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	aUUID := loadProjectFile(t, filepath.Join(packageDir, "Project.json")).UUID
+
+	// Project depends on A and B@v1.1.0
+	projectDir := initPackage(t, tempDir, "myproject")
+	projectUUID := loadProjectFile(t, filepath.Join(projectDir, "Project.json")).UUID
+	addDependencyToProject(t, projectDir, "A", "v1.1.0")
+	addDependencyToProject(t, projectDir, "B", "v1.1.0")
+
+	// JSON output of the selected build list
+	stdout, stderr, err := runCommand(t, projectDir, "graph", "--format", "json")
+	if err != nil {
+		t.Fatalf("graph failed: %v, stderr: %s", err, stderr)
+	}
+	var graph struct {
+		Nodes []struct{ ID, Name, Version string }
+		Edges []struct{ From, To, Version string }
+	}
+	if err := json.Unmarshal([]byte(stdout), &graph); err != nil {
+		t.Fatalf("Failed to parse graph JSON: %v\n%s", err, stdout)
+	}
+	if len(graph.Nodes) != 3 {
+		t.Errorf("Expected 3 nodes, got %+v", graph.Nodes)
+	}
+	expectedEdges := map[string]string{
+		projectUUID + "@v0 -> " + aUUID + "@v1": "v1.1.0",
+		projectUUID + "@v0 -> " + bUUID + "@v1": "v1.1.0",
+		aUUID + "@v1 -> " + bUUID + "@v1":       "v1.0.0",
+	}
+	if len(graph.Edges) != len(expectedEdges) {
+		t.Errorf("Expected %d edges, got %+v", len(expectedEdges), graph.Edges)
+	}
+	for _, edge := range graph.Edges {
+		if version, ok := expectedEdges[edge.From+" -> "+edge.To]; !ok || version != edge.Version {
+			t.Errorf("Unexpected edge %+v", edge)
+		}
+	}
+
+	// DOT and Mermaid output
+	stdout, _, err = runCommand(t, projectDir, "graph")
+	if err != nil || !strings.HasPrefix(stdout, "digraph \"myproject\" {\n") || !strings.Contains(stdout, fmt.Sprintf("%q -> %q [label=\"v1.0.0\"];", aUUID+"@v1", bUUID+"@v1")) {
+		t.Errorf("Unexpected DOT output: err=%v stdout=%q", err, stdout)
+	}
+	stdout, _, err = runCommand(t, projectDir, "graph", "--format", "mermaid")
+	if err != nil || !strings.HasPrefix(stdout, "graph TD\n") || !strings.Contains(stdout, "-->|\"v1.0.0\"|") {
+		t.Errorf("Unexpected Mermaid output: err=%v stdout=%q", err, stdout)
+	}
+
+	// Invalid format
+	_, stderr, err = runCommand(t, projectDir, "graph", "--format", "svg")
+	if err == nil || !strings.Contains(stderr, "unsupported graph format 'svg'") {
+		t.Errorf("Expected format error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with a package
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Registry status as a single JSON document on stdout
+	stdout, stderr, err := runCommand(t, tempDir, "registry", "status", registryName, "--output", "json")
+	if err != nil {
+		t.Fatalf("registry status failed: %v, stderr: %s", err, stderr)
+	}
+	var registryStatus struct {
+		Registry string
+		Packages []struct{ Name, UUID, GitURL string }
+	}
+	if err := json.Unmarshal([]byte(stdout), &registryStatus); err != nil {
+		t.Fatalf("Failed to parse registry status JSON: %v\n%s", err, stdout)
+	}
+	if registryStatus.Registry != registryName || len(registryStatus.Packages) != 1 || registryStatus.Packages[0].Name != "mypkg" {
+		t.Errorf("Unexpected registry status %+v", registryStatus)
+	}
+
+	// Adding a dependency reports the selected version and registry
+	projectDir := initPackage(t, tempDir, "myproject")
+	stdout, stderr, err = runCommand(t, projectDir, "--output", "json", "add", "mypkg")
+	if err != nil {
+		t.Fatalf("add failed: %v, stderr: %s", err, stderr)
+	}
+	var added struct{ Action, Name, Version, Registry string }
+	if err := json.Unmarshal([]byte(stdout), &added); err != nil {
+		t.Fatalf("Failed to parse add JSON: %v\n%s", err, stdout)
+	}
+	if added.Action != "added" || added.Name != "mypkg" || added.Version != "v0.1.0" || added.Registry != registryName {
+		t.Errorf("Unexpected add result %+v", added)
+	}
+	if !strings.Contains(stderr, "Added dependency 'mypkg'") {
+		t.Errorf("Expected human-readable message on stderr, got %q", stderr)
+	}
+
+	// Status lists the direct dependency
+	stdout, stderr, err = runCommand(t, projectDir, "status", "--output", "json")
+	if err != nil {
+		t.Fatalf("status failed: %v, stderr: %s", err, stderr)
+	}
+	var status struct {
+		Project struct{ Name string }
+		Direct  []struct{ Name, Version string }
+	}
+	if err := json.Unmarshal([]byte(stdout), &status); err != nil {
+		t.Fatalf("Failed to parse status JSON: %v\n%s", err, stdout)
+	}
+	if status.Project.Name != "myproject" || len(status.Direct) != 1 || status.Direct[0].Version != "v0.1.0" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	// Commands that change projects or registries print a result document as well
+	otherDir := filepath.Join(tempDir, "other")
+	if err := os.MkdirAll(otherDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	otherRegistryURL := createBareRepo(t, tempDir, "otherreg.git")
+	results := []struct {
+		dir      string
+		args     []string
+		expected string
+	}{
+		{otherDir, []string{"init", "otherproject", "v0.1.0"}, `"name": "otherproject"`},
+		{projectDir, []string{"upgrade", "mypkg"}, `"changes": []`},
+		{projectDir, []string{"develop", "mypkg"}, `"action": "developed"`},
+		{projectDir, []string{"free", "mypkg"}, `"action": "freed"`},
+		{projectDir, []string{"why", "mypkg"}, `"selected": true`},
+		{tempDir, []string{"registry", "init", "otherreg", otherRegistryURL}, `"action": "initialized"`},
+		{tempDir, []string{"registry", "delete", "otherreg", "--force"}, `"action": "deleted"`},
+		{tempDir, []string{"registry", "clone", otherRegistryURL}, `"action": "cloned"`},
+	}
+	for _, tt := range results {
+		stdout, stderr, err := runCommand(t, tt.dir, append(tt.args, "--output", "json")...)
+		if err != nil || !json.Valid([]byte(stdout)) || !strings.Contains(stdout, tt.expected) {
+			t.Errorf("%v: expected a JSON document containing %s, got err=%v stdout=%q stderr=%q", tt.args, tt.expected, err, stdout, stderr)
+		}
+	}
+
+	// Errors use the code/message schema with an exit code per category
+	tests := []struct {
+		dir      string
+		args     []string
+		code     string
+		exitCode int
+	}{
+		{tempDir, []string{"registry", "status", "nonexistent"}, "not_found", 3},
+		{projectDir, []string{"rm", "otherpkg"}, "not_found", 3},
+		{projectDir, []string{"add", "mypkg", "v0.1.0"}, "already_exists", 4},
+		{projectDir, []string{"add", "mypkg", "0.1.0"}, "invalid_argument", 2},
+		{tempDir, []string{"registry", "status"}, "invalid_argument", 2},
+		{projectDir, []string{"add", "a", "b", "c", "d"}, "invalid_argument", 2},
+		{projectDir, []string{"status", "--bogus"}, "invalid_argument", 2},
+		{projectDir, []string{"downgrade", "mypkg", "v0.0.1"}, "not_found", 3},
+		{projectDir, []string{"downgrade", "mypkg"}, "invalid_argument", 2},
+		{tempDir, []string{"registry", "delete", "nonexistent", "--force"}, "not_found", 3},
+		{tempDir, []string{"registry", "init", registryName, otherRegistryURL}, "already_exists", 4},
+	}
+	for _, tt := range tests {
+		stdout, stderr, err := runCommand(t, tt.dir, append(tt.args, "--output", "json")...)
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != tt.exitCode {
+			t.Errorf("%v: expected exit code %d, got %v (stderr: %q)", tt.args, tt.exitCode, err, stderr)
+		}
+		var result struct {
+			Error struct{ Code, Message string }
+		}
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("%v: failed to parse error JSON: %v\n%s", tt.args, err, stdout)
+		}
+		if result.Error.Code != tt.code || result.Error.Message == "" {
+			t.Errorf("%v: unexpected error %+v", tt.args, result.Error)
+		}
+	}
+
+	// Unsupported output formats are rejected
+	_, stderr, err = runCommand(t, tempDir, "registry", "status", registryName, "--output", "yaml")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 || !strings.Contains(stderr, "unsupported output format 'yaml'") {
+		t.Errorf("Expected output format error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestRunAndEnv tests running a command in the project environment and printing the environment
+func TestRunAndEnv(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with a package and a project depending on it
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	specs := loadSpecs(t, tempDir, registryName, "mypkg", "v0.1.0")
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "mypkg", "v0.1.0")
+	packagePath := filepath.Join(tempDir, ".cosm", "packages", "mypkg", specs.SHA1, "src", "?.t")
+
+	// Run propagates the environment and the exit code of the command
+	stdout, stderr, err := runCommand(t, projectDir, "run", "--", "sh", "-c", "echo $TERRA_PATH; exit 3")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("Expected exit code 3, got %v (stderr: %q)", err, stderr)
+	}
+	expectedOutput := "src/?.t;" + packagePath + ";;\n"
+	if stdout != expectedOutput {
+		t.Errorf("Expected output %q, got %q (stderr: %q)", expectedOutput, stdout, stderr)
+	}
+	if strings.Contains(stderr, "Error:") {
+		t.Errorf("Expected no error message for a failing command, got %q", stderr)
+	}
+
+	// A command killed by a signal exits with 128 plus the signal number, and JSON output reports
+	// the exit status as an error document
+	_, stderr, err = runCommand(t, projectDir, "run", "--", "sh", "-c", "kill -TERM $$")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 143 {
+		t.Errorf("Expected exit code 143, got %v (stderr: %q)", err, stderr)
+	}
+	stdout, stderr, err = runCommand(t, projectDir, "run", "--output", "json", "--", "sh", "-c", "exit 3")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 || !strings.Contains(stdout, "'sh' exited with status 3") {
+		t.Errorf("Expected error document and exit code 3, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+
+	// Env prints the environment for the requested shell
+	stdout, stderr, err = runCommand(t, projectDir, "env", "--shell", "bash")
+	expectedOutput = fmt.Sprintf("export TERRA_PATH='src/?.t;%s;;'\n", packagePath)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	stdout, stderr, err = runCommand(t, projectDir, "env", "--shell", "fish")
+	expectedOutput = fmt.Sprintf("set -gx TERRA_PATH 'src/?.t;%s;;'\n", packagePath)
+	checkOutput(t, stdout, stderr, expectedOutput, err, false, 0)
+	stdout, stderr, err = runCommand(t, projectDir, "env", "--shell", "json")
+	if err != nil {
+		t.Fatalf("env failed: %v, stderr: %s", err, stderr)
+	}
+	var env map[string]string
+	if err := json.Unmarshal([]byte(stdout), &env); err != nil || env["TERRA_PATH"] != "src/?.t;"+packagePath+";;" {
+		t.Errorf("Unexpected JSON environment %q (err: %v)", stdout, err)
+	}
+
+	// Unsupported shells are rejected
+	_, stderr, err = runCommand(t, projectDir, "env", "--shell", "csh")
+	if err == nil || !strings.Contains(stderr, "unsupported shell 'csh'") {
+		t.Errorf("Expected shell error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestActivateShells tests the shell files written on activation and the refusal of nested activation
+func TestActivateShells(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	projectDir := initPackage(t, tempDir, "myproject")
+
+	// Activation writes the environment for bash/zsh and fish
+	if _, stderr, err := runCommand(t, projectDir, "activate", "--shell", "bash"); err != nil {
+		t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+	}
+	expectedFiles := map[string]string{
+		filepath.Join(".cosm", ".env"):      "export TERRA_PATH='src/?.t;;'\n",
+		filepath.Join(".cosm", ".env.fish"): "set -gx TERRA_PATH 'src/?.t;;'\n",
+	}
+	for file, expected := range expectedFiles {
+		data, err := os.ReadFile(filepath.Join(projectDir, file))
+		if err != nil || string(data) != expected {
+			t.Errorf("Expected %s to contain %q, got %q (err: %v)", file, expected, string(data), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, ".cosm", ".bashrc")); err != nil {
+		t.Errorf("Expected .cosm/.bashrc to be written: %v", err)
+	}
+
+	// JSON is not an interactive shell
+	_, stderr, err := runCommand(t, projectDir, "activate", "--shell", "json")
+	if err == nil || !strings.Contains(stderr, "cannot activate a json shell") {
+		t.Errorf("Expected shell error, got err=%v stderr=%q", err, stderr)
+	}
+
+	// Nested activation is refused
+	os.Setenv("COSM_PROMPT", "1")
+	defer os.Unsetenv("COSM_PROMPT")
+	_, stderr, err = runCommand(t, projectDir, "activate")
+	if err == nil || !strings.Contains(stderr, "already active") {
+		t.Errorf("Expected nested activation error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestBuildListDigest tests that the build list is regenerated when its inputs change, not when
+// Project.json is merely touched
+func TestBuildListDigest(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with a package and a project depending on it
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "mypkg", "v0.1.0")
+
+	activate := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, err := runCommand(t, projectDir, append([]string{"activate"}, args...)...)
+		if err != nil {
+			t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+		}
+		return stdout
+	}
+	generated := "Generated build list for myproject in .cosm/buildlist.json\n"
+	upToDate := "Build list up-to-date in .cosm/buildlist.json\n"
+
+	if stdout := activate(); !strings.HasPrefix(stdout, generated) {
+		t.Errorf("Expected build list to be generated, got %q", stdout)
+	}
+	if buildList := loadBuildList(t, filepath.Join(projectDir, ".cosm", "buildlist.json")); !strings.HasPrefix(buildList.Digest, "sha256:") {
+		t.Errorf("Expected build list to record a digest, got %q", buildList.Digest)
+	}
+
+	// Touching Project.json does not change the inputs
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(projectDir, "Project.json"), future, future); err != nil {
+		t.Fatalf("Failed to touch Project.json: %v", err)
+	}
+	if stdout := activate(); !strings.HasPrefix(stdout, upToDate) {
+		t.Errorf("Expected build list to be up-to-date, got %q", stdout)
+	}
+
+	// A registry update changes the inputs
+	releasePackage(t, packageDir, "v0.2.0")
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", registryName, "mypkg", "v0.2.0"); err != nil {
+		t.Fatalf("Failed to add version: %v\nStderr: %s", err, stderr)
+	}
+	stdout, stderr, err := runCommand(t, projectDir, "status")
+	if err != nil || !strings.Contains(stdout, "is out of date") {
+		t.Errorf("Expected status to report a stale build list, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+	if stdout := activate(); !strings.HasPrefix(stdout, generated) {
+		t.Errorf("Expected build list to be regenerated, got %q", stdout)
+	}
+
+	// --refresh forces regeneration
+	if stdout := activate("--refresh"); !strings.HasPrefix(stdout, generated) {
+		t.Errorf("Expected build list to be regenerated with --refresh, got %q", stdout)
+	}
+
+	// A build list left over from before the last dependency was removed is stale
+	if _, stderr, err := runCommand(t, projectDir, "rm", "mypkg"); err != nil {
+		t.Fatalf("Failed to remove mypkg: %v\nStderr: %s", err, stderr)
+	}
+	stdout, stderr, err = runCommand(t, projectDir, "status")
+	if err != nil || !strings.Contains(stdout, "is out of date") {
+		t.Errorf("Expected status to report a stale build list without dependencies, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+}
+
+// TestConcurrentActivate tests that parallel activations sharing a depot clone and materialize
+// packages without corrupting each other
+func TestConcurrentActivate(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with B and A depending on B
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	addDependencyToProject(t, packageDir, "B", "v1.0.0")
+	commitAndPushPackageChanges(t, packageDir, "added B@v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+
+	// Remove the clones so that every activation races to create them
+	clonesDir := filepath.Join(tempDir, ".cosm", "clones")
+	clones, err := os.ReadDir(clonesDir)
+	if err != nil {
+		t.Fatalf("Failed to read clones: %v", err)
+	}
+	for _, clone := range clones {
+		if err := os.RemoveAll(filepath.Join(clonesDir, clone.Name())); err != nil {
+			t.Fatalf("Failed to remove clone: %v", err)
+		}
+	}
+
+	const projects = 8
+	var projectDirs []string
+	for i := 0; i < projects; i++ {
+		projectDir := initPackage(t, tempDir, fmt.Sprintf("project%d", i))
+		addDependencyToProject(t, projectDir, "A", "v1.1.0")
+		projectDirs = append(projectDirs, projectDir)
+	}
+
+	// Activate all projects while the registry is being updated
+	errs := make(chan error, 2*projects)
+	var wg sync.WaitGroup
+	for _, projectDir := range projectDirs {
+		wg.Add(2)
+		go func(dir string) {
+			defer wg.Done()
+			if _, stderr, err := runCommand(t, dir, "activate"); err != nil {
+				errs <- fmt.Errorf("activate in %s: %v\nStderr: %s", dir, err, stderr)
+			}
+		}(projectDir)
+		go func() {
+			defer wg.Done()
+			if _, stderr, err := runCommand(t, tempDir, "registry", "update", registryName); err != nil {
+				errs <- fmt.Errorf("registry update: %v\nStderr: %s", err, stderr)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Every project sees both packages, and no temporary clones are left behind
+	for _, projectDir := range projectDirs {
+		buildList := loadBuildList(t, filepath.Join(projectDir, ".cosm", "buildlist.json"))
+		if len(buildList.Dependencies) != 2 {
+			t.Errorf("Expected 2 dependencies in %s, got %v", projectDir, buildList.Dependencies)
+		}
+		for _, dep := range buildList.Dependencies {
+			if _, err := os.Stat(filepath.Join(tempDir, ".cosm", dep.Path, "Project.json")); err != nil {
+				t.Errorf("Expected package %s to be available: %v", dep.Name, err)
+			}
+		}
+	}
+	entries, err := os.ReadDir(clonesDir)
+	if err != nil {
+		t.Fatalf("Failed to read clones: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected exactly 2 clones, got %d", len(entries))
+	}
+}
+
+// TestActivateJobs tests that activate makes packages available with a bounded number of jobs,
+// reports progress and aggregates the errors of all failed packages
+func TestActivateJobs(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with B and C and a project depending on both
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	for _, name := range []string{"B", "C"} {
+		packageDir, gitURL := setupPackageWithGit(t, tempDir, name, "v1.0.0")
+		releasePackage(t, packageDir, "v1.0.0")
+		addPackageToRegistry(t, tempDir, registryName, gitURL)
+	}
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "B", "v1.0.0")
+	addDependencyToProject(t, projectDir, "C", "v1.0.0")
+
+	// removeDepotEntries empties a depot directory without removing it
+	removeDepotEntries := func(name string) {
+		t.Helper()
+		dir := filepath.Join(tempDir, ".cosm", name)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				t.Fatalf("Failed to remove %s: %v", entry.Name(), err)
+			}
+		}
+	}
+	removeDepotEntries("clones")
+
+	stdout, stderr, err := runCommand(t, projectDir, "activate", "--jobs", "2")
+	if err != nil {
+		t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+	}
+	for _, want := range []string{"Making 2 packages available (jobs: 2)\n", "[2/2] Available "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, stdout)
+		}
+	}
+
+	// Negative job counts are rejected
+	_, stderr, err = runCommand(t, projectDir, "activate", "--jobs", "-1")
+	if err == nil || stderr != "Error: --jobs must be a positive number, got -1\n" {
+		t.Errorf("Expected --jobs -1 to be rejected, got err=%v stderr=%q", err, stderr)
+	}
+
+	// Every failed package is reported once the remotes are gone
+	removeDepotEntries("clones")
+	removeDepotEntries("packages")
+	for _, name := range []string{"B", "C"} {
+		if err := os.RemoveAll(filepath.Join(tempDir, name+".git")); err != nil {
+			t.Fatalf("Failed to remove remote of %s: %v", name, err)
+		}
+	}
+	_, stderr, err = runCommand(t, projectDir, "activate", "--jobs", "2")
+	if err == nil {
+		t.Fatalf("Expected activate to fail without remotes")
+	}
+	for _, want := range []string{"2 of 2 packages could not be made available", "\n  B@v1.0.0: ", "\n  C@v1.0.0: "} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected error to contain %q, got %q", want, stderr)
+		}
+	}
+}
+
+// TestVerify tests that tree hashes are recorded on registration, checked on materialization and
+// reported by cosm verify
+func TestVerify(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// A depot without packages has nothing to verify
+	stdout, stderr, err := runCommand(t, tempDir, "verify", "--all")
+	checkOutput(t, stdout, stderr, "Verified 0 packages: 0 ok, 0 modified, 0 missing, 0 unverified\n", err, false, 0)
+
+	// Setup registry with a package and a project depending on it
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	specs := loadSpecs(t, tempDir, registryName, "mypkg", "v0.1.0")
+	if !strings.HasPrefix(specs.TreeHash, "sha256:") {
+		t.Fatalf("Expected specs.json to record a tree hash, got %q", specs.TreeHash)
+	}
+	projectDir := initPackage(t, tempDir, "myproject")
+	addDependencyToProject(t, projectDir, "mypkg", "v0.1.0")
+	if _, stderr, err := runCommand(t, projectDir, "activate"); err != nil {
+		t.Fatalf("Failed to activate: %v\nStderr: %s", err, stderr)
+	}
+	packagePath := filepath.Join("packages", "mypkg", specs.SHA1)
+
+	stdout, stderr, err = runCommand(t, projectDir, "verify")
+	expected := fmt.Sprintf("ok         mypkg@v0.1.0 (%s)\nVerified 1 packages: 1 ok, 0 modified, 0 missing, 0 unverified\n", packagePath)
+	checkOutput(t, stdout, stderr, expected, err, false, 0)
+
+	// Tampered files are reported with a dedicated error code
+	tampered := filepath.Join(tempDir, ".cosm", packagePath, "extra.t")
+	if err := os.WriteFile(tampered, []byte("return 1"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with package: %v", err)
+	}
+	stdout, _, err = runCommand(t, tempDir, "verify", "--all")
+	if err == nil || !strings.Contains(stdout, "modified   mypkg@v0.1.0") {
+		t.Errorf("Expected verify --all to report a modified package, got err=%v stdout=%q", err, stdout)
+	}
+	stdout, _, err = runCommand(t, projectDir, "verify", "--output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 6 {
+		t.Errorf("Expected exit code 6, got %v", err)
+	}
+	var doc struct {
+		Error struct {
+			Code    string       `json:"code"`
+			Details verifyOutput `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &doc); err != nil {
+		t.Fatalf("Failed to parse JSON output %q: %v", stdout, err)
+	}
+	if doc.Error.Code != "verification_failed" || doc.Error.Details.Failed != 1 || doc.Error.Details.Packages[0].Status != "modified" {
+		t.Errorf("Unexpected JSON error document: %s", stdout)
+	}
+
+	// Partial packages are reported as missing
+	if err := os.Remove(filepath.Join(tempDir, ".cosm", packagePath, "Project.json")); err != nil {
+		t.Fatalf("Failed to remove Project.json: %v", err)
+	}
+	stdout, _, err = runCommand(t, projectDir, "verify")
+	if err == nil || !strings.Contains(stdout, "missing    mypkg@v0.1.0") {
+		t.Errorf("Expected verify to report a missing package, got err=%v stdout=%q", err, stdout)
+	}
+
+	// Materialization rejects files that do not match the recorded tree hash
+	if err := os.RemoveAll(filepath.Join(tempDir, ".cosm", packagePath)); err != nil {
+		t.Fatalf("Failed to remove package: %v", err)
+	}
+	buildListFile := filepath.Join(projectDir, ".cosm", "buildlist.json")
+	buildList := loadBuildList(t, buildListFile)
+	for key, dep := range buildList.Dependencies {
+		dep.TreeHash = "sha256:0000"
+		buildList.Dependencies[key] = dep
+	}
+	data, err := json.MarshalIndent(buildList, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal build list: %v", err)
+	}
+	if err := os.WriteFile(buildListFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write build list: %v", err)
+	}
+	_, stderr, err = runCommand(t, projectDir, "activate")
+	if err == nil || !strings.Contains(stderr, "tree hash mismatch") {
+		t.Errorf("Expected activate to reject the package, got err=%v stderr=%q", err, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".cosm", packagePath)); !os.IsNotExist(err) {
+		t.Errorf("Expected rejected package not to be materialized, got %v", err)
+	}
+
+	// Registered versions with unreadable specs are reported without aborting verify or gc
+	specsFile := filepath.Join(tempDir, ".cosm", "registries", registryName, "M", "mypkg", "v0.1.0", "specs.json")
+	if err := os.WriteFile(specsFile, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write specs.json: %v", err)
+	}
+	stdout, _, err = runCommand(t, tempDir, "verify", "--all")
+	if err == nil || !strings.Contains(stdout, "unreadable mypkg@v0.1.0 in registry 'myreg'") ||
+		!strings.Contains(stdout, "Verified 0 packages") {
+		t.Errorf("Expected verify --all to report unreadable specs, got err=%v stdout=%q", err, stdout)
+	}
+	if _, stderr, err := runCommand(t, tempDir, "gc", "--keep-latest", "1"); err != nil {
+		t.Errorf("Expected gc --keep-latest to ignore unreadable specs: %v\nStderr: %s", err, stderr)
+	}
+}
+
+// verifyOutput mirrors the JSON document of cosm verify
+type verifyOutput struct {
+	Packages []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"packages"`
+	Failed int `json:"failed"`
+}
+
+// TestGc tests that cosm gc removes packages and clones not used by activated projects while
+// keeping development checkouts and, with --keep-latest, the latest versions
+func TestGc(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	cosmDir := filepath.Join(tempDir, ".cosm")
+
+	// Setup registry with two versions of A and a package B
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "A", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	releasePackage(t, packageDir, "v1.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	packageDir, gitURL = setupPackageWithGit(t, tempDir, "B", "v1.0.0")
+	releasePackage(t, packageDir, "v1.0.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	specsA1 := loadSpecs(t, tempDir, registryName, "A", "v1.0.0")
+	specsA2 := loadSpecs(t, tempDir, registryName, "A", "v1.1.0")
+	specsB := loadSpecs(t, tempDir, registryName, "B", "v1.0.0")
+
+	// project1 uses A@v1.0.0 and develops B, project2 uses A@v1.1.0 and B
+	project1 := initPackage(t, tempDir, "project1")
+	addDependencyToProject(t, project1, "A", "v1.0.0")
+	addDependencyToProject(t, project1, "B", "v1.0.0")
+	project2 := initPackage(t, tempDir, "project2")
+	addDependencyToProject(t, project2, "A", "v1.1.0")
+	addDependencyToProject(t, project2, "B", "v1.0.0")
+	for _, projectDir := range []string{project1, project2} {
+		if _, stderr, err := runCommand(t, projectDir, "activate"); err != nil {
+			t.Fatalf("Failed to activate %s: %v\nStderr: %s", projectDir, err, stderr)
+		}
+	}
+	if _, stderr, err := runCommand(t, project1, "develop", "B"); err != nil {
+		t.Fatalf("Failed to develop B: %v\nStderr: %s", err, stderr)
+	}
+	if _, stderr, err := runCommand(t, project1, "activate"); err != nil {
+		t.Fatalf("Failed to activate project1: %v\nStderr: %s", err, stderr)
+	}
+	var indexed []string
+	data, err := os.ReadFile(filepath.Join(cosmDir, "projects.json"))
+	if err != nil || json.Unmarshal(data, &indexed) != nil || len(indexed) != 2 {
+		t.Fatalf("Expected projects.json to list both projects, got %s (%v)", data, err)
+	}
+
+	// Removing project2 leaves A@v1.1.0 and the release of B unused
+	if err := os.RemoveAll(project2); err != nil {
+		t.Fatalf("Failed to remove project2: %v", err)
+	}
+	packageA1 := filepath.Join("packages", "A", specsA1.SHA1)
+	packageA2 := filepath.Join("packages", "A", specsA2.SHA1)
+	packageB := filepath.Join("packages", "B", specsB.SHA1)
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(cosmDir, path))
+		return err == nil
+	}
+
+	// A project without a build list, e.g. one whose .cosm directory was removed, keeps no packages
+	project3 := initPackage(t, tempDir, "project3")
+	data, err = json.Marshal(append(indexed, project3))
+	if err != nil {
+		t.Fatalf("Failed to marshal project index: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cosmDir, "projects.json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write projects.json: %v", err)
+	}
+
+	// A dry run reports without removing anything
+	stdout, stderr, err := runCommand(t, tempDir, "gc", "--dry-run")
+	if err != nil {
+		t.Fatalf("Failed to run gc --dry-run: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Warning: project "+project3+" has no build list") {
+		t.Errorf("Expected a warning about project3, got %q", stdout)
+	}
+	for _, want := range []string{"Forgetting project " + project2, "Would remove " + packageA2 + " (", "Would remove " + packageB + " (", "Would remove " + filepath.Join("clones", specsB.UUID) + " (", "Would free "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected dry run output to contain %q, got %q", want, stdout)
+		}
+	}
+	if !exists(packageA2) || !exists(packageB) {
+		t.Errorf("Expected dry run to keep all packages")
+	}
+
+	// --keep-latest keeps the newest version of A
+	if _, stderr, err := runCommand(t, tempDir, "gc", "--keep-latest", "1"); err != nil {
+		t.Fatalf("Failed to run gc: %v\nStderr: %s", err, stderr)
+	}
+	if !exists(packageA1) || !exists(packageA2) || !exists(filepath.Join("clones", specsA1.UUID)) {
+		t.Errorf("Expected packages of A and its clone to be kept")
+	}
+	if !exists(filepath.Join("dev", "B@v1", "Project.json")) {
+		t.Errorf("Expected development checkout of B to be kept")
+	}
+
+	// Without a policy, only the build list of project1 is kept
+	stdout, stderr, err = runCommand(t, tempDir, "gc")
+	if err != nil {
+		t.Fatalf("Failed to run gc: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Removed "+packageA2) {
+		t.Errorf("Expected gc to remove %s, got %q", packageA2, stdout)
+	}
+	if !exists(packageA1) || exists(packageA2) || exists(packageB) || exists(filepath.Join("clones", specsB.UUID)) {
+		t.Errorf("Expected only A@v1.0.0 to be kept in the depot")
+	}
+	if !exists(filepath.Join("dev", "B@v1", "Project.json")) {
+		t.Errorf("Expected development checkout of B to be kept")
+	}
+	data, err = os.ReadFile(filepath.Join(cosmDir, "projects.json"))
+	if err != nil || json.Unmarshal(data, &indexed) != nil || len(indexed) != 2 || indexed[0] != project1 || indexed[1] != project3 {
+		t.Errorf("Expected projects.json to list project1 and project3, got %s (%v)", data, err)
+	}
+}
+
+// TestOffline tests that --offline and COSM_OFFLINE resolve against local registries and clones
+// without contacting any remote
+func TestOffline(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with a package, then make all remotes unreachable
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	projectDir := initPackage(t, tempDir, "myproject")
+	for _, remote := range []string{registryName + ".git", "mypkg.git"} {
+		if err := os.RemoveAll(filepath.Join(tempDir, remote)); err != nil {
+			t.Fatalf("Failed to remove remote %s: %v", remote, err)
+		}
+	}
+
+	// Registries are pulled without offline mode
+	if _, _, err := runCommand(t, projectDir, "add", "mypkg", "v0.1.0", "--refresh"); err == nil {
+		t.Fatalf("Expected add to fail with unreachable remotes")
+	}
+
+	// Local registry state and clones suffice in offline mode
+	stdout, stderr, err := runCommand(t, projectDir, "add", "mypkg", "v0.1.0", "--offline")
+	checkOutput(t, stdout, stderr, "Added dependency 'mypkg' v0.1.0 from registry 'myreg' to project\n", err, false, 0)
+	os.Setenv("COSM_OFFLINE", "1")
+	defer os.Unsetenv("COSM_OFFLINE")
+	if _, stderr, err := runCommand(t, projectDir, "activate"); err != nil {
+		t.Fatalf("Failed to activate offline: %v\nStderr: %s", err, stderr)
+	}
+
+	// Missing local state is reported precisely
+	_, stderr, err = runCommand(t, projectDir, "add", "otherpkg")
+	if err == nil || !strings.Contains(stderr, "not found in the local state of any registry (offline mode)") {
+		t.Errorf("Expected offline lookup error, got err=%v stderr=%q", err, stderr)
+	}
+	_, stderr, err = runCommand(t, tempDir, "registry", "update", registryName)
+	if err == nil || !strings.Contains(stderr, "cannot update registries in offline mode") {
+		t.Errorf("Expected registry update to be refused, got err=%v stderr=%q", err, stderr)
+	}
+	specs := loadSpecs(t, tempDir, registryName, "mypkg", "v0.1.0")
+	for _, dir := range []string{filepath.Join("packages", "mypkg"), filepath.Join("clones", specs.UUID)} {
+		if err := os.RemoveAll(filepath.Join(tempDir, ".cosm", dir)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+	}
+	stdout, _, err = runCommand(t, projectDir, "activate", "--output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 7 {
+		t.Errorf("Expected exit code 7, got %v", err)
+	}
+	if !strings.Contains(stdout, `"code": "offline"`) || !strings.Contains(stdout, "has no local clone") {
+		t.Errorf("Expected offline error document, got %q", stdout)
+	}
+}
+
+// TestRegistryTTL tests that registries are only pulled by reads when they are stale, that
+// --refresh forces a pull, and that registry update --all reports a summary table
+func TestRegistryTTL(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup two registries with a package
+	setupRegistry(t, tempDir, "myreg")
+	setupRegistry(t, tempDir, "otherreg")
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, "myreg", gitURL)
+	projectDir := initPackage(t, tempDir, "myproject")
+
+	// registry update --all pulls all registries and records when they were updated
+	stdout, stderr, err := runCommand(t, tempDir, "registry", "update", "--all")
+	if err != nil {
+		t.Fatalf("Failed to update registries: %v\nStderr: %s", err, stderr)
+	}
+	for _, want := range []string{"REGISTRY", "LAST UPDATED", "myreg", "otherreg", "updated", "Updated 2 of 2 registries\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in update table, got %q", want, stdout)
+		}
+	}
+	var updateTimes map[string]time.Time
+	data, err := os.ReadFile(filepath.Join(tempDir, ".cosm", "registries", "last_updated.json"))
+	if err != nil || json.Unmarshal(data, &updateTimes) != nil || len(updateTimes) != 2 {
+		t.Fatalf("Expected last_updated.json with 2 registries, got %s (%v)", data, err)
+	}
+
+	// Fresh registries are not pulled, so reads succeed without their remote
+	if err := os.RemoveAll(filepath.Join(tempDir, "myreg.git")); err != nil {
+		t.Fatalf("Failed to remove remote: %v", err)
+	}
+	stdout, stderr, err = runCommand(t, projectDir, "add", "mypkg", "v0.1.0")
+	checkOutput(t, stdout, stderr, "Added dependency 'mypkg' v0.1.0 from registry 'myreg' to project\n", err, false, 0)
+
+	// --refresh and a zero TTL force a pull
+	if _, _, err := runCommand(t, projectDir, "upgrade", "mypkg", "--refresh"); err == nil {
+		t.Errorf("Expected upgrade --refresh to fail with an unreachable remote")
+	}
+	os.Setenv("COSM_REGISTRY_TTL", "0")
+	defer os.Unsetenv("COSM_REGISTRY_TTL")
+	if _, _, err := runCommand(t, projectDir, "upgrade", "mypkg"); err == nil {
+		t.Errorf("Expected upgrade with COSM_REGISTRY_TTL=0 to fail with an unreachable remote")
+	}
+	os.Setenv("COSM_REGISTRY_TTL", "soon")
+	_, stderr, err = runCommand(t, projectDir, "upgrade", "mypkg")
+	if err == nil || !strings.Contains(stderr, "invalid COSM_REGISTRY_TTL 'soon'") {
+		t.Errorf("Expected invalid TTL error, got err=%v stderr=%q", err, stderr)
+	}
+	os.Unsetenv("COSM_REGISTRY_TTL")
+
+	// Failed pulls are reported in the table, keep the previous update time and fail the command
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "update", "--all")
+	if err == nil {
+		t.Errorf("Expected registry update --all to fail when a pull fails")
+	}
+	if !strings.Contains(stderr, "failed to update registries: myreg") {
+		t.Errorf("Expected error naming myreg, got %q", stderr)
+	}
+	if !strings.Contains(stdout, "failed") || !strings.Contains(stdout, "Updated 1 of 2 registries\n") {
+		t.Errorf("Expected one failed registry in update table, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "Failed to update registry 'myreg'") {
+		t.Errorf("Expected failure of myreg after the update table, got %q", stdout)
+	}
+	if !strings.Contains(stdout, updateTimes["myreg"].Format(time.RFC3339)) {
+		t.Errorf("Expected previous update time of myreg in update table, got %q", stdout)
+	}
+}
+
+// TestSearch tests that cosm search ranks packages across registries by name, keyword and
+// description matches and applies the registry and language filters
+func TestSearch(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup two registries with three packages
+	setupRegistry(t, tempDir, "myreg")
+	setupRegistry(t, tempDir, "otherreg")
+	jsonDir, jsonURL := setupPackageWithGit(t, tempDir, "jsonpkg", "v0.1.0")
+	setPackageMetadata(t, jsonDir, "Fast encoder", "go", "encoding", "json")
+	releasePackage(t, jsonDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, "myreg", jsonURL)
+	releasePackage(t, jsonDir, "--major")
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", "myreg", "jsonpkg", "v1.0.0"); err != nil {
+		t.Fatalf("Failed to add jsonpkg v1.0.0: %v\nStderr: %s", err, stderr)
+	}
+	yamlDir, yamlURL := setupPackageWithGit(t, tempDir, "yamlpkg", "v0.1.0")
+	setPackageMetadata(t, yamlDir, "YAML parser with JSON support", "python", "yaml")
+	releasePackage(t, yamlDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, "otherreg", yamlURL)
+	utilsDir, utilsURL := setupPackageWithGit(t, tempDir, "jsonutils", "v0.1.0")
+	releasePackage(t, utilsDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, "otherreg", utilsURL)
+
+	// Exact name matches rank above prefix and description matches
+	stdout, stderr, err := runCommand(t, tempDir, "search", "JSON")
+	if err != nil {
+		t.Fatalf("Failed to search: %v\nStderr: %s", err, stderr)
+	}
+	jsonPos := strings.Index(stdout, "jsonpkg v1.0.0 [myreg]\n  Fast encoder\n  majors: v0, v1\n  giturl: "+jsonURL+"\n")
+	utilsPos := strings.Index(stdout, "jsonutils v0.1.0 [otherreg]\n")
+	yamlPos := strings.Index(stdout, "yamlpkg v0.1.0 [otherreg]\n  YAML parser with JSON support\n")
+	if jsonPos < 0 || utilsPos < jsonPos || yamlPos < utilsPos {
+		t.Errorf("Expected jsonpkg, jsonutils and yamlpkg in that order, got %q", stdout)
+	}
+
+	// Keyword matches and filters
+	stdout, _, _ = runCommand(t, tempDir, "search", "encoding")
+	if !strings.HasPrefix(stdout, "jsonpkg v1.0.0 [myreg]\n") || strings.Contains(stdout, "yamlpkg") {
+		t.Errorf("Expected keyword match of jsonpkg only, got %q", stdout)
+	}
+	stdout, _, _ = runCommand(t, tempDir, "search", "json", "--language", "Python")
+	if !strings.HasPrefix(stdout, "yamlpkg v0.1.0 [otherreg]\n") || strings.Contains(stdout, "jsonpkg") {
+		t.Errorf("Expected language filter to keep only yamlpkg, got %q", stdout)
+	}
+	stdout, _, _ = runCommand(t, tempDir, "search", "json", "--registry", "myreg")
+	if strings.Contains(stdout, "otherreg") {
+		t.Errorf("Expected registry filter to exclude otherreg, got %q", stdout)
+	}
+	stdout, _, _ = runCommand(t, tempDir, "search", "xml")
+	checkOutput(t, stdout, "", "No packages found matching 'xml'\n", nil, false, 0)
+	_, stderr, err = runCommand(t, tempDir, "search", "json", "--registry", "nosuchreg")
+	if err == nil || !strings.Contains(stderr, "registry 'nosuchreg' not found") {
+		t.Errorf("Expected unknown registry error, got err=%v stderr=%q", err, stderr)
+	}
+
+	// The index is regenerated when versions are removed
+	removeFromRegistry(t, tempDir, "myreg", "jsonpkg", "v1.0.0")
+	data, err := os.ReadFile(filepath.Join(tempDir, ".cosm", "registries", "myreg", "index.json"))
+	if err != nil {
+		t.Fatalf("Failed to read index.json: %v", err)
+	}
+	if !strings.Contains(string(data), `"latest": "v0.1.0"`) {
+		t.Errorf("Expected latest version v0.1.0 in index.json, got %s", data)
+	}
+}
+
+// TestInfo tests that cosm info shows the versions, requirements, build list and dependents of a
+// package, selecting the latest version or the latest version matching a query
+func TestInfo(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with pkgB v0.1.0 and v0.2.0, and pkgA v0.1.0 requiring pkgB v0.1.0
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	pkgBDir, pkgBURL := setupPackageWithGit(t, tempDir, "pkgB", "v0.1.0")
+	releasePackage(t, pkgBDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgBURL)
+	releasePackage(t, pkgBDir, "--minor")
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", registryName, "pkgB", "v0.2.0"); err != nil {
+		t.Fatalf("Failed to add pkgB v0.2.0: %v\nStderr: %s", err, stderr)
+	}
+	pkgADir, pkgAURL := setupPackageWithGit(t, tempDir, "pkgA", "v0.1.0")
+	addDependencyToProject(t, pkgADir, "pkgB", "v0.1.0")
+	commitAndPushPackageChanges(t, pkgADir, "Add pkgB")
+	releasePackage(t, pkgADir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgAURL)
+	specsB1 := loadSpecs(t, tempDir, registryName, "pkgB", "v0.1.0")
+	specsB2 := loadSpecs(t, tempDir, registryName, "pkgB", "v0.2.0")
+
+	// The latest version is selected by default
+	stdout, stderr, err := runCommand(t, tempDir, "info", "pkgB")
+	expected := fmt.Sprintf("Package 'pkgB' in registry 'myreg'\n"+
+		"  UUID:   %s\n"+
+		"  GitURL: %s\n"+
+		"  Versions:\n"+
+		"    v0.1.0 %s\n"+
+		"    v0.2.0 %s (selected)\n"+
+		"  Dependencies of v0.2.0:\n"+
+		"    (none)\n"+
+		"  Build list of v0.2.0:\n"+
+		"    (none)\n"+
+		"  Dependents of v0.2.0 in registry 'myreg':\n"+
+		"    (none)\n", specsB2.UUID, pkgBURL, specsB1.SHA1, specsB2.SHA1)
+	checkOutput(t, stdout, stderr, expected, err, false, 0)
+
+	// A version query selects the latest matching version and its dependents
+	stdout, _, err = runCommand(t, tempDir, "info", "pkgB@v0.1")
+	if err != nil || !strings.Contains(stdout, "v0.1.0 "+specsB1.SHA1+" (selected)\n") ||
+		!strings.Contains(stdout, "  Dependents of v0.1.0 in registry 'myreg':\n    pkgA v0.1.0 (direct)\n") {
+		t.Errorf("Expected v0.1.0 with dependent pkgA, got %q (%v)", stdout, err)
+	}
+	stdout, _, err = runCommand(t, tempDir, "info", "pkgA")
+	if err != nil || !strings.Contains(stdout, "  Dependencies of v0.1.0:\n    pkgB v0.1.0\n") ||
+		!strings.Contains(stdout, "  Build list of v0.1.0:\n    pkgB v0.1.0 "+specsB1.SHA1+"\n") {
+		t.Errorf("Expected requirements and build list of pkgA, got %q (%v)", stdout, err)
+	}
+
+	// Unknown packages and versions are reported as not found
+	stdout, _, err = runCommand(t, tempDir, "info", "pkgB@v2", "--output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 || !strings.Contains(stdout, "no version of package 'pkgB' matching 'v2'") {
+		t.Errorf("Expected not_found for pkgB@v2, got %q (%v)", stdout, err)
+	}
+	_, stderr, err = runCommand(t, tempDir, "info", "nosuchpkg")
+	if err == nil || !strings.Contains(stderr, "package 'nosuchpkg' not found in any registry") {
+		t.Errorf("Expected unknown package error, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestRegistryDependents tests the reverse-dependency index of a registry and that registry rm
+// refuses to remove required versions without --force
+func TestRegistryDependents(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with pkgC v0.1.0, pkgB v0.1.0 requiring pkgC and pkgA v0.1.0 requiring pkgB
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	pkgCDir, pkgCURL := setupPackageWithGit(t, tempDir, "pkgC", "v0.1.0")
+	releasePackage(t, pkgCDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgCURL)
+	pkgBDir, pkgBURL := setupPackageWithGit(t, tempDir, "pkgB", "v0.1.0")
+	addDependencyToProject(t, pkgBDir, "pkgC", "v0.1.0")
+	commitAndPushPackageChanges(t, pkgBDir, "Add pkgC")
+	releasePackage(t, pkgBDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgBURL)
+	pkgADir, pkgAURL := setupPackageWithGit(t, tempDir, "pkgA", "v0.1.0")
+	addDependencyToProject(t, pkgADir, "pkgB", "v0.1.0")
+	commitAndPushPackageChanges(t, pkgADir, "Add pkgB")
+	releasePackage(t, pkgADir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgAURL)
+
+	// Direct and build list dependents are listed per version
+	stdout, stderr, err := runCommand(t, tempDir, "registry", "dependents", registryName, "pkgC")
+	expected := "Dependents of 'pkgC' in registry 'myreg':\n" +
+		"  v0.1.0:\n" +
+		"    pkgA v0.1.0 (build list)\n" +
+		"    pkgB v0.1.0 (direct)\n"
+	checkOutput(t, stdout, stderr, expected, err, false, 0)
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "dependents", registryName, "pkgA", "v0.1.0")
+	checkOutput(t, stdout, stderr, "Dependents of 'pkgA' in registry 'myreg':\n  v0.1.0:\n    (none)\n", err, false, 0)
+
+	// Required versions and packages are not removed without --force
+	stdout, _, err = runCommand(t, tempDir, "registry", "rm", registryName, "pkgC", "v0.1.0", "--output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 8 {
+		t.Errorf("Expected exit code 8, got %v", err)
+	}
+	if !strings.Contains(stdout, `"code": "has_dependents"`) || !strings.Contains(stdout, "required by 2 registered versions: pkgA@v0.1.0, pkgB@v0.1.0") {
+		t.Errorf("Expected has_dependents error document, got %q", stdout)
+	}
+	_, stderr, err = runCommand(t, tempDir, "registry", "rm", registryName, "pkgB")
+	if err == nil || !strings.Contains(stderr, "cannot remove package 'pkgB' from registry 'myreg': required by 1 registered versions: pkgA@v0.1.0") {
+		t.Errorf("Expected removal of pkgB to be refused, got err=%v stderr=%q", err, stderr)
+	}
+	verifyVersionsJSON(t, filepath.Join(tempDir, ".cosm", "registries", registryName, "P", "pkgC", "versions.json"), []string{"v0.1.0"})
+
+	// --force removes the version with a warning and updates the index
+	_, stderr, err = runCommand(t, tempDir, "registry", "rm", registryName, "pkgA", "--force")
+	if err != nil || stderr != "" {
+		t.Fatalf("Failed to remove pkgA: %v\nStderr: %s", err, stderr)
+	}
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "rm", registryName, "pkgC", "v0.1.0", "--force")
+	if err != nil || !strings.Contains(stdout, "Warning: removing version 'v0.1.0' of package 'pkgC' breaks 1 registered versions: pkgB@v0.1.0") {
+		t.Errorf("Expected forced removal with warning, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, ".cosm", "registries", registryName, "dependents.json"))
+	if err != nil {
+		t.Fatalf("Failed to read dependents.json: %v", err)
+	}
+	if strings.Contains(string(data), "pkgA") || !strings.Contains(string(data), loadProjectFile(t, filepath.Join(pkgCDir, "Project.json")).UUID) {
+		t.Errorf("Expected pkgA to be removed from dependents.json and pkgB to still require pkgC, got %s", data)
+	}
+
+	// Registering the removed version again restores its dependents
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", registryName, "pkgC", "v0.1.0"); err != nil {
+		t.Fatalf("Failed to add pkgC v0.1.0 again: %v\nStderr: %s", err, stderr)
+	}
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "dependents", registryName, "pkgC")
+	checkOutput(t, stdout, stderr, "Dependents of 'pkgC' in registry 'myreg':\n  v0.1.0:\n    pkgB v0.1.0 (direct)\n", err, false, 0)
+	_, stderr, err = runCommand(t, tempDir, "registry", "rm", registryName, "pkgC", "v0.1.0")
+	if err == nil || !strings.Contains(stderr, "required by 1 registered versions: pkgB@v0.1.0") {
+		t.Errorf("Expected removal of re-registered pkgC v0.1.0 to be refused, got err=%v stderr=%q", err, stderr)
+	}
+}
+
+// TestYank tests that yanked versions stay resolvable for existing requirements but are not
+// selected by add or upgrade, that status warns about them, and that unyank reverts the yank
+func TestYank(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with mypkg v0.1.0 and v0.2.0 and a project requiring v0.2.0
+	registryName := "myreg"
+	setupRegistry(t, tempDir, registryName)
+	packageDir, gitURL := setupPackageWithGit(t, tempDir, "mypkg", "v0.1.0")
+	releasePackage(t, packageDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, gitURL)
+	releasePackage(t, packageDir, "--minor")
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", registryName, "mypkg", "v0.2.0"); err != nil {
+		t.Fatalf("Failed to add mypkg v0.2.0: %v\nStderr: %s", err, stderr)
+	}
+	project1 := initPackage(t, tempDir, "project1")
+	addDependencyToProject(t, project1, "mypkg", "v0.2.0")
+
+	// Yank v0.2.0 with a reason
+	stdout, stderr, err := runCommand(t, tempDir, "registry", "yank", registryName, "mypkg", "v0.2.0", "--reason", "broken build")
+	checkOutput(t, stdout, stderr, "Yanked version 'v0.2.0' of package 'mypkg' in registry 'myreg'\n", err, false, 0)
+	versionsFile := filepath.Join(tempDir, ".cosm", "registries", registryName, "M", "mypkg", "versions.json")
+	verifyVersionsJSON(t, versionsFile, []string{"v0.1.0", "v0.2.0"})
+	yankedFile := filepath.Join(filepath.Dir(versionsFile), "yanked.json")
+	data, err := os.ReadFile(yankedFile)
+	if err != nil || !strings.Contains(string(data), `"reason": "broken build"`) {
+		t.Errorf("Expected yank to be recorded in yanked.json, got %s (%v)", data, err)
+	}
+	_, stderr, err = runCommand(t, tempDir, "registry", "yank", registryName, "mypkg", "v0.2.0")
+	if err == nil || !strings.Contains(stderr, "version 'v0.2.0' of package 'mypkg' is already yanked") {
+		t.Errorf("Expected second yank to fail, got err=%v stderr=%q", err, stderr)
+	}
+
+	// Existing requirements still resolve, with a warning in status
+	if _, stderr, err := runCommand(t, project1, "activate"); err != nil {
+		t.Fatalf("Expected yanked version to stay resolvable: %v\nStderr: %s", err, stderr)
+	}
+	stdout, _, err = runCommand(t, project1, "status")
+	if err != nil || !strings.Contains(stdout, "mypkg v0.2.0 from 'myreg' [yanked]") ||
+		!strings.Contains(stdout, "Warning: mypkg v0.2.0 is yanked in registry 'myreg' (broken build)") {
+		t.Errorf("Expected yank warning in status, got %q (%v)", stdout, err)
+	}
+	stdout, _, _ = runCommand(t, tempDir, "info", "mypkg")
+	if !strings.Contains(stdout, "v0.2.0 ") || !strings.Contains(stdout, " (yanked: broken build)\n") || !strings.Contains(stdout, "Dependencies of v0.1.0:") {
+		t.Errorf("Expected info to mark v0.2.0 as yanked and select v0.1.0, got %q", stdout)
+	}
+
+	// New requirements never select the yanked version
+	project2 := initPackage(t, tempDir, "project2")
+	stdout, stderr, err = runCommand(t, project2, "add", "mypkg")
+	checkOutput(t, stdout, stderr, "Added dependency 'mypkg' v0.1.0 from registry 'myreg' to project\n", err, false, 0)
+	_, stderr, err = runCommand(t, project2, "add", "mypkg", "v0.2.0")
+	if err == nil || !strings.Contains(stderr, "version 'v0.2.0' of package 'mypkg' is yanked in registry 'myreg' (broken build)") {
+		t.Errorf("Expected adding a yanked version to fail, got err=%v stderr=%q", err, stderr)
+	}
+	stdout, _, err = runCommand(t, project2, "upgrade", "mypkg")
+	if err != nil || !strings.Contains(stdout, "Dependency 'mypkg' is already at v0.1.0") {
+		t.Errorf("Expected upgrade to skip the yanked version, got %q (%v)", stdout, err)
+	}
+
+	// Unyank makes the version selectable again and removes yanked.json
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "unyank", registryName, "mypkg", "v0.2.0")
+	checkOutput(t, stdout, stderr, "Unyanked version 'v0.2.0' of package 'mypkg' in registry 'myreg'\n", err, false, 0)
+	verifyVersionsJSON(t, versionsFile, []string{"v0.1.0", "v0.2.0"})
+	if _, err := os.Stat(yankedFile); !os.IsNotExist(err) {
+		t.Errorf("Expected yanked.json to be removed, got %v", err)
+	}
+	if _, stderr, err := runCommand(t, project2, "upgrade", "mypkg"); err != nil {
+		t.Fatalf("Failed to upgrade mypkg: %v\nStderr: %s", err, stderr)
+	}
+	verifyProjectDependencies(t, filepath.Join(project2, "Project.json"), "mypkg", "v0.2.0")
+}
+
+// TestRegistryVerify tests that cosm registry verify reports drift between versions.json, the
+// version directories, specs.json, buildlist.json and registry.json, and repairs versions.json
+// with --fix without dropping listed versions
+func TestRegistryVerify(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Setup registry with pkgA v0.1.0 and v0.2.0 and pkgB v0.1.0 requiring pkgA v0.1.0
+	registryName := "myreg"
+	_, registryDir := setupRegistry(t, tempDir, registryName)
+	pkgADir, pkgAURL := setupPackageWithGit(t, tempDir, "pkgA", "v0.1.0")
+	releasePackage(t, pkgADir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgAURL)
+	releasePackage(t, pkgADir, "--minor")
+	if _, stderr, err := runCommand(t, tempDir, "registry", "add", registryName, "pkgA", "v0.2.0"); err != nil {
+		t.Fatalf("Failed to add pkgA v0.2.0: %v\nStderr: %s", err, stderr)
+	}
+	pkgBDir, pkgBURL := setupPackageWithGit(t, tempDir, "pkgB", "v0.1.0")
+	addDependencyToProject(t, pkgBDir, "pkgA", "v0.1.0")
+	commitAndPushPackageChanges(t, pkgBDir, "Add pkgA")
+	releasePackage(t, pkgBDir, "v0.1.0")
+	addPackageToRegistry(t, tempDir, registryName, pkgBURL)
+
+	stdout, stderr, err := runCommand(t, tempDir, "registry", "verify", registryName, "--deep")
+	checkOutput(t, stdout, stderr, "Registry 'myreg': 0 errors, 0 warnings, 0 fixed\n", err, false, 0)
+
+	// A version without directory is a fixable error; a changed SHA1 is only found with --deep
+	versionsFile := filepath.Join(registryDir, "P", "pkgA", "versions.json")
+	if err := os.WriteFile(versionsFile, []byte(`["v0.1.0", "v0.2.0", "v0.3.0"]`), 0644); err != nil {
+		t.Fatalf("Failed to write versions.json: %v", err)
+	}
+	specs := loadSpecs(t, tempDir, registryName, "pkgA", "v0.2.0")
+	goodSHA1 := specs.SHA1
+	specs.SHA1 = strings.Repeat("0", 40)
+	writeSpecs := func(specs types.Specs) {
+		t.Helper()
+		data, err := json.MarshalIndent(specs, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to marshal specs: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(registryDir, "P", "pkgA", "v0.2.0", "specs.json"), data, 0644); err != nil {
+			t.Fatalf("Failed to write specs.json: %v", err)
+		}
+	}
+	writeSpecs(specs)
+	stdout, _, err = runCommand(t, tempDir, "registry", "verify", registryName)
+	if err == nil || !strings.Contains(stdout, "Errors:\n  pkgA@v0.3.0: versions.json lists v0.3.0 but its directory does not exist [fixable with --fix]\n") ||
+		strings.Contains(stdout, "SHA1") {
+		t.Errorf("Expected a fixable error for v0.3.0 only, got err=%v stdout=%q", err, stdout)
+	}
+	stdout, _, err = runCommand(t, tempDir, "registry", "verify", registryName, "--deep", "--output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 6 {
+		t.Errorf("Expected exit code 6, got %v", err)
+	}
+	var doc struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				Findings []struct {
+					Severity string `json:"severity"`
+					Version  string `json:"version"`
+					Message  string `json:"message"`
+				} `json:"findings"`
+				Errors int `json:"errors"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &doc); err != nil {
+		t.Fatalf("Failed to parse JSON output %q: %v", stdout, err)
+	}
+	if doc.Error.Code != "verification_failed" || doc.Error.Details.Errors != 2 ||
+		!strings.Contains(stdout, fmt.Sprintf("tag v0.2.0 points to %s", goodSHA1)) {
+		t.Errorf("Unexpected JSON error document: %s", stdout)
+	}
+
+	// --fix regenerates versions.json from the version directories and pushes the repair
+	specs.SHA1 = goodSHA1
+	writeSpecs(specs)
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "verify", registryName, "--fix")
+	if err != nil || !strings.Contains(stdout, "does not exist [fixed]") || !strings.HasSuffix(stdout, "Registry 'myreg': 0 errors, 0 warnings, 1 fixed\n") {
+		t.Errorf("Expected versions.json to be fixed, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+	verifyVersionsJSON(t, versionsFile, []string{"v0.1.0", "v0.2.0"})
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "verify", registryName)
+	checkOutput(t, stdout, stderr, "Registry 'myreg': 0 errors, 0 warnings, 0 fixed\n", err, false, 0)
+
+	// --fix keeps listed versions whose specs are unusable and only removes the missing version
+	specsFile := filepath.Join(registryDir, "P", "pkgA", "v0.2.0", "specs.json")
+	if err := os.WriteFile(specsFile, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write specs.json: %v", err)
+	}
+	if err := os.WriteFile(versionsFile, []byte(`["v0.1.0", "v0.2.0", "v0.3.0"]`), 0644); err != nil {
+		t.Fatalf("Failed to write versions.json: %v", err)
+	}
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "verify", registryName, "--fix")
+	if err == nil || !strings.Contains(stdout, "v0.3.0 but its directory does not exist [fixed]") ||
+		!strings.Contains(stdout, "specs.json of v0.2.0 cannot be loaded") || !strings.HasSuffix(stdout, "Registry 'myreg': 1 errors, 0 warnings, 1 fixed\n") {
+		t.Errorf("Expected only v0.3.0 to be fixed, got err=%v stdout=%q stderr=%q", err, stdout, stderr)
+	}
+	verifyVersionsJSON(t, versionsFile, []string{"v0.1.0", "v0.2.0"})
+	writeSpecs(specs)
+	stdout, stderr, err = runCommand(t, tempDir, "registry", "verify", registryName)
+	checkOutput(t, stdout, stderr, "Registry 'myreg': 0 errors, 0 warnings, 0 fixed\n", err, false, 0)
+
+	// Missing package directories and build lists requiring removed versions are not fixable
+	if err := os.RemoveAll(filepath.Join(registryDir, "P", "pkgA", "v0.1.0")); err != nil {
+		t.Fatalf("Failed to remove version directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(registryDir, "S", "stray"), 0755); err != nil {
+		t.Fatalf("Failed to create stray directory: %v", err)
+	}
+	stdout, _, err = runCommand(t, tempDir, "registry", "verify", registryName)
+	for _, expected := range []string{
+		"  pkgB@v0.1.0: buildlist.json of v0.1.0 requires pkgA@v0.1.0 which is not registered in any registry\n",
+		"Warnings:\n",
+		"  stray: directory S/stray is not registered in registry.json\n",
+	} {
+		if err == nil || !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in output, got err=%v stdout=%q", expected, err, stdout)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(registryDir, "P", "pkgB")); err != nil {
+		t.Fatalf("Failed to remove package directory: %v", err)
+	}
+	stdout, _, err = runCommand(t, tempDir, "registry", "verify", registryName)
+	if err == nil || !strings.Contains(stdout, "  pkgB: package is registered in registry.json but directory P/pkgB does not exist\n") {
+		t.Errorf("Expected missing package directory to be reported, got err=%v stdout=%q", err, stdout)
+	}
 }
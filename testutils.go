@@ -262,6 +262,22 @@ func loadProjectFile(t *testing.T, projectFile string) types.Project {
 	return project
 }
 
+// setPackageMetadata sets the search metadata in Project.json of a package and pushes the change
+func setPackageMetadata(t *testing.T, packageDir, description, language string, keywords ...string) {
+	t.Helper()
+	projectFile := filepath.Join(packageDir, "Project.json")
+	project := loadProjectFile(t, projectFile)
+	project.Description, project.Language, project.Keywords = description, language, keywords
+	data, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal Project.json: %v", err)
+	}
+	if err := os.WriteFile(projectFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write Project.json: %v", err)
+	}
+	commitAndPushPackageChanges(t, packageDir, "Set package metadata")
+}
+
 // removeFromRegistry executes the cosm registry rm command and verifies its output
 func removeFromRegistry(t *testing.T, dir, registryName, packageName string, version string) (stdout, stderr string) {
 	t.Helper()
@@ -658,6 +674,18 @@ func getCloneBranch(t *testing.T, cloneDir string) string {
 	return strings.TrimSpace(string(output))
 }
 
+// getCloneHead returns the commit checked out in the clone directory
+func getCloneHead(t *testing.T, cloneDir string) string {
+	t.Helper()
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = cloneDir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD of %s: %v", cloneDir, err)
+	}
+	return strings.TrimSpace(string(output))
+}
+
 // verifyCloneBranch checks if the clone is on the expected branch
 func verifyCloneBranch(t *testing.T, cloneDir, expectedBranch string) {
 	t.Helper()
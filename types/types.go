@@ -28,35 +28,51 @@ type Dependency struct {
 
 // Project represents a project configuration
 type Project struct {
-	Name     string                `json:"name"`
-	UUID     string                `json:"uuid"`
-	Authors  []string              `json:"authors"`
-	Language string                `json:"language,omitempty"`
-	Version  string                `json:"version"`
-	Deps     map[string]Dependency `json:"deps,omitempty"` // Changed from []Dependency to map[string]string
+	Name        string                `json:"name"`
+	UUID        string                `json:"uuid"`
+	Authors     []string              `json:"authors"`
+	Language    string                `json:"language,omitempty"`
+	Version     string                `json:"version"`
+	Description string                `json:"description,omitempty"` // shown and matched by cosm search
+	Keywords    []string              `json:"keywords,omitempty"`
+	Deps        map[string]Dependency `json:"deps,omitempty"` // Changed from []Dependency to map[string]string
 }
 
 // Specs represents the metadata for a package version
 type Specs struct {
-	Name    string                `json:"name"`
-	UUID    string                `json:"uuid"`
-	Version string                `json:"version"`
-	GitURL  string                `json:"giturl"`
-	SHA1    string                `json:"sha1"`
-	Deps    map[string]Dependency `json:"deps"`
+	Name        string                `json:"name"`
+	UUID        string                `json:"uuid"`
+	Version     string                `json:"version"`
+	GitURL      string                `json:"giturl"`
+	SHA1        string                `json:"sha1"`
+	TreeHash    string                `json:"treehash,omitempty"` // content hash of the files of the version
+	Deps        map[string]Dependency `json:"deps"`
+	Description string                `json:"description,omitempty"` // search metadata from Project.json
+	Keywords    []string              `json:"keywords,omitempty"`
+	Language    string                `json:"language,omitempty"`
+}
+
+// YankedVersion records why and when a version was yanked from a registry. Yanked versions stay
+// resolvable for existing requirements but are never selected by add or upgrade.
+type YankedVersion struct {
+	Reason string `json:"reason,omitempty"`
+	Date   string `json:"date"`
 }
 
 // BuildList represents the minimum version dependencies for a package version
 type BuildList struct {
 	Dependencies map[string]BuildListDependency `json:"dependencies"`
+	Digest       string                         `json:"digest,omitempty"` // digest of the inputs of a project build list
 }
 
 // BuildListDependency represents a single dependency in the build list
 type BuildListDependency struct {
-	Name    string `json:"name"`
-	UUID    string `json:"uuid"`
-	Version string `json:"version"`
-	GitURL  string `json:"giturl"`
-	SHA1    string `json:"sha1"`
-	Path    string `json:"path"`
+	Name     string `json:"name"`
+	UUID     string `json:"uuid"`
+	Version  string `json:"version"`
+	GitURL   string `json:"giturl"`
+	SHA1     string `json:"sha1"`
+	TreeHash string `json:"treehash,omitempty"` // content hash recorded in specs.json
+	Path     string `json:"path"`
+	Develop  bool   `json:"develop,omitempty"` // Path points to a development checkout
 }